@@ -0,0 +1,773 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: api/gateway/v1/gateway.proto
+
+// API gRPC del gateway: mismo contrato que POST /api/agent/chat (ChatRequest / ChatResponse).
+
+package gatewayv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ChatRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	SessionId     int64                  `protobuf:"varint,2,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	IdEmpresa     int64                  `protobuf:"varint,3,opt,name=id_empresa,json=idEmpresa,proto3" json:"id_empresa,omitempty"`
+	ApiKey        string                 `protobuf:"bytes,4,opt,name=api_key,json=apiKey,proto3" json:"api_key,omitempty"`
+	Config        *ChatConfig            `protobuf:"bytes,5,opt,name=config,proto3" json:"config,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChatRequest) Reset() {
+	*x = ChatRequest{}
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChatRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChatRequest) ProtoMessage() {}
+
+func (x *ChatRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChatRequest.ProtoReflect.Descriptor instead.
+func (*ChatRequest) Descriptor() ([]byte, []int) {
+	return file_api_gateway_v1_gateway_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ChatRequest) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ChatRequest) GetSessionId() int64 {
+	if x != nil {
+		return x.SessionId
+	}
+	return 0
+}
+
+func (x *ChatRequest) GetIdEmpresa() int64 {
+	if x != nil {
+		return x.IdEmpresa
+	}
+	return 0
+}
+
+func (x *ChatRequest) GetApiKey() string {
+	if x != nil {
+		return x.ApiKey
+	}
+	return ""
+}
+
+func (x *ChatRequest) GetConfig() *ChatConfig {
+	if x != nil {
+		return x.Config
+	}
+	return nil
+}
+
+type ChatConfig struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	NombreBot           string                 `protobuf:"bytes,1,opt,name=nombre_bot,json=nombreBot,proto3" json:"nombre_bot,omitempty"`
+	Modalidad           string                 `protobuf:"bytes,2,opt,name=modalidad,proto3" json:"modalidad,omitempty"`
+	FraseSaludo         string                 `protobuf:"bytes,3,opt,name=frase_saludo,json=fraseSaludo,proto3" json:"frase_saludo,omitempty"`
+	ArchivoSaludo       string                 `protobuf:"bytes,4,opt,name=archivo_saludo,json=archivoSaludo,proto3" json:"archivo_saludo,omitempty"`
+	Personalidad        string                 `protobuf:"bytes,5,opt,name=personalidad,proto3" json:"personalidad,omitempty"`
+	FraseDes            string                 `protobuf:"bytes,6,opt,name=frase_des,json=fraseDes,proto3" json:"frase_des,omitempty"`
+	FraseNoSabe         string                 `protobuf:"bytes,7,opt,name=frase_no_sabe,json=fraseNoSabe,proto3" json:"frase_no_sabe,omitempty"`
+	CorreoUsuario       string                 `protobuf:"bytes,8,opt,name=correo_usuario,json=correoUsuario,proto3" json:"correo_usuario,omitempty"`
+	DuracionCitaMinutos int32                  `protobuf:"varint,9,opt,name=duracion_cita_minutos,json=duracionCitaMinutos,proto3" json:"duracion_cita_minutos,omitempty"`
+	Slots               int32                  `protobuf:"varint,10,opt,name=slots,proto3" json:"slots,omitempty"`
+	AgendarUsuario      *bool                  `protobuf:"varint,11,opt,name=agendar_usuario,json=agendarUsuario,proto3,oneof" json:"agendar_usuario,omitempty"`
+	AgendarSucursal     *bool                  `protobuf:"varint,12,opt,name=agendar_sucursal,json=agendarSucursal,proto3,oneof" json:"agendar_sucursal,omitempty"`
+	UsuarioId           int64                  `protobuf:"varint,13,opt,name=usuario_id,json=usuarioId,proto3" json:"usuario_id,omitempty"`
+	IdChatbot           int64                  `protobuf:"varint,14,opt,name=id_chatbot,json=idChatbot,proto3" json:"id_chatbot,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *ChatConfig) Reset() {
+	*x = ChatConfig{}
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChatConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChatConfig) ProtoMessage() {}
+
+func (x *ChatConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChatConfig.ProtoReflect.Descriptor instead.
+func (*ChatConfig) Descriptor() ([]byte, []int) {
+	return file_api_gateway_v1_gateway_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ChatConfig) GetNombreBot() string {
+	if x != nil {
+		return x.NombreBot
+	}
+	return ""
+}
+
+func (x *ChatConfig) GetModalidad() string {
+	if x != nil {
+		return x.Modalidad
+	}
+	return ""
+}
+
+func (x *ChatConfig) GetFraseSaludo() string {
+	if x != nil {
+		return x.FraseSaludo
+	}
+	return ""
+}
+
+func (x *ChatConfig) GetArchivoSaludo() string {
+	if x != nil {
+		return x.ArchivoSaludo
+	}
+	return ""
+}
+
+func (x *ChatConfig) GetPersonalidad() string {
+	if x != nil {
+		return x.Personalidad
+	}
+	return ""
+}
+
+func (x *ChatConfig) GetFraseDes() string {
+	if x != nil {
+		return x.FraseDes
+	}
+	return ""
+}
+
+func (x *ChatConfig) GetFraseNoSabe() string {
+	if x != nil {
+		return x.FraseNoSabe
+	}
+	return ""
+}
+
+func (x *ChatConfig) GetCorreoUsuario() string {
+	if x != nil {
+		return x.CorreoUsuario
+	}
+	return ""
+}
+
+func (x *ChatConfig) GetDuracionCitaMinutos() int32 {
+	if x != nil {
+		return x.DuracionCitaMinutos
+	}
+	return 0
+}
+
+func (x *ChatConfig) GetSlots() int32 {
+	if x != nil {
+		return x.Slots
+	}
+	return 0
+}
+
+func (x *ChatConfig) GetAgendarUsuario() bool {
+	if x != nil && x.AgendarUsuario != nil {
+		return *x.AgendarUsuario
+	}
+	return false
+}
+
+func (x *ChatConfig) GetAgendarSucursal() bool {
+	if x != nil && x.AgendarSucursal != nil {
+		return *x.AgendarSucursal
+	}
+	return false
+}
+
+func (x *ChatConfig) GetUsuarioId() int64 {
+	if x != nil {
+		return x.UsuarioId
+	}
+	return 0
+}
+
+func (x *ChatConfig) GetIdChatbot() int64 {
+	if x != nil {
+		return x.IdChatbot
+	}
+	return 0
+}
+
+type ChatResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reply         string                 `protobuf:"bytes,1,opt,name=reply,proto3" json:"reply,omitempty"`
+	SessionId     int64                  `protobuf:"varint,2,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	AgentUsed     string                 `protobuf:"bytes,3,opt,name=agent_used,json=agentUsed,proto3" json:"agent_used,omitempty"`
+	Url           *string                `protobuf:"bytes,4,opt,name=url,proto3,oneof" json:"url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChatResponse) Reset() {
+	*x = ChatResponse{}
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChatResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChatResponse) ProtoMessage() {}
+
+func (x *ChatResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChatResponse.ProtoReflect.Descriptor instead.
+func (*ChatResponse) Descriptor() ([]byte, []int) {
+	return file_api_gateway_v1_gateway_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ChatResponse) GetReply() string {
+	if x != nil {
+		return x.Reply
+	}
+	return ""
+}
+
+func (x *ChatResponse) GetSessionId() int64 {
+	if x != nil {
+		return x.SessionId
+	}
+	return 0
+}
+
+func (x *ChatResponse) GetAgentUsed() string {
+	if x != nil {
+		return x.AgentUsed
+	}
+	return ""
+}
+
+func (x *ChatResponse) GetUrl() string {
+	if x != nil && x.Url != nil {
+		return *x.Url
+	}
+	return ""
+}
+
+// ChatEvent es un evento de ChatStream: processing y luego reply.
+type ChatEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*ChatEvent_Processing
+	//	*ChatEvent_Reply
+	Event         isChatEvent_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChatEvent) Reset() {
+	*x = ChatEvent{}
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChatEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChatEvent) ProtoMessage() {}
+
+func (x *ChatEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChatEvent.ProtoReflect.Descriptor instead.
+func (*ChatEvent) Descriptor() ([]byte, []int) {
+	return file_api_gateway_v1_gateway_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ChatEvent) GetEvent() isChatEvent_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *ChatEvent) GetProcessing() *Processing {
+	if x != nil {
+		if x, ok := x.Event.(*ChatEvent_Processing); ok {
+			return x.Processing
+		}
+	}
+	return nil
+}
+
+func (x *ChatEvent) GetReply() *ChatResponse {
+	if x != nil {
+		if x, ok := x.Event.(*ChatEvent_Reply); ok {
+			return x.Reply
+		}
+	}
+	return nil
+}
+
+type isChatEvent_Event interface {
+	isChatEvent_Event()
+}
+
+type ChatEvent_Processing struct {
+	Processing *Processing `protobuf:"bytes,1,opt,name=processing,proto3,oneof"`
+}
+
+type ChatEvent_Reply struct {
+	Reply *ChatResponse `protobuf:"bytes,2,opt,name=reply,proto3,oneof"`
+}
+
+func (*ChatEvent_Processing) isChatEvent_Event() {}
+
+func (*ChatEvent_Reply) isChatEvent_Event() {}
+
+// Processing indica que el mensaje paso la validacion y el agente esta respondiendo.
+type Processing struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	Agent         string                 `protobuf:"bytes,2,opt,name=agent,proto3" json:"agent,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Processing) Reset() {
+	*x = Processing{}
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Processing) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Processing) ProtoMessage() {}
+
+func (x *Processing) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Processing.ProtoReflect.Descriptor instead.
+func (*Processing) Descriptor() ([]byte, []int) {
+	return file_api_gateway_v1_gateway_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Processing) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *Processing) GetAgent() string {
+	if x != nil {
+		return x.Agent
+	}
+	return ""
+}
+
+type HealthRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HealthRequest) Reset() {
+	*x = HealthRequest{}
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HealthRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HealthRequest) ProtoMessage() {}
+
+func (x *HealthRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HealthRequest.ProtoReflect.Descriptor instead.
+func (*HealthRequest) Descriptor() ([]byte, []int) {
+	return file_api_gateway_v1_gateway_proto_rawDescGZIP(), []int{5}
+}
+
+type HealthResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ok | degraded | unavailable (mismo criterio que GET /health).
+	Status        string                  `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Ready         bool                    `protobuf:"varint,2,opt,name=ready,proto3" json:"ready,omitempty"`
+	Agents        map[string]*AgentHealth `protobuf:"bytes,3,rep,name=agents,proto3" json:"agents,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HealthResponse) Reset() {
+	*x = HealthResponse{}
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HealthResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HealthResponse) ProtoMessage() {}
+
+func (x *HealthResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HealthResponse.ProtoReflect.Descriptor instead.
+func (*HealthResponse) Descriptor() ([]byte, []int) {
+	return file_api_gateway_v1_gateway_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *HealthResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *HealthResponse) GetReady() bool {
+	if x != nil {
+		return x.Ready
+	}
+	return false
+}
+
+func (x *HealthResponse) GetAgents() map[string]*AgentHealth {
+	if x != nil {
+		return x.Agents
+	}
+	return nil
+}
+
+type AgentHealth struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	Status              string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Critical            bool                   `protobuf:"varint,2,opt,name=critical,proto3" json:"critical,omitempty"`
+	ConsecutiveFailures int32                  `protobuf:"varint,3,opt,name=consecutive_failures,json=consecutiveFailures,proto3" json:"consecutive_failures,omitempty"`
+	LatencyMs           int64                  `protobuf:"varint,4,opt,name=latency_ms,json=latencyMs,proto3" json:"latency_ms,omitempty"`
+	AvgLatencyMs        int64                  `protobuf:"varint,5,opt,name=avg_latency_ms,json=avgLatencyMs,proto3" json:"avg_latency_ms,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *AgentHealth) Reset() {
+	*x = AgentHealth{}
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AgentHealth) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentHealth) ProtoMessage() {}
+
+func (x *AgentHealth) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentHealth.ProtoReflect.Descriptor instead.
+func (*AgentHealth) Descriptor() ([]byte, []int) {
+	return file_api_gateway_v1_gateway_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *AgentHealth) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *AgentHealth) GetCritical() bool {
+	if x != nil {
+		return x.Critical
+	}
+	return false
+}
+
+func (x *AgentHealth) GetConsecutiveFailures() int32 {
+	if x != nil {
+		return x.ConsecutiveFailures
+	}
+	return 0
+}
+
+func (x *AgentHealth) GetLatencyMs() int64 {
+	if x != nil {
+		return x.LatencyMs
+	}
+	return 0
+}
+
+func (x *AgentHealth) GetAvgLatencyMs() int64 {
+	if x != nil {
+		return x.AvgLatencyMs
+	}
+	return 0
+}
+
+var File_api_gateway_v1_gateway_proto protoreflect.FileDescriptor
+
+const file_api_gateway_v1_gateway_proto_rawDesc = "" +
+	"\n" +
+	"\x1capi/gateway/v1/gateway.proto\x12\x12maravia.gateway.v1\"\xb6\x01\n" +
+	"\vChatRequest\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x02 \x01(\x03R\tsessionId\x12\x1d\n" +
+	"\n" +
+	"id_empresa\x18\x03 \x01(\x03R\tidEmpresa\x12\x17\n" +
+	"\aapi_key\x18\x04 \x01(\tR\x06apiKey\x126\n" +
+	"\x06config\x18\x05 \x01(\v2\x1e.maravia.gateway.v1.ChatConfigR\x06config\"\xae\x04\n" +
+	"\n" +
+	"ChatConfig\x12\x1d\n" +
+	"\n" +
+	"nombre_bot\x18\x01 \x01(\tR\tnombreBot\x12\x1c\n" +
+	"\tmodalidad\x18\x02 \x01(\tR\tmodalidad\x12!\n" +
+	"\ffrase_saludo\x18\x03 \x01(\tR\vfraseSaludo\x12%\n" +
+	"\x0earchivo_saludo\x18\x04 \x01(\tR\rarchivoSaludo\x12\"\n" +
+	"\fpersonalidad\x18\x05 \x01(\tR\fpersonalidad\x12\x1b\n" +
+	"\tfrase_des\x18\x06 \x01(\tR\bfraseDes\x12\"\n" +
+	"\rfrase_no_sabe\x18\a \x01(\tR\vfraseNoSabe\x12%\n" +
+	"\x0ecorreo_usuario\x18\b \x01(\tR\rcorreoUsuario\x122\n" +
+	"\x15duracion_cita_minutos\x18\t \x01(\x05R\x13duracionCitaMinutos\x12\x14\n" +
+	"\x05slots\x18\n" +
+	" \x01(\x05R\x05slots\x12,\n" +
+	"\x0fagendar_usuario\x18\v \x01(\bH\x00R\x0eagendarUsuario\x88\x01\x01\x12.\n" +
+	"\x10agendar_sucursal\x18\f \x01(\bH\x01R\x0fagendarSucursal\x88\x01\x01\x12\x1d\n" +
+	"\n" +
+	"usuario_id\x18\r \x01(\x03R\tusuarioId\x12\x1d\n" +
+	"\n" +
+	"id_chatbot\x18\x0e \x01(\x03R\tidChatbotB\x12\n" +
+	"\x10_agendar_usuarioB\x13\n" +
+	"\x11_agendar_sucursal\"\x81\x01\n" +
+	"\fChatResponse\x12\x14\n" +
+	"\x05reply\x18\x01 \x01(\tR\x05reply\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x02 \x01(\x03R\tsessionId\x12\x1d\n" +
+	"\n" +
+	"agent_used\x18\x03 \x01(\tR\tagentUsed\x12\x15\n" +
+	"\x03url\x18\x04 \x01(\tH\x00R\x03url\x88\x01\x01B\x06\n" +
+	"\x04_url\"\x90\x01\n" +
+	"\tChatEvent\x12@\n" +
+	"\n" +
+	"processing\x18\x01 \x01(\v2\x1e.maravia.gateway.v1.ProcessingH\x00R\n" +
+	"processing\x128\n" +
+	"\x05reply\x18\x02 \x01(\v2 .maravia.gateway.v1.ChatResponseH\x00R\x05replyB\a\n" +
+	"\x05event\"A\n" +
+	"\n" +
+	"Processing\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\x12\x14\n" +
+	"\x05agent\x18\x02 \x01(\tR\x05agent\"\x0f\n" +
+	"\rHealthRequest\"\xe2\x01\n" +
+	"\x0eHealthResponse\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12\x14\n" +
+	"\x05ready\x18\x02 \x01(\bR\x05ready\x12F\n" +
+	"\x06agents\x18\x03 \x03(\v2..maravia.gateway.v1.HealthResponse.AgentsEntryR\x06agents\x1aZ\n" +
+	"\vAgentsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x125\n" +
+	"\x05value\x18\x02 \x01(\v2\x1f.maravia.gateway.v1.AgentHealthR\x05value:\x028\x01\"\xb9\x01\n" +
+	"\vAgentHealth\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12\x1a\n" +
+	"\bcritical\x18\x02 \x01(\bR\bcritical\x121\n" +
+	"\x14consecutive_failures\x18\x03 \x01(\x05R\x13consecutiveFailures\x12\x1d\n" +
+	"\n" +
+	"latency_ms\x18\x04 \x01(\x03R\tlatencyMs\x12$\n" +
+	"\x0eavg_latency_ms\x18\x05 \x01(\x03R\favgLatencyMs2\xfc\x01\n" +
+	"\x0eGatewayService\x12I\n" +
+	"\x04Chat\x12\x1f.maravia.gateway.v1.ChatRequest\x1a .maravia.gateway.v1.ChatResponse\x12N\n" +
+	"\n" +
+	"ChatStream\x12\x1f.maravia.gateway.v1.ChatRequest\x1a\x1d.maravia.gateway.v1.ChatEvent0\x01\x12O\n" +
+	"\x06Health\x12!.maravia.gateway.v1.HealthRequest\x1a\".maravia.gateway.v1.HealthResponseB\"Z gateway/api/gateway/v1;gatewayv1b\x06proto3"
+
+var (
+	file_api_gateway_v1_gateway_proto_rawDescOnce sync.Once
+	file_api_gateway_v1_gateway_proto_rawDescData []byte
+)
+
+func file_api_gateway_v1_gateway_proto_rawDescGZIP() []byte {
+	file_api_gateway_v1_gateway_proto_rawDescOnce.Do(func() {
+		file_api_gateway_v1_gateway_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_gateway_v1_gateway_proto_rawDesc), len(file_api_gateway_v1_gateway_proto_rawDesc)))
+	})
+	return file_api_gateway_v1_gateway_proto_rawDescData
+}
+
+var file_api_gateway_v1_gateway_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_api_gateway_v1_gateway_proto_goTypes = []any{
+	(*ChatRequest)(nil),    // 0: maravia.gateway.v1.ChatRequest
+	(*ChatConfig)(nil),     // 1: maravia.gateway.v1.ChatConfig
+	(*ChatResponse)(nil),   // 2: maravia.gateway.v1.ChatResponse
+	(*ChatEvent)(nil),      // 3: maravia.gateway.v1.ChatEvent
+	(*Processing)(nil),     // 4: maravia.gateway.v1.Processing
+	(*HealthRequest)(nil),  // 5: maravia.gateway.v1.HealthRequest
+	(*HealthResponse)(nil), // 6: maravia.gateway.v1.HealthResponse
+	(*AgentHealth)(nil),    // 7: maravia.gateway.v1.AgentHealth
+	nil,                    // 8: maravia.gateway.v1.HealthResponse.AgentsEntry
+}
+var file_api_gateway_v1_gateway_proto_depIdxs = []int32{
+	1, // 0: maravia.gateway.v1.ChatRequest.config:type_name -> maravia.gateway.v1.ChatConfig
+	4, // 1: maravia.gateway.v1.ChatEvent.processing:type_name -> maravia.gateway.v1.Processing
+	2, // 2: maravia.gateway.v1.ChatEvent.reply:type_name -> maravia.gateway.v1.ChatResponse
+	8, // 3: maravia.gateway.v1.HealthResponse.agents:type_name -> maravia.gateway.v1.HealthResponse.AgentsEntry
+	7, // 4: maravia.gateway.v1.HealthResponse.AgentsEntry.value:type_name -> maravia.gateway.v1.AgentHealth
+	0, // 5: maravia.gateway.v1.GatewayService.Chat:input_type -> maravia.gateway.v1.ChatRequest
+	0, // 6: maravia.gateway.v1.GatewayService.ChatStream:input_type -> maravia.gateway.v1.ChatRequest
+	5, // 7: maravia.gateway.v1.GatewayService.Health:input_type -> maravia.gateway.v1.HealthRequest
+	2, // 8: maravia.gateway.v1.GatewayService.Chat:output_type -> maravia.gateway.v1.ChatResponse
+	3, // 9: maravia.gateway.v1.GatewayService.ChatStream:output_type -> maravia.gateway.v1.ChatEvent
+	6, // 10: maravia.gateway.v1.GatewayService.Health:output_type -> maravia.gateway.v1.HealthResponse
+	8, // [8:11] is the sub-list for method output_type
+	5, // [5:8] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_api_gateway_v1_gateway_proto_init() }
+func file_api_gateway_v1_gateway_proto_init() {
+	if File_api_gateway_v1_gateway_proto != nil {
+		return
+	}
+	file_api_gateway_v1_gateway_proto_msgTypes[1].OneofWrappers = []any{}
+	file_api_gateway_v1_gateway_proto_msgTypes[2].OneofWrappers = []any{}
+	file_api_gateway_v1_gateway_proto_msgTypes[3].OneofWrappers = []any{
+		(*ChatEvent_Processing)(nil),
+		(*ChatEvent_Reply)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_gateway_v1_gateway_proto_rawDesc), len(file_api_gateway_v1_gateway_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_gateway_v1_gateway_proto_goTypes,
+		DependencyIndexes: file_api_gateway_v1_gateway_proto_depIdxs,
+		MessageInfos:      file_api_gateway_v1_gateway_proto_msgTypes,
+	}.Build()
+	File_api_gateway_v1_gateway_proto = out.File
+	file_api_gateway_v1_gateway_proto_goTypes = nil
+	file_api_gateway_v1_gateway_proto_depIdxs = nil
+}
@@ -0,0 +1,4 @@
+// Package gatewayv1 contiene el contrato gRPC del gateway (gateway.proto) y el codigo generado.
+package gatewayv1
+
+//go:generate protoc --go_out=../../.. --go_opt=paths=source_relative --go-grpc_out=../../.. --go-grpc_opt=paths=source_relative -I ../../.. api/gateway/v1/gateway.proto
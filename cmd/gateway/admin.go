@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"gateway/internal/config"
+	"gateway/internal/handler"
+	"gateway/internal/middleware"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// opsHandlers agrupa los endpoints operativos: van al listener admin o, si ADMIN_ADDR esta vacio,
+// al router publico como antes.
+type opsHandlers struct {
+	health   *handler.HealthHandler
+	stats    http.Handler
+	logLevel *handler.LogLevelHandler     // nil si ADMIN_TOKEN esta vacio
+	webhooks *handler.WebhookAdminHandler // nil si ADMIN_TOKEN esta vacio o no hay dispatcher
+	token    string
+}
+
+// mount registra /health, /metrics, /debug/stats y /admin (log-level, webhooks) en r. Con token,
+// /debug/stats (trafico por id_empresa y estado de los breakers) exige el mismo Bearer que /admin.
+func (o opsHandlers) mount(r chi.Router) {
+	r.Get("/health", o.health.ServeHTTP)
+	r.Handle("/metrics", handler.MetricsHandler())
+	if o.token != "" {
+		r.With(middleware.AdminAuth(o.token)).Handle("/debug/stats", o.stats)
+	} else {
+		r.Handle("/debug/stats", o.stats)
+	}
+	if o.logLevel == nil && o.webhooks == nil {
+		return
+	}
+	r.Route("/admin", func(ar chi.Router) {
+		ar.Use(middleware.AdminAuth(o.token))
+		if o.logLevel != nil {
+			ar.Get("/log-level", o.logLevel.Get)
+			ar.Put("/log-level", o.logLevel.Set)
+			ar.Post("/log-level/overrides", o.logLevel.AddOverride)
+			ar.Delete("/log-level/overrides", o.logLevel.RemoveOverride)
+		}
+		if o.webhooks != nil {
+			ar.Get("/webhooks/dead-letters", o.webhooks.List)
+			ar.Post("/webhooks/dead-letters/redeliver", o.webhooks.RedeliverAll)
+			ar.Post("/webhooks/dead-letters/{id}/redeliver", o.webhooks.Redeliver)
+		}
+	})
+}
+
+// newAdminServer builds the operational listener: ops endpoints, probes, pprof and runtime diagnostics.
+// Con ADMIN_TOKEN definido, /debug/* (stats, pprof, goroutines, buildinfo, config) exige el mismo
+// Bearer que /admin; /health, /metrics, /livez y /readyz no.
+func newAdminServer(cfg *config.Config, ops opsHandlers, agents handler.AgentLister, startedAt time.Time) *http.Server {
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Get("/livez", ops.health.Live)
+	r.Get("/readyz", ops.health.Ready)
+	ops.mount(r)
+
+	r.Group(func(dr chi.Router) {
+		if cfg.AdminToken != "" {
+			dr.Use(middleware.AdminAuth(cfg.AdminToken))
+		}
+		dr.Handle("/debug/buildinfo", handler.BuildInfoHandler(startedAt))
+		dr.Handle("/debug/config", handler.ConfigHandler(cfg, agents))
+		dr.Handle("/debug/goroutines", handler.GoroutinesHandler())
+		dr.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		dr.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		dr.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		dr.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		dr.HandleFunc("/debug/pprof/*", pprof.Index) // heap, goroutine, block, mutex, allocs...
+	})
+
+	// Sin WriteTimeout: /debug/pprof/profile y /trace escriben durante ?seconds=N.
+	return &http.Server{
+		Addr:              cfg.AdminAddr,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gateway/internal/channel"
+	"gateway/internal/channel/telegram"
+	"gateway/internal/channel/whatsapp"
+	"gateway/internal/config"
+	"gateway/internal/handler"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// channels agrupa los dispatchers de los canales de mensajeria habilitados (para el shutdown).
+type channels []*channel.Dispatcher
+
+// mountChannels registers the webhooks of the enabled messaging channels on r.
+func mountChannels(r chi.Router, cfg *config.Config, chat *handler.ChatHandler, m channel.Metrics) (channels, error) {
+	opts := channel.Options{
+		Concurrency: cfg.ChannelConcurrency,
+		MaxPending:  cfg.ChannelMaxPending,
+		Timeout:     time.Duration(cfg.ChannelTimeoutSec) * time.Second,
+	}
+	var out channels
+	if cfg.WhatsAppEnabled {
+		tenants, err := whatsapp.LoadTenants(cfg.WhatsAppTenantsFile)
+		if err != nil {
+			return nil, err
+		}
+		d := channel.NewDispatcher(whatsapp.Name, opts, m)
+		client := whatsapp.NewClient(cfg.WhatsAppAPIBaseURL, time.Duration(cfg.WhatsAppSendTimeoutSec)*time.Second)
+		wa, err := whatsapp.NewHandler(chat, tenants, client, d, whatsapp.Options{
+			VerifyToken: cfg.WhatsAppVerifyToken,
+			AppSecret:   cfg.WhatsAppAppSecret,
+		})
+		if err != nil {
+			return nil, err
+		}
+		r.Get("/webhooks/whatsapp", wa.Verify)
+		r.Post("/webhooks/whatsapp", wa.ServeHTTP)
+		slog.Info("whatsapp channel enabled", "numbers", len(tenants))
+		out = append(out, d)
+	}
+	if cfg.TelegramEnabled {
+		bots, err := telegram.LoadBots(cfg.TelegramBotsFile)
+		if err != nil {
+			return nil, err
+		}
+		d := channel.NewDispatcher(telegram.Name, opts, m)
+		client := telegram.NewClient(cfg.TelegramAPIBaseURL, time.Duration(cfg.TelegramSendTimeoutSec)*time.Second)
+		tg, err := telegram.NewHandler(chat, bots, client, d)
+		if err != nil {
+			return nil, err
+		}
+		r.Post("/webhooks/telegram/{bot}", tg.ServeHTTP)
+		slog.Info("telegram channel enabled", "bots", len(bots))
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// Shutdown waits for the queued messages of every channel until ctx expires.
+func (cs channels) Shutdown(ctx context.Context) error {
+	var first error
+	for _, d := range cs {
+		if err := d.Shutdown(ctx); err != nil && first == nil {
+			first = fmt.Errorf("channels: %w", err)
+		}
+	}
+	return first
+}
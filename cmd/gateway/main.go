@@ -1,184 +1,669 @@
-package main
-
-import (
-	"context"
-	"fmt"
-	"log/slog"
-	"net/http"
-	"os"
-	"os/signal"
-	"runtime"
-	"strconv"
-	"strings"
-	"syscall"
-	"time"
-
-	"gateway/internal/agent"
-	"gateway/internal/config"
-	"gateway/internal/handler"
-	"gateway/internal/metrics"
-	"gateway/internal/middleware"
-	"gateway/internal/proxy"
-
-	"github.com/go-chi/chi/v5"
-)
-
-func main() {
-	// Cargar config primero para poder usar LOG_LEVEL al crear el logger.
-	cfg, err := config.Load()
-	if err != nil {
-		// Sin logger aun; usar uno minimo para reportar el error.
-		bootstrap := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
-		bootstrap.Error("load config", "err", err)
-		os.Exit(1)
-	}
-
-	level := parseLogLevel(cfg.LogLevel)
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: level,
-		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
-			if a.Key == slog.TimeKey {
-				// Formato legible sin nanosegundos: "2026-02-20 23:56:28"
-				a.Value = slog.StringValue(a.Value.Time().UTC().Format(time.DateTime))
-			}
-			return a
-		},
-	}))
-	slog.SetDefault(logger)
-
-	reg, err := agent.NewRegistryFromEnv()
-	if err != nil {
-		slog.Error("agent registry", "err", err)
-		os.Exit(1)
-	}
-
-	agentTimeout := time.Duration(cfg.AgentTimeoutSec) * time.Second
-	invoker := proxy.NewInvoker(agentTimeout, reg)
-
-	chatHandler := &handler.ChatHandler{
-		Caller:       invoker,
-		Router:       agent.ModalidadToAgent,
-		AgentTimeout: agentTimeout,
-		Metrics:      metrics.NewRecorder(),
-	}
-	healthHandler := handler.NewHealthHandler(reg)
-
-	r := chi.NewRouter()
-	r.Use(middleware.RequestID)
-	r.Use(middleware.Logger)
-	r.Use(middleware.CORS(cfg.CORSOrigins))
-
-	r.Post("/api/agent/chat", chatHandler.ServeHTTP)
-	r.Get("/health", healthHandler.ServeHTTP)
-	r.Handle("/metrics", handler.MetricsHandler())
-
-	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		_, _ = w.Write([]byte(`{"service":"MaravIA Gateway","status":"running","endpoints":{"/api/agent/chat":"POST","/health":"GET","/metrics":"GET"}}`))
-	})
-
-	const defaultPort = 8000
-	port := cfg.HTTPPort
-	if port <= 0 {
-		port = defaultPort
-	}
-	addr := ":" + strconv.Itoa(port)
-
-	// Timeouts: evitan slowloris y conexiones colgadas (valores desde config/env).
-	readHeaderTimeout := time.Duration(cfg.ReadHeaderTimeoutSec) * time.Second
-	readTimeout := time.Duration(cfg.ReadTimeoutSec) * time.Second
-	writeTimeout := time.Duration(cfg.WriteTimeoutSec) * time.Second
-	var idleTimeout time.Duration
-	if cfg.IdleTimeoutSec > 0 {
-		idleTimeout = time.Duration(cfg.IdleTimeoutSec) * time.Second
-	}
-
-	logStartup(cfg, reg, addr)
-
-	srv := &http.Server{
-		Addr:              addr,
-		Handler:           r,
-		ReadHeaderTimeout: readHeaderTimeout,
-		ReadTimeout:       readTimeout,
-		WriteTimeout:      writeTimeout,
-		IdleTimeout:       idleTimeout,
-	}
-	errCh := make(chan error, 1)
-	go func() {
-		slog.Info("listening", "addr", addr)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			errCh <- err
-		}
-	}()
-
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-
-	select {
-	case sig := <-sigChan:
-		slog.Info("signal received", "signal", sig.String())
-	case err := <-errCh:
-		slog.Error("server failed", "err", err)
-		os.Exit(1)
-	}
-
-	slog.Info("shutting down")
-	ctx, cancel := context.WithTimeout(context.Background(), agentTimeout+5*time.Second)
-	defer cancel()
-	if err := srv.Shutdown(ctx); err != nil {
-		slog.Error("shutdown", "err", err)
-		os.Exit(1)
-	}
-	slog.Info("stopped")
-}
-
-// logStartup imprime un banner con la config relevante del gateway al arrancar.
-func logStartup(cfg *config.Config, reg *agent.Registry, addr string) {
-	sep := "============================================================"
-	dash := "------------------------------------------------------------"
-	slog.Info(sep)
-	slog.Info("  INICIANDO GATEWAY - MaravIA")
-	slog.Info(sep)
-	slog.Info(fmt.Sprintf("  Host         : %s", addr))
-	slog.Info(fmt.Sprintf("  Go version   : %s", runtime.Version()))
-	slog.Info(fmt.Sprintf("  Log level    : %s", cfg.LogLevel))
-	slog.Info(fmt.Sprintf("  CORS origins : %s", cfg.CORSOrigins))
-	slog.Info(dash)
-	slog.Info("  Timeouts HTTP del servidor")
-	slog.Info(fmt.Sprintf("    ReadHeader  : %ds", cfg.ReadHeaderTimeoutSec))
-	slog.Info(fmt.Sprintf("    Read        : %ds", cfg.ReadTimeoutSec))
-	slog.Info(fmt.Sprintf("    Write       : %ds", cfg.WriteTimeoutSec))
-	slog.Info(fmt.Sprintf("    Idle        : %ds", cfg.IdleTimeoutSec))
-	slog.Info(fmt.Sprintf("  Timeout agentes : %ds", cfg.AgentTimeoutSec))
-	slog.Info(dash)
-	slog.Info("  Agentes (puntos de conexion)")
-	for _, a := range reg.All() {
-		status := "habilitado"
-		if !a.Enabled {
-			status = "DESHABILITADO"
-		}
-		slog.Info(fmt.Sprintf("    %-18s [%s] %s", a.Key, status, a.URL))
-	}
-	slog.Info(dash)
-	slog.Info("  Endpoints")
-	slog.Info("    POST /api/agent/chat")
-	slog.Info("    GET  /health")
-	slog.Info("    GET  /metrics")
-	slog.Info(sep)
-}
-
-// parseLogLevel convierte el string de env (debug, info, warn, error) a slog.Level. Valor desconocido -> info.
-func parseLogLevel(s string) slog.Level {
-	switch strings.ToLower(strings.TrimSpace(s)) {
-	case "debug":
-		return slog.LevelDebug
-	case "info":
-		return slog.LevelInfo
-	case "warn", "warning":
-		return slog.LevelWarn
-	case "error":
-		return slog.LevelError
-	default:
-		return slog.LevelInfo
-	}
-}
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"syscall"
+	"time"
+
+	"gateway/internal/agent"
+	"gateway/internal/config"
+	"gateway/internal/grpcapi"
+	"gateway/internal/guardrail"
+	"gateway/internal/handler"
+	"gateway/internal/health"
+	"gateway/internal/jobs"
+	"gateway/internal/logging"
+	"gateway/internal/mcp"
+	"gateway/internal/metrics"
+	"gateway/internal/middleware"
+	"gateway/internal/proxy"
+	"gateway/internal/stats"
+	"gateway/internal/svctoken"
+	"gateway/internal/tlsutil"
+	"gateway/internal/tracing"
+	"gateway/internal/transcript"
+	"gateway/internal/webhook"
+
+	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	startedAt := time.Now()
+	// Cargar config primero para poder usar LOG_LEVEL al crear el logger.
+	cfg, err := config.Load()
+	if err != nil {
+		// Sin logger aun; usar uno minimo para reportar el error.
+		bootstrap := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+		bootstrap.Error("load config", "err", err)
+		os.Exit(1)
+	}
+
+	// Nivel en caliente: logging.Controller filtra (LevelVar + overrides por tenant/sesion);
+	// el JSONHandler acepta todo desde debug.
+	level, _ := logging.ParseLevel(cfg.LogLevel)
+	logLevels := logging.NewController(level)
+	logOut := os.Stdout
+	if cfg.MCPStdio {
+		logOut = os.Stderr // stdout es el canal MCP
+	}
+	logger := slog.New(logging.NewHandler(tracing.NewLogHandler(slog.NewJSONHandler(logOut, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				// Formato legible sin nanosegundos: "2026-02-20 23:56:28"
+				a.Value = slog.StringValue(a.Value.Time().UTC().Format(time.DateTime))
+			}
+			return a
+		},
+	})), logLevels))
+	slog.SetDefault(logger)
+	notifyLogToggle(logLevels)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Enabled:     cfg.TracingEnabled,
+		Endpoint:    cfg.TracingEndpoint,
+		Insecure:    cfg.TracingInsecure,
+		SampleRatio: cfg.TracingSampleRatio,
+		ServiceName: cfg.TracingServiceName,
+		Version:     buildVersion(),
+	})
+	if err != nil {
+		slog.Error("tracing", "err", err)
+		os.Exit(1)
+	}
+
+	reg, err := agent.NewRegistryFromEnv()
+	if err != nil {
+		slog.Error("agent registry", "err", err)
+		os.Exit(1)
+	}
+
+	agentTimeout := time.Duration(cfg.AgentTimeoutSec) * time.Second
+	metricTenants, err := config.IntList(cfg.MetricsTenants)
+	if err != nil {
+		slog.Error("metrics tenants", "err", err)
+		os.Exit(1)
+	}
+	recorder := metrics.NewRecorder(metrics.TenantPolicy{Allow: metricTenants, Limit: cfg.MetricsTenantLimit})
+	statsCollector := stats.NewCollector(stats.Options{MaxTenants: cfg.StatsMaxTenants, TopTenants: cfg.StatsTopTenants})
+
+	tlsReload := time.Duration(cfg.TLSReloadSec) * time.Second
+	invokerOpts := []proxy.Option{proxy.WithMetrics(recorder), proxy.WithTLSReload(tlsReload)}
+	var signer *svctoken.Signer
+	if cfg.ServiceTokenKeys != "" {
+		keys, err := svctoken.LoadKeys(cfg.ServiceTokenAlg, cfg.ServiceTokenKeys)
+		if err != nil {
+			slog.Error("service token keys", "err", err)
+			os.Exit(1)
+		}
+		signer, err = svctoken.NewSigner(keys, cfg.ServiceTokenIssuer, time.Duration(cfg.ServiceTokenTTLSec)*time.Second)
+		if err != nil {
+			slog.Error("service token signer", "err", err)
+			os.Exit(1)
+		}
+		invokerOpts = append(invokerOpts, proxy.WithTokenSigner(signer))
+	}
+
+	invokerOpts = append(invokerOpts, proxy.WithHealthProbes(cfg.HealthBreakerTripFailures))
+	jobTimeout := time.Duration(cfg.JobTimeoutSec) * time.Second
+	if cfg.AsyncEnabled {
+		// Los jobs async pueden esperar al agente mas que AGENT_TIMEOUT.
+		invokerOpts = append(invokerOpts, proxy.WithLongCalls(jobTimeout))
+	}
+	invoker, err := proxy.NewInvoker(agentTimeout, reg, invokerOpts...)
+	if err != nil {
+		slog.Error("agent invoker", "err", err)
+		os.Exit(1)
+	}
+
+	pipeline, err := newGuardrails(cfg)
+	if err != nil {
+		slog.Error("guardrails", "err", err)
+		os.Exit(1)
+	}
+	var caller handler.AgentCaller = invoker
+	if !pipeline.Empty() {
+		caller = guardrail.NewCaller(invoker, pipeline, recorder)
+	}
+
+	chatHandler := &handler.ChatHandler{
+		Caller:       caller,
+		Router:       agent.ModalidadToAgent,
+		AgentTimeout: agentTimeout,
+		Metrics:      handler.MultiRecorder{recorder, statsCollector},
+		StrictConfig: cfg.ValidationStrictConfig,
+	}
+	transcripts, err := newTranscriptSink(cfg)
+	if err != nil {
+		slog.Error("transcript sink", "err", err)
+		os.Exit(1)
+	}
+	chatHandler.Transcripts = transcripts // nil si TRANSCRIPT_DIR esta vacio
+
+	// Contexto de tareas en segundo plano (monitor de salud); se cancela en el shutdown.
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	monitorOpts := health.Options{
+		Interval:    time.Duration(cfg.HealthProbeIntervalSec) * time.Second,
+		Timeout:     time.Duration(cfg.HealthProbeTimeoutSec) * time.Second,
+		HistorySize: cfg.HealthHistorySize,
+		Critical:    config.SplitList(cfg.HealthCriticalAgents),
+		TLSReload:   tlsReload,
+	}
+	if cfg.HealthBreakerTripFailures > 0 {
+		// Las probes alimentan los circuit breakers del invoker.
+		monitorOpts.Observer = invoker
+	}
+	monitor := health.NewMonitor(reg, monitorOpts)
+	go monitor.Run(bgCtx)
+	go logLevels.Run(bgCtx, time.Minute)
+	mcpServer := mcp.New(chatHandler, reg, mcp.Options{Tokens: config.SplitList(cfg.MCPAuthTokens)})
+	if cfg.MCPStdio {
+		runMCPStdio(mcpServer, shutdownTracing, transcripts, agentTimeout+5*time.Second)
+		return
+	}
+	webhooks := webhook.NewDispatcher(webhook.Options{
+		Secrets:        config.SplitList(cfg.WebhookSecrets),
+		Timeout:        time.Duration(cfg.WebhookTimeoutSec) * time.Second,
+		MaxAge:         time.Duration(cfg.WebhookMaxAgeSec) * time.Second,
+		InitialBackoff: time.Duration(cfg.WebhookInitialBackoffSec) * time.Second,
+		MaxBackoff:     time.Duration(cfg.WebhookMaxBackoffSec) * time.Second,
+		Concurrency:    cfg.WebhookConcurrency,
+		MaxPending:     cfg.WebhookMaxPending,
+		MaxDeadLetters: cfg.WebhookMaxDeadLetters,
+		AllowPrivate:   cfg.WebhookAllowPrivateIPs,
+	}, recorder)
+	if cfg.WebhookSecrets == "" {
+		slog.Warn("WEBHOOK_SECRETS empty: callbacks are sent without signature")
+	}
+	var jobRunner *jobs.Runner
+	if cfg.AsyncEnabled {
+		jobStore := jobs.NewStore(cfg.JobMaxJobs, time.Duration(cfg.JobTTLSec)*time.Second)
+		go jobStore.Run(bgCtx, time.Minute)
+		jobRunner = jobs.NewRunner(jobStore, jobTimeout, webhooks)
+		chatHandler.Async = &handler.AsyncOptions{
+			Jobs:          jobRunner,
+			Timeout:       jobTimeout,
+			CallbackHosts: config.SplitList(cfg.JobCallbackAllowedHosts),
+		}
+	}
+	healthHandler := handler.NewHealthHandler(monitor)
+
+	accessLog, err := middleware.NewLogger(middleware.LoggerOptions{
+		TrustedProxies: config.SplitList(cfg.TrustedProxies),
+		ExcludePaths:   config.SplitList(cfg.LogExcludePaths),
+	})
+	if err != nil {
+		slog.Error("access log", "err", err)
+		os.Exit(1)
+	}
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(middleware.Tracing)
+	r.Use(accessLog)
+	cors, err := newCORS(cfg)
+	if err != nil {
+		slog.Error("cors policy", "err", err)
+		os.Exit(1)
+	}
+	r.Use(cors)
+
+	ops := opsHandlers{
+		health: healthHandler,
+		stats:  &handler.StatsHandler{Stats: statsCollector, Agents: invoker},
+		token:  cfg.AdminToken,
+	}
+	if cfg.AdminToken != "" {
+		ops.logLevel = &handler.LogLevelHandler{Levels: logLevels}
+		ops.webhooks = &handler.WebhookAdminHandler{Queue: webhooks}
+	}
+
+	r.Post("/api/agent/chat", chatHandler.ServeHTTP)
+	r.Post("/api/v2/agent/chat", chatHandler.ServeV2)
+	if jobRunner != nil {
+		r.Get("/api/agent/jobs/{id}", chatHandler.Job)
+	}
+	r.Post("/api/agent/chat/batch", handler.NewBatchHandler(chatHandler, handler.BatchOptions{
+		MaxItems:     cfg.BatchMaxItems,
+		MaxBodyBytes: int64(cfg.BatchMaxBodyBytes),
+		Concurrency:  cfg.BatchConcurrency,
+		MaxPerAgent:  cfg.BatchMaxPerAgent,
+		Timeout:      time.Duration(cfg.BatchTimeoutSec) * time.Second,
+	}).ServeHTTP)
+	var wsHandler *handler.WSHandler
+	if cfg.WSEnabled {
+		wsHandler = handler.NewWSHandler(chatHandler, handler.WSOptions{
+			OriginPatterns:    config.SplitList(cfg.WSAllowedOrigins),
+			MaxConnections:    cfg.WSMaxConnections,
+			MaxMessageBytes:   int64(cfg.WSMaxMessageBytes),
+			MaxInFlight:       cfg.WSMaxInFlight,
+			MaxMessagesPerMin: cfg.WSMaxMessagesPerMin,
+			AuthTimeout:       time.Duration(cfg.WSAuthTimeoutSec) * time.Second,
+			IdleTimeout:       time.Duration(cfg.WSIdleTimeoutSec) * time.Second,
+			PingInterval:      time.Duration(cfg.WSPingIntervalSec) * time.Second,
+		}, recorder)
+		r.Get("/api/agent/ws", wsHandler.ServeHTTP)
+	}
+	openAIHandler := &handler.OpenAIHandler{Chat: chatHandler}
+	r.Post("/v1/chat/completions", openAIHandler.ServeHTTP)
+	r.Get("/v1/models", openAIHandler.Models)
+	if cfg.MCPEnabled {
+		r.Post("/mcp", mcpServer.ServeHTTP)
+	}
+	chans, err := mountChannels(r, cfg, chatHandler, recorder)
+	if err != nil {
+		slog.Error("channels", "err", err)
+		os.Exit(1)
+	}
+	// livez/readyz quedan en el puerto publico: las probes de Kubernetes llegan a la IP del pod.
+	r.Get("/livez", healthHandler.Live)
+	r.Get("/readyz", healthHandler.Ready)
+	if cfg.AdminAddr == "" {
+		ops.mount(r)
+		if cfg.AdminToken == "" {
+			slog.Warn("ADMIN_ADDR empty and no ADMIN_TOKEN: /debug/stats is served on the public port without auth")
+		}
+	}
+	if signer != nil {
+		r.Handle("/.well-known/jwks.json", handler.JWKSHandler(signer))
+	}
+
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"service":"MaravIA Gateway","status":"running","endpoints":{"/api/agent/chat":"POST","/api/v2/agent/chat":"POST","/api/agent/chat/batch":"POST","/api/agent/jobs/{id}":"GET","/api/agent/ws":"GET (WebSocket)","/v1/chat/completions":"POST","/v1/models":"GET","/mcp":"POST (MCP)","gateway.v1.GatewayService":"gRPC (GRPC_PORT)","/livez":"GET","/readyz":"GET"}}`))
+	})
+
+	const defaultPort = 8000
+	port := cfg.HTTPPort
+	if port <= 0 {
+		port = defaultPort
+	}
+	addr := ":" + strconv.Itoa(port)
+
+	// Timeouts: evitan slowloris y conexiones colgadas (valores desde config/env).
+	readHeaderTimeout := time.Duration(cfg.ReadHeaderTimeoutSec) * time.Second
+	readTimeout := time.Duration(cfg.ReadTimeoutSec) * time.Second
+	writeTimeout := time.Duration(cfg.WriteTimeoutSec) * time.Second
+	var idleTimeout time.Duration
+	if cfg.IdleTimeoutSec > 0 {
+		idleTimeout = time.Duration(cfg.IdleTimeoutSec) * time.Second
+	}
+
+	logStartup(cfg, reg, addr, signer)
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           r,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+	var tlsCfg *tls.Config
+	if cfg.TLSEnabled() {
+		tlsCfg, err = tlsutil.ServerConfig(cfg.TLSCertFile, cfg.TLSKeyFile, tlsReload)
+		if err != nil {
+			slog.Error("tls config", "err", err)
+			os.Exit(1)
+		}
+		srv.TLSConfig = tlsCfg
+	}
+	errCh := make(chan error, 3)
+	var grpcSrv *grpc.Server
+	if cfg.GRPCPort > 0 {
+		grpcAddr := ":" + strconv.Itoa(cfg.GRPCPort)
+		lis, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			slog.Error("grpc listener", "err", err)
+			os.Exit(1)
+		}
+		grpcSrv = grpcapi.New(chatHandler, chatHandler.Router, monitor, grpcapi.Options{
+			Tokens:     config.SplitList(cfg.GRPCAuthTokens),
+			Reflection: cfg.GRPCReflection,
+			TLS:        tlsCfg, // mismo cert (y recarga) que HTTPS
+		})
+		go func() {
+			slog.Info("grpc listening", "addr", grpcAddr, "tls", tlsCfg != nil, "reflection", cfg.GRPCReflection)
+			if err := grpcSrv.Serve(lis); err != nil {
+				errCh <- fmt.Errorf("grpc listener: %w", err)
+			}
+		}()
+	}
+	var adminSrv *http.Server
+	if cfg.AdminAddr != "" {
+		adminSrv = newAdminServer(cfg, ops, reg, startedAt)
+		go func() {
+			slog.Info("admin listening", "addr", cfg.AdminAddr)
+			if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				errCh <- fmt.Errorf("admin listener: %w", err)
+			}
+		}()
+	}
+	go func() {
+		slog.Info("listening", "addr", addr, "tls", cfg.TLSEnabled())
+		var err error
+		if cfg.TLSEnabled() {
+			// Cert y key vienen de TLSConfig.GetCertificate (recarga en caliente).
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			errCh <- err
+		}
+	}()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case sig := <-sigChan:
+		slog.Info("signal received", "signal", sig.String())
+	case err := <-errCh:
+		slog.Error("server failed", "err", err)
+		os.Exit(1)
+	}
+
+	slog.Info("shutting down")
+	stopBackground()
+	ctx, cancel := context.WithTimeout(context.Background(), agentTimeout+5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Error("shutdown", "err", err)
+		os.Exit(1)
+	}
+	if grpcSrv != nil {
+		// GracefulStop espera los RPC en curso; si vence ctx se cortan.
+		done := make(chan struct{})
+		go func() {
+			grpcSrv.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			slog.Warn("grpc shutdown", "err", ctx.Err())
+			grpcSrv.Stop()
+		}
+	}
+	if wsHandler != nil {
+		// Las conexiones WebSocket (hijacked) no las espera srv.Shutdown: respuestas pendientes y cierre 1001.
+		if err := wsHandler.Shutdown(ctx); err != nil {
+			slog.Warn("ws shutdown", "err", err)
+		}
+	}
+	// Mensajes de WhatsApp y Telegram ya aceptados (el proveedor recibio 200): se espera la respuesta del agente y el envio.
+	if err := chans.Shutdown(ctx); err != nil {
+		slog.Warn("channels shutdown", "err", err)
+	}
+	if jobRunner != nil {
+		// Jobs async en curso: se esperan con el mismo plazo; al vencer se cancelan (failed + callback).
+		if err := jobRunner.Shutdown(ctx); err != nil {
+			slog.Warn("jobs shutdown", "err", err)
+		}
+	}
+	// Despues de los jobs: sus callbacks ya estan encolados. Las entregas esperando reintento pasan a dead-letter.
+	if err := webhooks.Shutdown(ctx); err != nil {
+		slog.Warn("webhooks shutdown", "err", err)
+	}
+	if adminSrv != nil {
+		// Despues del publico: /metrics y pprof siguen disponibles mientras drenan los requests.
+		if err := adminSrv.Shutdown(ctx); err != nil {
+			slog.Warn("admin shutdown", "err", err)
+		}
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Warn("tracing shutdown", "err", err)
+	}
+	if transcripts != nil {
+		if err := transcripts.Close(); err != nil {
+			slog.Warn("transcript close", "err", err)
+		}
+	}
+	slog.Info("stopped")
+}
+
+// buildVersion returns the module version embedded by the Go toolchain ("(devel)" en builds locales).
+func buildVersion() string {
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "" {
+		return bi.Main.Version
+	}
+	return "(devel)"
+}
+
+// logStartup imprime un banner con la config relevante del gateway al arrancar.
+func logStartup(cfg *config.Config, reg *agent.Registry, addr string, signer *svctoken.Signer) {
+	sep := "============================================================"
+	dash := "------------------------------------------------------------"
+	slog.Info(sep)
+	slog.Info("  INICIANDO GATEWAY - MaravIA")
+	slog.Info(sep)
+	slog.Info(fmt.Sprintf("  Host         : %s", addr))
+	slog.Info(fmt.Sprintf("  Version      : %s", buildVersion()))
+	slog.Info(fmt.Sprintf("  Go version   : %s", runtime.Version()))
+	slog.Info(fmt.Sprintf("  Log level    : %s", cfg.LogLevel))
+	slog.Info(fmt.Sprintf("  Access log   : excluye %q, proxies confiables %q", cfg.LogExcludePaths, cfg.TrustedProxies))
+	slog.Info(fmt.Sprintf("  CORS origins : %s", cfg.CORSOrigins))
+	for _, rt := range cfg.CORSRoutes {
+		slog.Info(fmt.Sprintf("    CORS %-8s: %s -> %s (credentials=%t)", rt.Name, rt.PathPrefix, rt.Origins, rt.AllowCredentials))
+	}
+	if cfg.TLSEnabled() {
+		slog.Info(fmt.Sprintf("  TLS          : %s (recarga cada %ds)", cfg.TLSCertFile, cfg.TLSReloadSec))
+	} else {
+		slog.Info("  TLS          : deshabilitado (HTTP plano)")
+	}
+	if cfg.AsyncEnabled {
+		slog.Info(fmt.Sprintf("  Jobs async   : timeout %ds, max %d, ttl %ds", cfg.JobTimeoutSec, cfg.JobMaxJobs, cfg.JobTTLSec))
+	} else {
+		slog.Info("  Jobs async   : deshabilitado")
+	}
+	if cfg.GRPCPort > 0 {
+		slog.Info(fmt.Sprintf("  gRPC         : :%d (auth=%t, reflection=%t)", cfg.GRPCPort, cfg.GRPCAuthTokens != "", cfg.GRPCReflection))
+	} else {
+		slog.Info("  gRPC         : deshabilitado")
+	}
+	if cfg.TracingEnabled {
+		slog.Info(fmt.Sprintf("  Tracing      : OTLP %s (sample=%.2f)", cfg.TracingEndpoint, cfg.TracingSampleRatio))
+	} else {
+		slog.Info("  Tracing      : deshabilitado (solo propagacion traceparent)")
+	}
+	if signer != nil {
+		slog.Info(fmt.Sprintf("  Service token: %s kid=%s ttl=%ds", cfg.ServiceTokenAlg, signer.ActiveKeyID(), cfg.ServiceTokenTTLSec))
+	} else {
+		slog.Info("  Service token: deshabilitado")
+	}
+	slog.Info(dash)
+	slog.Info("  Timeouts HTTP del servidor")
+	slog.Info(fmt.Sprintf("    ReadHeader  : %ds", cfg.ReadHeaderTimeoutSec))
+	slog.Info(fmt.Sprintf("    Read        : %ds", cfg.ReadTimeoutSec))
+	slog.Info(fmt.Sprintf("    Write       : %ds", cfg.WriteTimeoutSec))
+	slog.Info(fmt.Sprintf("    Idle        : %ds", cfg.IdleTimeoutSec))
+	slog.Info(fmt.Sprintf("  Timeout agentes : %ds", cfg.AgentTimeoutSec))
+	slog.Info(fmt.Sprintf("  Health probes   : cada %ds (timeout %ds), criticos: %q", cfg.HealthProbeIntervalSec, cfg.HealthProbeTimeoutSec, cfg.HealthCriticalAgents))
+	if cfg.TranscriptDir != "" {
+		slog.Info(fmt.Sprintf("  Transcripts     : %s (rota a %d MB / %dh, retencion %d dias)", cfg.TranscriptDir, cfg.TranscriptMaxSizeMB, cfg.TranscriptMaxAgeHours, cfg.TranscriptRetentionDays))
+	} else {
+		slog.Info("  Transcripts     : deshabilitado")
+	}
+	if cfg.HealthBreakerTripFailures > 0 {
+		slog.Info(fmt.Sprintf("  Probe breaker   : abre tras %d probes fallidas", cfg.HealthBreakerTripFailures))
+	} else {
+		slog.Info("  Probe breaker   : deshabilitado")
+	}
+	slog.Info(fmt.Sprintf("  Guardrails      : max_length=%q control_chars=%q deny=%q leak=%q url=%q",
+		cfg.GuardrailMaxLengthAction, cfg.GuardrailControlCharsAction, cfg.GuardrailDenyAction, cfg.GuardrailLeakAction, cfg.GuardrailURLAction))
+	slog.Info(dash)
+	slog.Info("  Agentes (puntos de conexion)")
+	for _, a := range reg.All() {
+		status := "habilitado"
+		if !a.Enabled {
+			status = "DESHABILITADO"
+		}
+		slog.Info(fmt.Sprintf("    %-18s [%s] %s", a.Key, status, config.RedactURL(a.URL)))
+		if !a.ForwardAPIKey {
+			slog.Info(fmt.Sprintf("    %-18s api_key: no se reenvia", ""))
+		}
+		if !a.TLS.IsZero() {
+			slog.Info(fmt.Sprintf("    %-18s tls: ca=%q cert=%q server_name=%q", "", a.TLS.CAFile, a.TLS.CertFile, a.TLS.ServerName))
+		}
+	}
+	slog.Info(dash)
+	slog.Info("  Endpoints")
+	slog.Info("    POST /api/agent/chat, POST /api/v2/agent/chat (mensajes enriquecidos)")
+	if cfg.WSEnabled {
+		slog.Info(fmt.Sprintf("    GET  /api/agent/ws (WebSocket, origins %q, max %d conexiones)", cfg.WSAllowedOrigins, cfg.WSMaxConnections))
+	}
+	slog.Info("    POST /v1/chat/completions, GET /v1/models (OpenAI)")
+	if cfg.MCPEnabled {
+		slog.Info(fmt.Sprintf("    POST /mcp (MCP, tools = agentes habilitados, auth=%t)", cfg.MCPAuthTokens != ""))
+	}
+	if cfg.WhatsAppEnabled {
+		slog.Info(fmt.Sprintf("    GET|POST /webhooks/whatsapp (WhatsApp Cloud API, %s)", cfg.WhatsAppTenantsFile))
+	}
+	if cfg.TelegramEnabled {
+		slog.Info(fmt.Sprintf("    POST /webhooks/telegram/{bot} (Telegram, %s)", cfg.TelegramBotsFile))
+	}
+	slog.Info("    GET  /livez")
+	slog.Info("    GET  /readyz")
+	if signer != nil {
+		slog.Info("    GET  /.well-known/jwks.json")
+	}
+	if cfg.AdminAddr != "" {
+		slog.Info(fmt.Sprintf("  Endpoints admin (%s)", cfg.AdminAddr))
+		slog.Info("    GET  /health, /livez, /readyz, /metrics, /debug/stats")
+		slog.Info("    GET  /debug/pprof/*, /debug/goroutines, /debug/buildinfo, /debug/config")
+	} else {
+		slog.Info("    GET  /health")
+		slog.Info("    GET  /metrics")
+		slog.Info("    GET  /debug/stats")
+	}
+	if cfg.AdminToken != "" {
+		slog.Info("    GET|PUT /admin/log-level, POST|DELETE /admin/log-level/overrides (Bearer ADMIN_TOKEN)")
+	}
+	slog.Info(sep)
+}
+
+// newCORS builds the CORS middleware from the default policy and the CORS_ROUTE_<NAME>_* overrides.
+func newCORS(cfg *config.Config) (func(http.Handler) http.Handler, error) {
+	def := middleware.CORSPolicy{
+		Name:             "default",
+		Origins:          config.SplitList(cfg.CORSOrigins),
+		AllowedHeaders:   config.SplitList(cfg.CORSAllowedHeaders),
+		ExposedHeaders:   config.SplitList(cfg.CORSExposedHeaders),
+		AllowCredentials: cfg.CORSAllowCredentials,
+		MaxAgeSec:        cfg.CORSMaxAgeSec,
+	}
+	routes := make([]middleware.CORSPolicy, 0, len(cfg.CORSRoutes))
+	for _, rt := range cfg.CORSRoutes {
+		routes = append(routes, middleware.CORSPolicy{
+			Name:             rt.Name,
+			PathPrefix:       rt.PathPrefix,
+			Origins:          config.SplitList(rt.Origins),
+			AllowedHeaders:   config.SplitList(rt.AllowedHeaders),
+			ExposedHeaders:   config.SplitList(rt.ExposedHeaders),
+			AllowCredentials: rt.AllowCredentials,
+			MaxAgeSec:        rt.MaxAgeSec,
+		})
+	}
+	return middleware.CORS(def, routes...)
+}
+
+// newTranscriptSink builds the transcript sink (archivos JSONL). nil si TRANSCRIPT_DIR esta vacio.
+func newTranscriptSink(cfg *config.Config) (transcript.Sink, error) {
+	if cfg.TranscriptDir == "" {
+		return nil, nil
+	}
+	var redact transcript.Redactor
+	if cfg.TranscriptRedactPatternsFile != "" {
+		patterns, err := guardrail.LoadPatterns(cfg.TranscriptRedactPatternsFile)
+		if err != nil {
+			return nil, err
+		}
+		// Misma regla de patrones que los guardrails, siempre en modo sanitize.
+		rule, err := guardrail.NewPatterns("transcript_redact", patterns, "[REDACTED]", guardrail.ActionSanitize)
+		if err != nil {
+			return nil, err
+		}
+		redact = func(text string) string {
+			out, _ := rule.Apply(text, guardrail.Target{})
+			return out
+		}
+	}
+	sink, err := transcript.NewFileSink(transcript.FileOptions{
+		Dir:       cfg.TranscriptDir,
+		MaxSize:   int64(cfg.TranscriptMaxSizeMB) << 20,
+		MaxAge:    time.Duration(cfg.TranscriptMaxAgeHours) * time.Hour,
+		Retention: time.Duration(cfg.TranscriptRetentionDays) * 24 * time.Hour,
+		Buffer:    cfg.TranscriptBuffer,
+		Redact:    redact,
+	})
+	if err != nil {
+		return nil, err // sin envolver el *FileSink nil en una interfaz no nil
+	}
+	return sink, nil
+}
+
+// newGuardrails builds the content guardrail pipeline. Reglas sin accion configurada quedan deshabilitadas.
+func newGuardrails(cfg *config.Config) (guardrail.Pipeline, error) {
+	var p guardrail.Pipeline
+
+	if action, ok, err := guardrail.ParseAction(cfg.GuardrailMaxLengthAction); err != nil {
+		return p, err
+	} else if ok {
+		p.Inbound = append(p.Inbound, guardrail.NewMaxLength(cfg.GuardrailMaxLength, action))
+	}
+	if action, ok, err := guardrail.ParseAction(cfg.GuardrailControlCharsAction); err != nil {
+		return p, err
+	} else if ok {
+		p.Inbound = append(p.Inbound, guardrail.NewControlChars(action))
+	}
+	if action, ok, err := guardrail.ParseAction(cfg.GuardrailDenyAction); err != nil {
+		return p, err
+	} else if ok && cfg.GuardrailDenyPatternsFile != "" {
+		patterns, err := guardrail.LoadPatterns(cfg.GuardrailDenyPatternsFile)
+		if err != nil {
+			return p, err
+		}
+		rule, err := guardrail.NewPatterns("deny_pattern", patterns, "", action)
+		if err != nil {
+			return p, err
+		}
+		p.Inbound = append(p.Inbound, rule)
+	}
+	if action, ok, err := guardrail.ParseAction(cfg.GuardrailLeakAction); err != nil {
+		return p, err
+	} else if ok && cfg.GuardrailLeakPatternsFile != "" {
+		patterns, err := guardrail.LoadPatterns(cfg.GuardrailLeakPatternsFile)
+		if err != nil {
+			return p, err
+		}
+		rule, err := guardrail.NewPatterns("leak_pattern", patterns, "[…]", action)
+		if err != nil {
+			return p, err
+		}
+		p.Reply = append(p.Reply, rule)
+	}
+	if action, ok, err := guardrail.ParseAction(cfg.GuardrailURLAction); err != nil {
+		return p, err
+	} else if ok {
+		perTenant := make(map[int][]string, len(cfg.GuardrailURLTenantDomains))
+		for id, d := range cfg.GuardrailURLTenantDomains {
+			perTenant[id] = config.SplitList(d)
+		}
+		p.URL = append(p.URL, guardrail.NewURLAllowlist(config.SplitList(cfg.GuardrailURLDomains), perTenant, action))
+	}
+	return p, nil
+}
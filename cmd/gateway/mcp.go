@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"gateway/internal/mcp"
+	"gateway/internal/transcript"
+)
+
+// runMCPStdio serves MCP over stdin/stdout (MCP_STDIO) hasta que el cliente cierra stdin o llega
+// SIGINT/SIGTERM. Sin listeners HTTP ni gRPC; los logs van a stderr.
+func runMCPStdio(srv *mcp.Server, shutdownTracing func(context.Context) error, transcripts transcript.Sink, grace time.Duration) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	slog.Info("mcp stdio serving")
+	if err := srv.ServeStdio(ctx, os.Stdin, os.Stdout); err != nil && !errors.Is(err, context.Canceled) {
+		slog.Error("mcp stdio", "err", err)
+	}
+
+	slog.Info("shutting down")
+	sctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	if err := shutdownTracing(sctx); err != nil {
+		slog.Warn("tracing shutdown", "err", err)
+	}
+	if transcripts != nil {
+		if err := transcripts.Close(); err != nil {
+			slog.Warn("transcript close", "err", err)
+		}
+	}
+	slog.Info("stopped")
+}
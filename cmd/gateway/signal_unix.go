@@ -0,0 +1,23 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"gateway/internal/logging"
+)
+
+// notifyLogToggle alterna el nivel de log entre debug y LOG_LEVEL con cada SIGUSR1
+// (kill -USR1 <pid>), sin reiniciar el gateway.
+func notifyLogToggle(c *logging.Controller) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1)
+	go func() {
+		for range ch {
+			c.Toggle()
+		}
+	}()
+}
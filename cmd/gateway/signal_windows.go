@@ -0,0 +1,8 @@
+//go:build windows
+
+package main
+
+import "gateway/internal/logging"
+
+// notifyLogToggle: Windows no tiene SIGUSR1; el nivel se cambia solo por /admin/log-level.
+func notifyLogToggle(*logging.Controller) {}
@@ -0,0 +1,194 @@
+// Command replay re-envia conversaciones grabadas a un agente o a un gateway y compara las respuestas.
+//
+// Entrada: JSONL, un intercambio por linea con el formato de ChatRequest (message, session_id,
+// id_empresa, api_key, config) mas la respuesta grabada opcional (reply, url, latency_ms).
+// Los archivos del transcript sink (TRANSCRIPT_DIR) sirven como entrada, pero no guardan api_key:
+// con -gateway (que la valida) hay que pasarla con -api-key.
+//
+// Uso:
+//
+//	replay -in conversaciones.jsonl -agent http://localhost:8002/api/chat -concurrency 4 -rate 5 -out report.json
+//	replay -in transcript.jsonl -gateway http://localhost:8000 -api-key $API_KEY -session-offset 900000000
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// headerFlags collects repeated -header "Name: value" flags.
+type headerFlags []string
+
+func (h *headerFlags) String() string     { return strings.Join(*h, ", ") }
+func (h *headerFlags) Set(v string) error { *h = append(*h, v); return nil }
+
+func main() {
+	var (
+		in            = flag.String("in", "-", "archivo JSONL con los intercambios grabados (- = stdin)")
+		agentURL      = flag.String("agent", "", "URL del agente (POST directo con el contrato AgentRequest)")
+		gatewayURL    = flag.String("gateway", "", "URL base del gateway (POST /api/agent/chat)")
+		out           = flag.String("out", "-", "archivo del reporte JSON (- = stdout)")
+		concurrency   = flag.Int("concurrency", 4, "requests en paralelo")
+		rate          = flag.Float64("rate", 0, "requests por segundo (0 = sin limite)")
+		timeout       = flag.Duration("timeout", 30*time.Second, "timeout por request")
+		sessionOffset = flag.Int("session-offset", 0, "suma este valor a session_id para no mezclar con sesiones reales")
+		limit         = flag.Int("limit", 0, "maximo de intercambios a enviar (0 = todos)")
+		apiKey        = flag.String("api-key", "", "api_key para todos los intercambios (reemplaza la grabada; el transcript no la guarda)")
+		headers       headerFlags
+	)
+	flag.Var(&headers, "header", "header extra \"Nombre: valor\" (repetible), ej. Authorization para el agente")
+	flag.Parse()
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+
+	if (*agentURL == "") == (*gatewayURL == "") {
+		fmt.Fprintln(os.Stderr, "replay: indicar exactamente uno de -agent o -gateway")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *concurrency < 1 {
+		*concurrency = 1
+	}
+
+	exchanges, err := readExchanges(*in, *limit)
+	if err != nil {
+		slog.Error("read input", "err", err)
+		os.Exit(1)
+	}
+	if len(exchanges) == 0 {
+		slog.Error("no exchanges to replay", "in", *in)
+		os.Exit(1)
+	}
+	missingKey := 0
+	for i := range exchanges {
+		if *apiKey != "" {
+			exchanges[i].ApiKey = *apiKey
+		}
+		if exchanges[i].ApiKey == "" {
+			missingKey++
+		}
+	}
+	if *gatewayURL != "" && missingKey > 0 {
+		slog.Warn("exchanges without api_key: the gateway will reject them with 400 (use -api-key)", "count", missingKey)
+	}
+
+	target, err := newTarget(*agentURL, *gatewayURL, *timeout, headers)
+	if err != nil {
+		slog.Error("target", "err", err)
+		os.Exit(2)
+	}
+
+	slog.Info("replay start", "exchanges", len(exchanges), "target", target.name(), "concurrency", *concurrency, "rate", *rate)
+	start := time.Now()
+	results := run(context.Background(), target, exchanges, *concurrency, *rate, *sessionOffset)
+	rep := buildReport(target.name(), exchanges, results, time.Since(start))
+
+	if err := writeReport(*out, rep); err != nil {
+		slog.Error("write report", "err", err)
+		os.Exit(1)
+	}
+	s := rep.Summary
+	slog.Info("replay done",
+		"total", s.Total,
+		"errors", s.Errors,
+		"exact_match_rate", fmt.Sprintf("%.3f", s.ExactMatchRate),
+		"mean_similarity", fmt.Sprintf("%.3f", s.MeanSimilarity),
+		"url_changed", s.URLChanged,
+		"p95_ms", s.Latency.P95,
+		"duration", rep.Duration,
+	)
+}
+
+// run envia los intercambios con concurrencia acotada y, si rate > 0, a ritmo constante.
+// Los resultados quedan en el mismo orden que la entrada.
+func run(ctx context.Context, t target, exchanges []exchange, concurrency int, rate float64, sessionOffset int) []result {
+	results := make([]result, len(exchanges))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				ex := exchanges[i]
+				ex.SessionID += sessionOffset
+				results[i] = t.send(ctx, ex)
+				results[i].Index = i
+			}
+		}()
+	}
+
+	var tick <-chan time.Time
+	if rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for i := range exchanges {
+		if tick != nil && i > 0 {
+			<-tick
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// readExchanges lee el JSONL de entrada. Lineas vacias se ignoran; una linea invalida corta con error.
+func readExchanges(path string, limit int) ([]exchange, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 4<<20) // mensajes largos
+	var out []exchange
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		ex, err := parseExchange([]byte(line))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		out = append(out, ex)
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+	}
+	return out, sc.Err()
+}
+
+func writeReport(path string, rep report) error {
+	var w io.Writer = os.Stdout
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(rep)
+}
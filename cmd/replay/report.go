@@ -0,0 +1,253 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gateway/internal/transcript"
+)
+
+const (
+	statusOK    = "ok"
+	statusError = "error"
+)
+
+// result is the outcome of replaying one exchange.
+type result struct {
+	Index      int     `json:"index"`
+	SessionID  int     `json:"session_id"`
+	IdEmpresa  int     `json:"id_empresa"`
+	Status     string  `json:"status"`
+	HTTPStatus int     `json:"http_status,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	AgentUsed  string  `json:"agent_used,omitempty"`
+	Reply      string  `json:"reply"`
+	URL        *string `json:"url"`
+	LatencyMs  int64   `json:"latency_ms"`
+
+	// Comparacion con lo grabado (solo si el intercambio traia reply).
+	RecordedOutcome   string   `json:"recorded_outcome,omitempty"`
+	RecordedReply     *string  `json:"recorded_reply,omitempty"`
+	RecordedURL       *string  `json:"recorded_url,omitempty"`
+	RecordedLatencyMs int64    `json:"recorded_latency_ms,omitempty"`
+	ExactMatch        *bool    `json:"exact_match,omitempty"`
+	Similarity        *float64 `json:"similarity,omitempty"`
+	URLChanged        *bool    `json:"url_changed,omitempty"`
+}
+
+func (r result) fail(err error) result {
+	r.Status = statusError
+	r.Error = err.Error()
+	return r
+}
+
+// latencyStats is a latency distribution in milliseconds.
+type latencyStats struct {
+	Count   int      `json:"count"`
+	Min     int64    `json:"min_ms"`
+	Mean    int64    `json:"mean_ms"`
+	P50     int64    `json:"p50_ms"`
+	P90     int64    `json:"p90_ms"`
+	P95     int64    `json:"p95_ms"`
+	P99     int64    `json:"p99_ms"`
+	Max     int64    `json:"max_ms"`
+	Buckets []bucket `json:"buckets"` // histograma: "<=100ms", "<=250ms", ..., ">10000ms"
+}
+
+// bucket is one histogram bin.
+type bucket struct {
+	Range string `json:"range"`
+	Count int    `json:"count"`
+}
+
+type summary struct {
+	Total            int           `json:"total"`
+	OK               int           `json:"ok"`
+	Errors           int           `json:"errors"`
+	Compared         int           `json:"compared"`          // respuestas ok con reply grabado del agente
+	RecordedFallback int           `json:"recorded_fallback"` // grabados con outcome != ok: no se comparan
+	ExactMatches     int           `json:"exact_matches"`
+	ExactMatchRate   float64       `json:"exact_match_rate"`
+	MeanSimilarity   float64       `json:"mean_similarity"`
+	SimilarityDist   []bucket      `json:"similarity_distribution"`
+	URLChanged       int           `json:"url_changed"`
+	ThroughputRPS    float64       `json:"throughput_rps"`
+	Latency          latencyStats  `json:"latency"`
+	RecordedLatency  *latencyStats `json:"recorded_latency,omitempty"`
+}
+
+type report struct {
+	Target   string    `json:"target"`
+	Started  time.Time `json:"started"`
+	Duration string    `json:"duration"`
+	Summary  summary   `json:"summary"`
+	Results  []result  `json:"results"`
+}
+
+var latencyBuckets = []int64{100, 250, 500, 1000, 2500, 5000, 10000}
+
+// buildReport compara cada resultado con lo grabado y agrega las distribuciones.
+func buildReport(targetName string, exchanges []exchange, results []result, elapsed time.Duration) report {
+	s := summary{
+		Total:          len(results),
+		SimilarityDist: []bucket{{Range: "<0.50"}, {Range: "0.50-0.80"}, {Range: "0.80-0.95"}, {Range: "0.95-1.00"}},
+	}
+	var newLat, recLat []int64
+	var simSum float64
+	for i := range results {
+		r := &results[i]
+		ex := exchanges[i]
+		r.RecordedOutcome = ex.Outcome
+		if ex.Reply != nil {
+			r.RecordedReply = ex.Reply
+			r.RecordedURL = ex.URL
+		}
+		if ex.LatencyMs > 0 {
+			r.RecordedLatencyMs = ex.LatencyMs
+			recLat = append(recLat, ex.LatencyMs)
+		}
+		if r.Status != statusOK {
+			s.Errors++
+			continue
+		}
+		s.OK++
+		newLat = append(newLat, r.LatencyMs)
+		if ex.Reply == nil {
+			continue
+		}
+		if ex.Outcome != "" && ex.Outcome != transcript.OutcomeOK {
+			// El reply grabado es un fallback del gateway, no una respuesta del agente.
+			s.RecordedFallback++
+			continue
+		}
+
+		s.Compared++
+		exact := strings.TrimSpace(r.Reply) == strings.TrimSpace(*ex.Reply)
+		sim := similarity(r.Reply, *ex.Reply)
+		urlChanged := !sameURL(r.URL, ex.URL)
+		r.ExactMatch, r.Similarity, r.URLChanged = &exact, &sim, &urlChanged
+		if exact {
+			s.ExactMatches++
+		}
+		if urlChanged {
+			s.URLChanged++
+		}
+		simSum += sim
+		switch {
+		case sim < 0.5:
+			s.SimilarityDist[0].Count++
+		case sim < 0.8:
+			s.SimilarityDist[1].Count++
+		case sim < 0.95:
+			s.SimilarityDist[2].Count++
+		default:
+			s.SimilarityDist[3].Count++
+		}
+	}
+	if s.Compared > 0 {
+		s.ExactMatchRate = round3(float64(s.ExactMatches) / float64(s.Compared))
+		s.MeanSimilarity = round3(simSum / float64(s.Compared))
+	}
+	if elapsed > 0 {
+		s.ThroughputRPS = round3(float64(s.Total) / elapsed.Seconds())
+	}
+	s.Latency = distribution(newLat)
+	if len(recLat) > 0 {
+		d := distribution(recLat)
+		s.RecordedLatency = &d
+	}
+	return report{
+		Target:   targetName,
+		Started:  time.Now().Add(-elapsed).UTC(),
+		Duration: elapsed.Round(time.Millisecond).String(),
+		Summary:  s,
+		Results:  results,
+	}
+}
+
+func distribution(ms []int64) latencyStats {
+	d := latencyStats{Count: len(ms), Buckets: make([]bucket, len(latencyBuckets)+1)}
+	for i, b := range latencyBuckets {
+		d.Buckets[i].Range = "<=" + strconv.FormatInt(b, 10) + "ms"
+	}
+	d.Buckets[len(latencyBuckets)].Range = ">" + strconv.FormatInt(latencyBuckets[len(latencyBuckets)-1], 10) + "ms"
+	if len(ms) == 0 {
+		return d
+	}
+	sorted := append([]int64(nil), ms...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var sum int64
+	for _, v := range sorted {
+		sum += v
+		i := sort.Search(len(latencyBuckets), func(i int) bool { return v <= latencyBuckets[i] })
+		d.Buckets[i].Count++ // i == len(latencyBuckets) cae en el bucket ">"
+	}
+	d.Min, d.Max = sorted[0], sorted[len(sorted)-1]
+	d.Mean = sum / int64(len(sorted))
+	d.P50 = percentile(sorted, 0.50)
+	d.P90 = percentile(sorted, 0.90)
+	d.P95 = percentile(sorted, 0.95)
+	d.P99 = percentile(sorted, 0.99)
+	return d
+}
+
+// percentile usa nearest-rank sobre un slice ordenado.
+func percentile(sorted []int64, p float64) int64 {
+	idx := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
+
+// similarity returns 1 - levenshtein/maxLen sobre el texto normalizado (minusculas, espacios colapsados).
+// 1 = identico, 0 = nada en comun.
+func similarity(a, b string) float64 {
+	ra, rb := []rune(normalize(a)), []rune(normalize(b))
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+	maxLen := len(ra)
+	if len(rb) > maxLen {
+		maxLen = len(rb)
+	}
+	return round3(1 - float64(levenshtein(ra, rb))/float64(maxLen))
+}
+
+func normalize(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), unicode.IsSpace), " ")
+}
+
+// levenshtein con dos filas (memoria O(len(b))).
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func sameURL(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func round3(f float64) float64 { return math.Round(f*1000) / 1000 }
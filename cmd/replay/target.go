@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"gateway/internal/domain"
+	"gateway/internal/handler"
+	"gateway/internal/proxy"
+)
+
+// exchange is one recorded conversation turn. Los campos del request usan el mismo JSON que
+// ChatRequest (y que proxy.AgentRequest); reply/url/latency_ms/outcome son la respuesta grabada, opcional.
+type exchange struct {
+	proxy.AgentRequest
+	Reply     *string `json:"reply"`
+	URL       *string `json:"url"`
+	LatencyMs int64   `json:"latency_ms"`
+	Outcome   string  `json:"outcome"` // transcript.Outcome*; vacio = se asume ok
+}
+
+func parseExchange(line []byte) (exchange, error) {
+	var ex exchange
+	if err := json.Unmarshal(line, &ex); err != nil {
+		return ex, err
+	}
+	if strings.TrimSpace(ex.Message) == "" {
+		return ex, fmt.Errorf("message is required")
+	}
+	return ex, nil
+}
+
+// target sends one exchange and returns the new reply.
+type target interface {
+	name() string
+	send(ctx context.Context, ex exchange) result
+}
+
+// httpTarget envia el request al agente (contrato AgentRequest) o al gateway (POST /api/agent/chat).
+// Ambos aceptan el mismo JSON; solo cambia la URL y la forma de la respuesta. El gateway responde
+// 200 tambien con sus fallbacks: se detectan por handler.HeaderFallbackReason y cuentan como error.
+type httpTarget struct {
+	url     string
+	gateway bool
+	client  *http.Client
+	headers http.Header
+}
+
+func newTarget(agentURL, gatewayURL string, timeout time.Duration, headers []string) (target, error) {
+	t := &httpTarget{
+		url:     agentURL,
+		client:  &http.Client{Timeout: timeout},
+		headers: make(http.Header),
+	}
+	if gatewayURL != "" {
+		t.url = strings.TrimRight(gatewayURL, "/") + "/api/agent/chat"
+		t.gateway = true
+	}
+	for _, h := range headers {
+		k, v, ok := strings.Cut(h, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid -header %q (expected \"Name: value\")", h)
+		}
+		t.headers.Add(strings.TrimSpace(k), strings.TrimSpace(v))
+	}
+	return t, nil
+}
+
+func (t *httpTarget) name() string {
+	if t.gateway {
+		return "gateway " + t.url
+	}
+	return "agent " + t.url
+}
+
+// replyBody cubre la respuesta del agente ({reply,url} o {messages}) y la del gateway (ChatResponse, ChatResponseV2).
+type replyBody struct {
+	proxy.AgentResponse
+	AgentUsed *string `json:"agent_used"`
+}
+
+func (t *httpTarget) send(ctx context.Context, ex exchange) result {
+	res := result{SessionID: ex.SessionID, IdEmpresa: ex.IdEmpresa}
+
+	raw, err := json.Marshal(ex.AgentRequest)
+	if err != nil {
+		return res.fail(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(raw))
+	if err != nil {
+		return res.fail(err)
+	}
+	req.Header = t.headers.Clone()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	start := time.Now()
+	resp, err := t.client.Do(req)
+	if err != nil {
+		res.LatencyMs = time.Since(start).Milliseconds()
+		return res.fail(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	res.LatencyMs = time.Since(start).Milliseconds()
+	res.HTTPStatus = resp.StatusCode
+	if err != nil {
+		return res.fail(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return res.fail(fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body))))
+	}
+
+	var out replyBody
+	if err := json.Unmarshal(body, &out); err != nil {
+		return res.fail(fmt.Errorf("decode response: %w", err))
+	}
+	res.Status = statusOK
+	msgs, _ := out.Normalize()
+	res.Reply, res.URL = domain.Flatten(msgs)
+	if out.AgentUsed != nil {
+		res.AgentUsed = *out.AgentUsed
+	}
+	if reason := resp.Header.Get(handler.HeaderFallbackReason); t.gateway && reason != "" {
+		return res.fail(fmt.Errorf("gateway fallback (%s): %s", reason, res.Reply))
+	}
+	return res
+}
@@ -1,26 +1,48 @@
-package agent
-
-import (
-	"strings"
-)
-
-// RouteFunc maps an n8n modalidad string to an agent key.
-// Returns empty string if not found.
-type RouteFunc func(modalidad string) (agentKey string)
-
-var modalidadMap = map[string]string{
-	"citas":          "cita",
-	"ventas":         "venta",
-	"reservas":       "reserva",
-	"citas y ventas": "citas_ventas",
-}
-
-// ModalidadToAgent maps n8n modalidad (valores fijos) a clave de agente.
-// Devuelve "" si la modalidad no se reconoce.
-func ModalidadToAgent(modalidad string) string {
-	m := strings.ToLower(strings.TrimSpace(modalidad))
-	if agent, ok := modalidadMap[m]; ok {
-		return agent
-	}
-	return ""
-}
+package agent
+
+import (
+	"sort"
+	"strings"
+)
+
+// RouteFunc maps an n8n modalidad string to an agent key.
+// Returns empty string if not found.
+type RouteFunc func(modalidad string) (agentKey string)
+
+var modalidadMap = map[string]string{
+	"citas":          "cita",
+	"ventas":         "venta",
+	"reservas":       "reserva",
+	"citas y ventas": "citas_ventas",
+}
+
+// ModalidadToAgent maps n8n modalidad (valores fijos) a clave de agente.
+// Devuelve "" si la modalidad no se reconoce.
+func ModalidadToAgent(modalidad string) string {
+	m := strings.ToLower(strings.TrimSpace(modalidad))
+	if agent, ok := modalidadMap[m]; ok {
+		return agent
+	}
+	return ""
+}
+
+// ModalidadFor returns the modalidad that routes to agentKey (inversa de ModalidadToAgent).
+func ModalidadFor(agentKey string) (string, bool) {
+	k := strings.ToLower(strings.TrimSpace(agentKey))
+	for m, a := range modalidadMap {
+		if a == k {
+			return m, true
+		}
+	}
+	return "", false
+}
+
+// RoutedAgents lists the agent keys reachable through some modalidad, sorted.
+func RoutedAgents() []string {
+	out := make([]string, 0, len(modalidadMap))
+	for _, a := range modalidadMap {
+		out = append(out, a)
+	}
+	sort.Strings(out)
+	return out
+}
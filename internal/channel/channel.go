@@ -0,0 +1,190 @@
+// Package channel tiene lo comun de los canales de mensajeria (WhatsApp, Telegram): el webhook del
+// proveedor responde de inmediato y la llamada al agente y el envio de la respuesta corren en
+// segundo plano, en orden por conversacion y sin procesar dos veces un mensaje reenviado.
+package channel
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gateway/internal/handler"
+	"gateway/internal/middleware"
+)
+
+// Resultados de un mensaje entrante (label "outcome" de gateway_channel_messages_total).
+const (
+	OutcomeDispatched = "dispatched"  // encolado para el agente
+	OutcomeDuplicate  = "duplicate"   // reintento del proveedor de un mensaje ya recibido
+	OutcomeDropped    = "dropped"     // cola llena o gateway apagandose
+	OutcomeIgnored    = "ignored"     // tenant desconocido, tipo no soportado, request invalido
+	OutcomeReplied    = "replied"     // respuesta enviada al usuario
+	OutcomeSendFailed = "send_failed" // el proveedor rechazo el envio
+)
+
+// ChatService is the chat path used for every inbound message (implemented by handler.ChatHandler).
+type ChatService interface {
+	ChatV2(ctx context.Context, req *handler.ChatRequest) handler.ChatResponseV2
+	Validate(req *handler.ChatRequest, lang string) *handler.ErrorResponse
+}
+
+// Metrics counts inbound messages per channel (implemented by metrics.Recorder).
+type Metrics interface {
+	RecordChannelMessage(channel, outcome string)
+}
+
+// Options configures a Dispatcher. Valores <= 0 toman los defaults.
+type Options struct {
+	Concurrency int           // mensajes procesandose a la vez (default 50)
+	MaxPending  int           // encolados + en curso (default 1000); excedente = dropped
+	Timeout     time.Duration // llamada al agente + envio de la respuesta (default 2m)
+	DedupeTTL   time.Duration // cuanto se recuerda el ID de un mensaje (default 1h)
+}
+
+// Dispatcher runs inbound messages in background.
+type Dispatcher struct {
+	name    string
+	opts    Options
+	metrics Metrics // nil = sin metricas
+	sem     chan struct{}
+	wg      sync.WaitGroup
+
+	mu        sync.Mutex
+	closed    bool
+	pending   int
+	tails     map[string]chan struct{} // ultimo mensaje encolado por conversacion
+	seen      map[string]time.Time     // IDs de mensajes ya recibidos
+	lastPurge time.Time
+}
+
+// NewDispatcher creates a dispatcher for the channel name ("whatsapp"). m puede ser nil.
+func NewDispatcher(name string, opts Options, m Metrics) *Dispatcher {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 50
+	}
+	if opts.MaxPending <= 0 {
+		opts.MaxPending = 1000
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 2 * time.Minute
+	}
+	if opts.DedupeTTL <= 0 {
+		opts.DedupeTTL = time.Hour
+	}
+	return &Dispatcher{
+		name:      name,
+		opts:      opts,
+		metrics:   m,
+		sem:       make(chan struct{}, opts.Concurrency),
+		tails:     make(map[string]chan struct{}),
+		seen:      make(map[string]time.Time),
+		lastPurge: time.Now(),
+	}
+}
+
+// Dispatch queues fn for the conversation key. Los mensajes de una misma conversacion se procesan
+// en el orden de llegada. id deduplica los reintentos del proveedor (vacio = sin deduplicar).
+// fn recibe un contexto independiente del request (con su request ID) y con deadline Options.Timeout.
+func (d *Dispatcher) Dispatch(ctx context.Context, key, id string, fn func(ctx context.Context)) bool {
+	d.mu.Lock()
+	if id != "" {
+		d.purgeLocked()
+		if _, dup := d.seen[id]; dup {
+			d.mu.Unlock()
+			slog.DebugContext(ctx, "channel duplicate message", "channel", d.name, "message_id", id)
+			d.record(OutcomeDuplicate)
+			return false
+		}
+	}
+	if d.closed || d.pending >= d.opts.MaxPending {
+		closed := d.closed
+		d.mu.Unlock()
+		slog.WarnContext(ctx, "channel message dropped", "channel", d.name, "message_id", id, "closed", closed, "max_pending", d.opts.MaxPending)
+		d.record(OutcomeDropped)
+		return false
+	}
+	if id != "" {
+		d.seen[id] = time.Now()
+	}
+	prev := d.tails[key]
+	done := make(chan struct{})
+	d.tails[key] = done
+	d.pending++
+	d.wg.Add(1)
+	d.mu.Unlock()
+	d.record(OutcomeDispatched)
+
+	base := context.WithoutCancel(middleware.WithoutAnnotations(ctx))
+	go func() {
+		defer d.wg.Done()
+		defer func() {
+			close(done)
+			d.mu.Lock()
+			d.pending--
+			if d.tails[key] == done {
+				delete(d.tails, key)
+			}
+			d.mu.Unlock()
+		}()
+		if prev != nil {
+			<-prev
+		}
+		d.sem <- struct{}{}
+		defer func() { <-d.sem }()
+
+		ctx, cancel := context.WithTimeout(base, d.opts.Timeout)
+		defer cancel()
+		fn(ctx)
+	}()
+	return true
+}
+
+// Record counts an outcome decided by the channel adapter (ignored, replied, send_failed).
+func (d *Dispatcher) Record(outcome string) {
+	d.record(outcome)
+}
+
+// Shutdown stops accepting messages and waits for the queued ones until ctx expires.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	d.closed = true
+	pending := d.pending
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		if pending > 0 {
+			slog.Info("channel messages drained", "channel", d.name, "count", pending)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%s shutdown: %w", d.name, ctx.Err())
+	}
+}
+
+// purgeLocked drops expired IDs, como mucho una vez por minuto.
+func (d *Dispatcher) purgeLocked() {
+	now := time.Now()
+	if now.Sub(d.lastPurge) < time.Minute {
+		return
+	}
+	d.lastPurge = now
+	for id, t := range d.seen {
+		if now.Sub(t) > d.opts.DedupeTTL {
+			delete(d.seen, id)
+		}
+	}
+}
+
+func (d *Dispatcher) record(outcome string) {
+	if d.metrics != nil {
+		d.metrics.RecordChannelMessage(d.name, outcome)
+	}
+}
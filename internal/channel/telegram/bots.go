@@ -0,0 +1,51 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"gateway/internal/handler"
+)
+
+// Bot is the local config of one Telegram bot: a que empresa pertenece, con que credenciales se
+// llama al agente y con que token se responde por la Bot API.
+type Bot struct {
+	Token       string             `json:"token"`        // token de BotFather ("123456:ABC...")
+	SecretToken string             `json:"secret_token"` // secret_token de setWebhook; llega en X-Telegram-Bot-Api-Secret-Token
+	IdEmpresa   int                `json:"id_empresa"`
+	ApiKey      string             `json:"api_key"`
+	Config      handler.ChatConfig `json:"config"` // mismo objeto config de POST /api/agent/chat
+}
+
+// request builds the ChatRequest for one inbound message. session_id = chat.id (chat privado = id del usuario).
+func (b Bot) request(message string, sessionID int) *handler.ChatRequest {
+	return &handler.ChatRequest{
+		Message:   message,
+		SessionID: sessionID,
+		IdEmpresa: b.IdEmpresa,
+		ApiKey:    b.ApiKey,
+		Config:    b.Config,
+	}
+}
+
+// LoadBots reads the bots file: un objeto JSON con el nombre del bot como clave (ultimo segmento de
+// la URL del webhook, /webhooks/telegram/{bot}).
+//
+//	{"clinica": {"token": "123456:ABC...", "secret_token": "...", "id_empresa": 7, "api_key": "...", "config": {"modalidad": "citas"}}}
+func LoadBots(path string) (map[string]Bot, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("telegram bots: %w", err)
+	}
+	var bots map[string]Bot
+	if err := json.Unmarshal(raw, &bots); err != nil {
+		return nil, fmt.Errorf("telegram bots %s: %w", path, err)
+	}
+	for name, b := range bots {
+		if b.Token == "" || b.SecretToken == "" {
+			return nil, fmt.Errorf("telegram bots %s: bot %s: token and secret_token are required", path, name)
+		}
+	}
+	return bots, nil
+}
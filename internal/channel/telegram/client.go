@@ -0,0 +1,152 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"gateway/internal/domain"
+)
+
+// DefaultBaseURL is the Bot API base used to send messages.
+const DefaultBaseURL = "https://api.telegram.org"
+
+// Limites de la Bot API.
+const (
+	maxTextRunes      = 4096
+	maxCaptionRunes   = 1024
+	maxCallbackBytes  = 64
+	maxInlineKeyboard = 100
+)
+
+// Client sends messages through the Telegram Bot API.
+type Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+// NewClient creates a Client. baseURL vacio = DefaultBaseURL; timeout es por llamada a la API.
+func NewClient(baseURL string, timeout time.Duration) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{baseURL: strings.TrimRight(baseURL, "/"), http: &http.Client{Timeout: timeout}}
+}
+
+// Send delivers msgs to chatID in order: sendMessage para texto y botones, sendPhoto para
+// imagenes y sendDocument para documentos. Se detiene en el primer error.
+func (c *Client) Send(ctx context.Context, token string, chatID int64, msgs []domain.Message) error {
+	for i, m := range msgs {
+		method, payload := outbound(chatID, m)
+		if err := c.call(ctx, token, method, payload); err != nil {
+			return fmt.Errorf("message %d (%s): %w", i, m.Type, err)
+		}
+	}
+	return nil
+}
+
+// AnswerCallback acknowledges a button press (sin esto el cliente muestra el boton cargando).
+func (c *Client) AnswerCallback(ctx context.Context, token, callbackID string) error {
+	return c.call(ctx, token, "answerCallbackQuery", map[string]any{"callback_query_id": callbackID})
+}
+
+// apiResponse is the envelope of every Bot API response.
+type apiResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+}
+
+func (c *Client) call(ctx context.Context, token, method string, payload map[string]any) error {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/bot"+token+"/"+method, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		// El error de url.Error incluye la URL, y con ella el token del bot.
+		return fmt.Errorf("%s: %w", method, redactToken(err, token))
+	}
+	defer resp.Body.Close()
+	var out apiResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&out); err != nil {
+		return fmt.Errorf("%s: status %d: decode: %w", method, resp.StatusCode, err)
+	}
+	if !out.OK {
+		return fmt.Errorf("%s: status %d: %s", method, resp.StatusCode, out.Description)
+	}
+	return nil
+}
+
+// outbound maps a domain.Message to a Bot API method and its parameters.
+func outbound(chatID int64, m domain.Message) (string, map[string]any) {
+	switch m.Type {
+	case domain.MessageImage:
+		p := map[string]any{"chat_id": chatID, "photo": m.URL}
+		if m.Text != "" {
+			p["caption"] = truncate(m.Text, maxCaptionRunes)
+		}
+		return "sendPhoto", p
+	case domain.MessageDocument:
+		p := map[string]any{"chat_id": chatID, "document": m.URL}
+		if m.Text != "" {
+			p["caption"] = truncate(m.Text, maxCaptionRunes)
+		}
+		return "sendDocument", p
+	case domain.MessageButtons, domain.MessageQuickReplies:
+		if len(m.Buttons) <= maxInlineKeyboard {
+			rows := make([][]map[string]any, 0, len(m.Buttons))
+			for _, b := range m.Buttons {
+				button := map[string]any{"text": b.Title}
+				if b.URL != "" {
+					button["url"] = b.URL
+				} else {
+					button["callback_data"] = callbackData(b)
+				}
+				rows = append(rows, []map[string]any{button}) // un boton por fila: los titulos largos no se cortan
+			}
+			return "sendMessage", map[string]any{
+				"chat_id":      chatID,
+				"text":         truncate(m.Text, maxTextRunes),
+				"reply_markup": map[string]any{"inline_keyboard": rows},
+			}
+		}
+	}
+	text, _ := domain.Flatten([]domain.Message{m})
+	return "sendMessage", map[string]any{"chat_id": chatID, "text": truncate(text, maxTextRunes)}
+}
+
+// callbackData is what comes back as the user's message when the button is pressed
+// (Payload o Title, recortado a los 64 bytes que admite Telegram).
+func callbackData(b domain.Button) string {
+	s := b.Payload
+	if s == "" {
+		s = b.Title
+	}
+	for len(s) > maxCallbackBytes {
+		r := []rune(s)
+		s = string(r[:len(r)-1])
+	}
+	return s
+}
+
+func redactToken(err error, token string) error {
+	return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), token, "<token>"))
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,98 @@
+package telegram
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"gateway/internal/domain"
+)
+
+func TestCallbackDataCut(t *testing.T) {
+	long := strings.Repeat("a", 63) + "ñ" // 65 bytes: la ñ no entra entera
+	tests := []struct {
+		name string
+		b    domain.Button
+		want string
+	}{
+		{"payload", domain.Button{Title: "10:00", Payload: "slot_10"}, "slot_10"},
+		{"title when no payload", domain.Button{Title: "10:00"}, "10:00"},
+		{"exactly 64 bytes", domain.Button{Payload: strings.Repeat("x", 64)}, strings.Repeat("x", 64)},
+		{"ascii over 64", domain.Button{Payload: strings.Repeat("x", 80)}, strings.Repeat("x", 64)},
+		{"multibyte at the edge", domain.Button{Payload: long}, strings.Repeat("a", 63)},
+		{"multibyte", domain.Button{Payload: strings.Repeat("ñ", 40)}, strings.Repeat("ñ", 32)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := callbackData(tt.b)
+			if got != tt.want {
+				t.Errorf("callbackData = %q (%d bytes), want %q", got, len(got), tt.want)
+			}
+			if len(got) > maxCallbackBytes || !utf8.ValidString(got) {
+				t.Errorf("callbackData = %q: %d bytes, valid utf8 %v", got, len(got), utf8.ValidString(got))
+			}
+		})
+	}
+}
+
+func TestOutboundMethod(t *testing.T) {
+	tests := []struct {
+		m    domain.Message
+		want string
+		key  string
+	}{
+		{domain.Message{Type: domain.MessageText, Text: "hola"}, "sendMessage", "text"},
+		{domain.Message{Type: domain.MessageImage, URL: "https://x/a.png"}, "sendPhoto", "photo"},
+		{domain.Message{Type: domain.MessageDocument, URL: "https://x/b.pdf", Filename: "b.pdf"}, "sendDocument", "document"},
+		{domain.Message{Type: domain.MessageButtons, Text: "Web", Buttons: []domain.Button{{Title: "Abrir", URL: "https://x.pe"}}}, "sendMessage", "reply_markup"},
+		{domain.MediaMessage("https://x/c.JPG?sig=1"), "sendPhoto", "photo"},
+		{domain.MediaMessage("https://x/d.xlsx"), "sendDocument", "document"},
+	}
+	for _, tt := range tests {
+		method, payload := outbound(42, tt.m)
+		if method != tt.want {
+			t.Errorf("%s %s: method = %s, want %s", tt.m.Type, tt.m.URL, method, tt.want)
+		}
+		if _, ok := payload[tt.key]; !ok {
+			t.Errorf("%s %s: payload %v without %q", tt.m.Type, tt.m.URL, payload, tt.key)
+		}
+	}
+}
+
+func TestClientRedactsTokenFromErrors(t *testing.T) {
+	// Servidor cerrado: el error de red es un url.Error con la URL completa (/bot<token>/sendMessage).
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	c := NewClient(srv.URL, time.Second)
+	err := c.Send(context.Background(), testToken, 42, []domain.Message{{Type: domain.MessageText, Text: "hola"}})
+	if err == nil {
+		t.Fatal("Send to a closed server: want error")
+	}
+	if strings.Contains(err.Error(), testToken) {
+		t.Errorf("error leaks the bot token: %v", err)
+	}
+	if !strings.Contains(err.Error(), "<token>") {
+		t.Errorf("error = %v, want the token replaced by <token>", err)
+	}
+}
+
+func TestClientAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`))
+	}))
+	defer srv.Close()
+
+	err := NewClient(srv.URL, time.Second).Send(context.Background(), testToken, 42, []domain.Message{{Type: domain.MessageText, Text: "hola"}})
+	if err == nil || !strings.Contains(err.Error(), "chat not found") {
+		t.Fatalf("err = %v, want the Bot API description", err)
+	}
+	if strings.Contains(err.Error(), testToken) {
+		t.Errorf("error leaks the bot token: %v", err)
+	}
+}
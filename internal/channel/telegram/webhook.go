@@ -0,0 +1,163 @@
+// Package telegram es el canal de Telegram: recibe los updates del webhook de cada bot, los pasa por
+// el mismo camino que POST /api/agent/chat (routing, breakers, semaforos) y responde con la Bot API
+// (sendMessage, sendPhoto, sendDocument).
+package telegram
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"gateway/internal/channel"
+	"gateway/internal/handler"
+	"gateway/internal/logging"
+	"gateway/internal/middleware"
+)
+
+// Name is the channel label in logs and metrics.
+const Name = "telegram"
+
+// SecretHeader carries the secret_token given to setWebhook.
+const SecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// maxBodyBytes limita el POST de Telegram (un update son pocos KB).
+const maxBodyBytes = 1 << 20
+
+// Handler serves POST /webhooks/telegram/{bot}.
+type Handler struct {
+	chat       channel.ChatService
+	bots       map[string]Bot
+	client     *Client
+	dispatcher *channel.Dispatcher
+}
+
+// NewHandler validates every bot against the chat path (modalidad enrutable, id_empresa, api_key)
+// and builds the handler.
+func NewHandler(chat channel.ChatService, bots map[string]Bot, client *Client, d *channel.Dispatcher) (*Handler, error) {
+	if len(bots) == 0 {
+		return nil, errors.New("telegram: no bots configured")
+	}
+	for name, b := range bots {
+		if e := chat.Validate(b.request("-", 1), "en"); e != nil {
+			return nil, fmt.Errorf("telegram bot %s: %s", name, e.Describe())
+		}
+	}
+	return &Handler{chat: chat, bots: bots, client: client, dispatcher: d}, nil
+}
+
+// ServeHTTP handles one update: verifica el secret token del bot, encola el mensaje y responde 200
+// sin esperar al agente (Telegram reintenta los updates sin 200; se deduplican por update_id).
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("bot")
+	bot, ok := h.bots[name]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(SecretHeader)), []byte(bot.SecretToken)) != 1 {
+		slog.WarnContext(r.Context(), "telegram secret token rejected", "bot", name, "remote_addr", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var u update
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&u); err != nil {
+		slog.WarnContext(r.Context(), "telegram decode error", "bot", name, "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	middleware.Annotate(r.Context(), "tg_bot", name)
+	h.dispatch(r.Context(), name, bot, u)
+	w.WriteHeader(http.StatusOK)
+}
+
+// dispatch maps the update to a ChatRequest and queues the agent call.
+// Solo chats privados: session_id = chat.id (los grupos tienen ids negativos y varios usuarios).
+func (h *Handler) dispatch(ctx context.Context, name string, bot Bot, u update) {
+	text, c, callbackID := u.text()
+	if c.ID == 0 {
+		slog.DebugContext(ctx, "telegram update without message", "bot", name, "update_id", u.UpdateID)
+		h.dispatcher.Record(channel.OutcomeIgnored)
+		return
+	}
+	if c.Type != "private" {
+		slog.InfoContext(ctx, "telegram non-private chat", "bot", name, "chat_type", c.Type, "chat_id", c.ID)
+		h.dispatcher.Record(channel.OutcomeIgnored)
+		return
+	}
+	if text == "" {
+		slog.InfoContext(ctx, "telegram unsupported message", "bot", name, "update_id", u.UpdateID, "id_empresa", bot.IdEmpresa)
+		h.dispatcher.Record(channel.OutcomeIgnored)
+		return
+	}
+
+	req := bot.request(text, int(c.ID))
+	// Request ID propio por update (como cada mensaje de WhatsApp): <request del webhook>-<update_id>.
+	ctx = middleware.WithRequestID(ctx, fmt.Sprintf("%s-%d", middleware.GetRequestID(ctx), u.UpdateID))
+	ctx = logging.WithTarget(ctx, req.IdEmpresa, req.SessionID)
+	key := name + ":" + strconv.FormatInt(c.ID, 10)
+	h.dispatcher.Dispatch(ctx, key, name+":"+strconv.FormatInt(u.UpdateID, 10), func(ctx context.Context) {
+		if callbackID != "" {
+			if err := h.client.AnswerCallback(ctx, bot.Token, callbackID); err != nil {
+				slog.DebugContext(ctx, "telegram answer callback failed", "bot", name, "err", err)
+			}
+		}
+		h.reply(ctx, name, bot, c.ID, req)
+	})
+}
+
+// reply calls the agent and sends its messages (o el fallback) al chat.
+func (h *Handler) reply(ctx context.Context, name string, bot Bot, chatID int64, req *handler.ChatRequest) {
+	if e := h.chat.Validate(req, "es"); e != nil {
+		slog.WarnContext(ctx, "telegram invalid request", "request_id", middleware.GetRequestID(ctx), "bot", name, "err", e.Describe())
+		h.dispatcher.Record(channel.OutcomeIgnored)
+		return
+	}
+	resp := h.chat.ChatV2(ctx, req)
+	if err := h.client.Send(ctx, bot.Token, chatID, resp.Messages); err != nil {
+		slog.WarnContext(ctx, "telegram send failed", "request_id", middleware.GetRequestID(ctx), "bot", name, "session_id", req.SessionID, "err", err)
+		h.dispatcher.Record(channel.OutcomeSendFailed)
+		return
+	}
+	h.dispatcher.Record(channel.OutcomeReplied)
+}
+
+// update is a Bot API Update (solo los campos que usa el gateway).
+type update struct {
+	UpdateID      int64          `json:"update_id"`
+	Message       *message       `json:"message"`
+	CallbackQuery *callbackQuery `json:"callback_query"`
+}
+
+type message struct {
+	MessageID int64  `json:"message_id"`
+	Chat      chat   `json:"chat"`
+	Text      string `json:"text"`
+}
+
+type chat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"` // private, group, supergroup, channel
+}
+
+type callbackQuery struct {
+	ID      string   `json:"id"`
+	Data    string   `json:"data"` // callback_data del boton (domain.Button.Payload)
+	Message *message `json:"message"`
+}
+
+// text returns what goes to the agent as message, el chat y, si fue un boton, el ID del callback.
+// text "" = tipo no soportado (sticker, audio, foto...).
+func (u update) text() (string, chat, string) {
+	switch {
+	case u.Message != nil:
+		return u.Message.Text, u.Message.Chat, ""
+	case u.CallbackQuery != nil && u.CallbackQuery.Message != nil:
+		return u.CallbackQuery.Data, u.CallbackQuery.Message.Chat, u.CallbackQuery.ID
+	}
+	return "", chat{}, ""
+}
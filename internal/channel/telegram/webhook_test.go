@@ -0,0 +1,312 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gateway/internal/channel"
+	"gateway/internal/domain"
+	"gateway/internal/handler"
+	"gateway/internal/middleware"
+)
+
+const (
+	testToken  = "123456:ABC-secret-bot-token"
+	testSecret = "webhook-secret"
+)
+
+// apiCall is one request received by the fake Bot API.
+type apiCall struct {
+	token   string
+	method  string
+	payload map[string]any
+}
+
+// fakeAPI is a local stand-in for api.telegram.org: guarda cada llamada y responde {"ok": true}.
+type fakeAPI struct {
+	mu    sync.Mutex
+	calls []apiCall
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// /bot<token>/<method>
+	rest, ok := strings.CutPrefix(r.URL.Path, "/bot")
+	token, method, found := strings.Cut(rest, "/")
+	if r.Method != http.MethodPost || !ok || !found {
+		http.NotFound(w, r)
+		return
+	}
+	var payload map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	f.calls = append(f.calls, apiCall{token: token, method: method, payload: payload})
+	f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{"ok":true,"result":{}}`))
+}
+
+func (f *fakeAPI) methods() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]string, len(f.calls))
+	for i, c := range f.calls {
+		out[i] = c.method
+	}
+	return out
+}
+
+// fakeChat answers every message with reply and records what reached the agent.
+type fakeChat struct {
+	reply []domain.Message
+
+	mu         sync.Mutex
+	reqs       []*handler.ChatRequest
+	requestIDs []string
+}
+
+func (f *fakeChat) ChatV2(ctx context.Context, req *handler.ChatRequest) handler.ChatResponseV2 {
+	f.mu.Lock()
+	f.reqs = append(f.reqs, req)
+	f.requestIDs = append(f.requestIDs, middleware.GetRequestID(ctx))
+	f.mu.Unlock()
+	return handler.ChatResponseV2{Messages: f.reply, SessionID: req.SessionID}
+}
+
+func (f *fakeChat) Validate(req *handler.ChatRequest, _ string) *handler.ErrorResponse {
+	if req.ApiKey == "" {
+		return &handler.ErrorResponse{Detail: "api_key is required", Code: "validation_failed"}
+	}
+	return nil
+}
+
+func (f *fakeChat) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.reqs)
+}
+
+type testEnv struct {
+	api        *fakeAPI
+	chat       *fakeChat
+	dispatcher *channel.Dispatcher
+	srv        *httptest.Server
+}
+
+// newTestEnv serves the handler like the gateway router (/webhooks/telegram/{bot}, con request ID)
+// with its client pointed at a fake Bot API.
+func newTestEnv(t *testing.T, reply []domain.Message) *testEnv {
+	t.Helper()
+	api := &fakeAPI{}
+	apiSrv := httptest.NewServer(api)
+	t.Cleanup(apiSrv.Close)
+
+	chat := &fakeChat{reply: reply}
+	d := channel.NewDispatcher(Name, channel.Options{}, nil)
+	bots := map[string]Bot{
+		"clinica": {Token: testToken, SecretToken: testSecret, IdEmpresa: 7, ApiKey: "key", Config: handler.ChatConfig{Modalidad: "citas"}},
+	}
+	h, err := NewHandler(chat, bots, NewClient(apiSrv.URL, 5*time.Second), d)
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("POST /webhooks/telegram/{bot}", middleware.RequestID(h))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return &testEnv{api: api, chat: chat, dispatcher: d, srv: srv}
+}
+
+// post sends an update as Telegram would and returns the status code.
+func (e *testEnv) post(t *testing.T, bot, secret, requestID, body string) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, e.srv.URL+"/webhooks/telegram/"+bot, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set(SecretHeader, secret)
+	}
+	if requestID != "" {
+		req.Header.Set("X-Request-ID", requestID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// wait blocks until every dispatched update finished (respuesta enviada a la API falsa).
+func (e *testEnv) wait(t *testing.T) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := e.dispatcher.Shutdown(ctx); err != nil {
+		t.Fatalf("dispatcher shutdown: %v", err)
+	}
+}
+
+func privateText(updateID int64, chatID int64, text string) string {
+	return `{"update_id":` + itoa(updateID) + `,"message":{"message_id":1,"chat":{"id":` + itoa(chatID) + `,"type":"private"},"text":"` + text + `"}}`
+}
+
+func itoa(n int64) string {
+	b, _ := json.Marshal(n)
+	return string(b)
+}
+
+func TestWebhookRejectsWrongSecret(t *testing.T) {
+	e := newTestEnv(t, []domain.Message{{Type: domain.MessageText, Text: "hola"}})
+
+	for _, secret := range []string{"", "otro-secreto"} {
+		if got := e.post(t, "clinica", secret, "", privateText(1, 42, "hola")); got != http.StatusUnauthorized {
+			t.Errorf("secret %q: status = %d, want 401", secret, got)
+		}
+	}
+	e.wait(t)
+	if n := e.chat.count(); n != 0 {
+		t.Errorf("agent calls = %d, want 0", n)
+	}
+	if m := e.api.methods(); len(m) != 0 {
+		t.Errorf("Bot API calls = %v, want none", m)
+	}
+}
+
+func TestWebhookUnknownBot(t *testing.T) {
+	e := newTestEnv(t, nil)
+	if got := e.post(t, "otro", testSecret, "", privateText(1, 42, "hola")); got != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", got)
+	}
+}
+
+func TestWebhookIgnoresNonPrivateChats(t *testing.T) {
+	e := newTestEnv(t, []domain.Message{{Type: domain.MessageText, Text: "hola"}})
+
+	for _, typ := range []string{"group", "supergroup", "channel"} {
+		body := `{"update_id":5,"message":{"message_id":1,"chat":{"id":-100123,"type":"` + typ + `"},"text":"hola"}}`
+		if got := e.post(t, "clinica", testSecret, "", body); got != http.StatusOK {
+			t.Errorf("%s: status = %d, want 200", typ, got)
+		}
+	}
+	e.wait(t)
+	if n := e.chat.count(); n != 0 {
+		t.Errorf("agent calls = %d, want 0", n)
+	}
+	if m := e.api.methods(); len(m) != 0 {
+		t.Errorf("Bot API calls = %v, want none", m)
+	}
+}
+
+func TestWebhookDedupesUpdateID(t *testing.T) {
+	e := newTestEnv(t, []domain.Message{{Type: domain.MessageText, Text: "hola"}})
+
+	// Telegram reintenta el mismo update si no recibio 200 a tiempo.
+	for range 3 {
+		if got := e.post(t, "clinica", testSecret, "", privateText(77, 42, "hola")); got != http.StatusOK {
+			t.Fatalf("status = %d, want 200", got)
+		}
+	}
+	if got := e.post(t, "clinica", testSecret, "", privateText(78, 42, "otra")); got != http.StatusOK {
+		t.Fatalf("status = %d, want 200", got)
+	}
+	e.wait(t)
+
+	if n := e.chat.count(); n != 2 {
+		t.Errorf("agent calls = %d, want 2 (update 77 once, update 78 once)", n)
+	}
+	if m := e.api.methods(); len(m) != 2 {
+		t.Errorf("Bot API calls = %v, want 2 sendMessage", m)
+	}
+}
+
+func TestWebhookRequestIDPerUpdate(t *testing.T) {
+	e := newTestEnv(t, []domain.Message{{Type: domain.MessageText, Text: "hola"}})
+
+	e.post(t, "clinica", testSecret, "rid-a", privateText(10, 42, "hola"))
+	e.post(t, "clinica", testSecret, "rid-b", privateText(11, 43, "hola"))
+	e.wait(t)
+
+	e.chat.mu.Lock()
+	defer e.chat.mu.Unlock()
+	got := map[string]bool{}
+	for _, id := range e.chat.requestIDs {
+		got[id] = true
+	}
+	for _, want := range []string{"rid-a-10", "rid-b-11"} {
+		if !got[want] {
+			t.Errorf("request IDs = %v, want %s", e.chat.requestIDs, want)
+		}
+	}
+}
+
+func TestWebhookReplySelectsMethod(t *testing.T) {
+	url := "https://cdn.example.com/menu.pdf?v=2"
+	reply := append([]domain.Message{
+		{Type: domain.MessageText, Text: "Hola"},
+		{Type: domain.MessageImage, URL: "https://cdn.example.com/mapa.png", Text: "Mapa"},
+		{Type: domain.MessageQuickReplies, Text: "Elige", Buttons: []domain.Button{{Title: "10:00", Payload: "slot_10"}}},
+	}, domain.LegacyMessages("Aqui el menu", &url)...) // reply + url del contrato v1: texto y documento
+
+	e := newTestEnv(t, reply)
+	if got := e.post(t, "clinica", testSecret, "", privateText(1, 42, "hola")); got != http.StatusOK {
+		t.Fatalf("status = %d, want 200", got)
+	}
+	e.wait(t)
+
+	want := []string{"sendMessage", "sendPhoto", "sendMessage", "sendMessage", "sendDocument"}
+	got := e.api.methods()
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("methods = %v, want %v", got, want)
+	}
+
+	e.api.mu.Lock()
+	defer e.api.mu.Unlock()
+	for _, c := range e.api.calls {
+		if c.token != testToken {
+			t.Errorf("%s: token = %q, want the bot token", c.method, c.token)
+		}
+		if c.payload["chat_id"] != float64(42) {
+			t.Errorf("%s: chat_id = %v, want 42", c.method, c.payload["chat_id"])
+		}
+	}
+	if p := e.api.calls[1].payload; p["photo"] != "https://cdn.example.com/mapa.png" || p["caption"] != "Mapa" {
+		t.Errorf("sendPhoto payload = %v", p)
+	}
+	if p := e.api.calls[4].payload; p["document"] != url {
+		t.Errorf("sendDocument payload = %v, want document %s", p, url)
+	}
+	if _, ok := e.api.calls[2].payload["reply_markup"]; !ok {
+		t.Errorf("quick replies without reply_markup: %v", e.api.calls[2].payload)
+	}
+}
+
+func TestWebhookCallbackQuery(t *testing.T) {
+	e := newTestEnv(t, []domain.Message{{Type: domain.MessageText, Text: "Reservado"}})
+
+	body := `{"update_id":9,"callback_query":{"id":"cb-1","data":"slot_10","message":{"message_id":3,"chat":{"id":42,"type":"private"}}}}`
+	if got := e.post(t, "clinica", testSecret, "", body); got != http.StatusOK {
+		t.Fatalf("status = %d, want 200", got)
+	}
+	e.wait(t)
+
+	if got := e.api.methods(); strings.Join(got, ",") != "answerCallbackQuery,sendMessage" {
+		t.Errorf("methods = %v, want [answerCallbackQuery sendMessage]", got)
+	}
+	e.chat.mu.Lock()
+	defer e.chat.mu.Unlock()
+	if len(e.chat.reqs) != 1 || e.chat.reqs[0].Message != "slot_10" || e.chat.reqs[0].SessionID != 42 {
+		t.Errorf("agent request = %+v, want message slot_10 for session 42", e.chat.reqs)
+	}
+}
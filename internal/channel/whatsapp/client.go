@@ -0,0 +1,172 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"gateway/internal/domain"
+)
+
+// DefaultBaseURL is the Graph API base used to send messages.
+const DefaultBaseURL = "https://graph.facebook.com/v21.0"
+
+// Limites de la Cloud API (en runas).
+const (
+	maxBodyRunes        = 4096
+	maxCaptionRunes     = 1024
+	maxReplyButtons     = 3
+	maxButtonTitleRunes = 20
+	maxListRows         = 10
+	maxRowTitleRunes    = 24
+	listButtonLabel     = "Opciones"
+)
+
+// Client sends messages through the WhatsApp Cloud API.
+type Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+// NewClient creates a Client. baseURL vacio = DefaultBaseURL; timeout es por mensaje enviado.
+func NewClient(baseURL string, timeout time.Duration) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{baseURL: strings.TrimRight(baseURL, "/"), http: &http.Client{Timeout: timeout}}
+}
+
+// Send delivers msgs to the user in order, desde el numero phoneNumberID. Se detiene en el primer error.
+func (c *Client) Send(ctx context.Context, phoneNumberID, token, to string, msgs []domain.Message) error {
+	for i, m := range msgs {
+		if err := c.post(ctx, phoneNumberID, token, outbound(to, m)); err != nil {
+			return fmt.Errorf("message %d (%s): %w", i, m.Type, err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) post(ctx context.Context, phoneNumberID, token string, payload map[string]any) error {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/"+phoneNumberID+"/messages", bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("cloud api status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// outbound maps a domain.Message to a Cloud API message. Lo que WhatsApp no puede representar
+// (mas de 10 opciones, varios botones de enlace) se envia como texto aplanado.
+func outbound(to string, m domain.Message) map[string]any {
+	out := map[string]any{
+		"messaging_product": "whatsapp",
+		"recipient_type":    "individual",
+		"to":                to,
+	}
+	switch m.Type {
+	case domain.MessageImage:
+		media := map[string]any{"link": m.URL}
+		if m.Text != "" {
+			media["caption"] = truncate(m.Text, maxCaptionRunes)
+		}
+		out["type"], out["image"] = "image", media
+		return out
+	case domain.MessageDocument:
+		media := map[string]any{"link": m.URL}
+		if m.Filename != "" {
+			media["filename"] = m.Filename
+		}
+		if m.Text != "" {
+			media["caption"] = truncate(m.Text, maxCaptionRunes)
+		}
+		out["type"], out["document"] = "document", media
+		return out
+	case domain.MessageButtons, domain.MessageQuickReplies:
+		if interactive := interactiveFor(m); interactive != nil {
+			out["type"], out["interactive"] = "interactive", interactive
+			return out
+		}
+	}
+	text, _ := domain.Flatten([]domain.Message{m})
+	out["type"] = "text"
+	out["text"] = map[string]any{"body": truncate(text, maxBodyRunes), "preview_url": strings.Contains(text, "://")}
+	return out
+}
+
+// interactiveFor builds reply buttons (hasta 3), a list (hasta 10) or a single cta_url button. nil = no representable.
+func interactiveFor(m domain.Message) map[string]any {
+	body := map[string]any{"text": truncate(m.Text, maxBodyRunes)}
+	var links, replies []domain.Button
+	for _, b := range m.Buttons {
+		if b.URL != "" {
+			links = append(links, b)
+		} else {
+			replies = append(replies, b)
+		}
+	}
+	switch {
+	case len(links) == 1 && len(replies) == 0:
+		return map[string]any{
+			"type": "cta_url",
+			"body": body,
+			"action": map[string]any{
+				"name":       "cta_url",
+				"parameters": map[string]any{"display_text": truncate(links[0].Title, maxButtonTitleRunes), "url": links[0].URL},
+			},
+		}
+	case len(links) > 0:
+		return nil
+	case len(replies) <= maxReplyButtons:
+		buttons := make([]map[string]any, len(replies))
+		for i, b := range replies {
+			buttons[i] = map[string]any{"type": "reply", "reply": map[string]any{"id": payload(b), "title": truncate(b.Title, maxButtonTitleRunes)}}
+		}
+		return map[string]any{"type": "button", "body": body, "action": map[string]any{"buttons": buttons}}
+	case len(replies) <= maxListRows:
+		rows := make([]map[string]any, len(replies))
+		for i, b := range replies {
+			rows[i] = map[string]any{"id": payload(b), "title": truncate(b.Title, maxRowTitleRunes)}
+		}
+		return map[string]any{
+			"type":   "list",
+			"body":   body,
+			"action": map[string]any{"button": listButtonLabel, "sections": []map[string]any{{"rows": rows}}},
+		}
+	}
+	return nil
+}
+
+// payload is what comes back as the user's message when the option is chosen.
+func payload(b domain.Button) string {
+	if b.Payload != "" {
+		return b.Payload
+	}
+	return b.Title
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
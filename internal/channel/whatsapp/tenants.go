@@ -0,0 +1,49 @@
+package whatsapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"gateway/internal/handler"
+)
+
+// Tenant is the local config of one WhatsApp business number: a que empresa pertenece, con que
+// credenciales se llama al agente y con que token se responde por la Cloud API.
+type Tenant struct {
+	IdEmpresa   int                `json:"id_empresa"`
+	ApiKey      string             `json:"api_key"`
+	AccessToken string             `json:"access_token"` // token (System User) con permiso whatsapp_business_messaging
+	Config      handler.ChatConfig `json:"config"`       // mismo objeto config de POST /api/agent/chat
+}
+
+// request builds the ChatRequest for one inbound message. session_id = numero del usuario (wa_id).
+func (t Tenant) request(message string, sessionID int) *handler.ChatRequest {
+	return &handler.ChatRequest{
+		Message:   message,
+		SessionID: sessionID,
+		IdEmpresa: t.IdEmpresa,
+		ApiKey:    t.ApiKey,
+		Config:    t.Config,
+	}
+}
+
+// LoadTenants reads the tenants file: un objeto JSON con phone_number_id como clave.
+//
+//	{"106540352242922": {"id_empresa": 7, "api_key": "...", "access_token": "EAAG...", "config": {"modalidad": "citas", ...}}}
+func LoadTenants(path string) (map[string]Tenant, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("whatsapp tenants: %w", err)
+	}
+	var tenants map[string]Tenant
+	if err := json.Unmarshal(raw, &tenants); err != nil {
+		return nil, fmt.Errorf("whatsapp tenants %s: %w", path, err)
+	}
+	for id, t := range tenants {
+		if t.AccessToken == "" {
+			return nil, fmt.Errorf("whatsapp tenants %s: phone_number_id %s: access_token is required", path, id)
+		}
+	}
+	return tenants, nil
+}
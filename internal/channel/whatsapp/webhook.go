@@ -0,0 +1,233 @@
+// Package whatsapp sirve el webhook de la WhatsApp Cloud API (Meta) sin pasar por n8n: verifica la
+// suscripcion y la firma, responde 200 de inmediato y, en segundo plano, llama al agente por el mismo
+// camino que POST /api/agent/chat y envia la respuesta al usuario.
+package whatsapp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gateway/internal/channel"
+	"gateway/internal/handler"
+	"gateway/internal/logging"
+	"gateway/internal/middleware"
+)
+
+// Name is the channel label in logs and metrics.
+const Name = "whatsapp"
+
+// SignatureHeader carries the HMAC-SHA256 of the body with the app secret ("sha256=<hex>").
+const SignatureHeader = "X-Hub-Signature-256"
+
+// maxBodyBytes limita el POST de Meta (los payloads reales son de pocos KB).
+const maxBodyBytes = 1 << 20
+
+// Options configures the webhook.
+type Options struct {
+	VerifyToken string // el "Verify token" configurado en la app de Meta
+	AppSecret   string // firma X-Hub-Signature-256
+}
+
+// Handler serves GET (verificacion) and POST (mensajes) of the Meta webhook.
+type Handler struct {
+	chat       channel.ChatService
+	tenants    map[string]Tenant // por phone_number_id
+	client     *Client
+	dispatcher *channel.Dispatcher
+	opts       Options
+}
+
+// NewHandler validates every tenant against the chat path (modalidad enrutable, id_empresa, api_key)
+// and builds the handler.
+func NewHandler(chat channel.ChatService, tenants map[string]Tenant, client *Client, d *channel.Dispatcher, opts Options) (*Handler, error) {
+	if opts.VerifyToken == "" || opts.AppSecret == "" {
+		return nil, errors.New("whatsapp: verify token and app secret are required")
+	}
+	for id, t := range tenants {
+		if e := chat.Validate(t.request("-", 1), "en"); e != nil {
+			return nil, fmt.Errorf("whatsapp tenant %s: %s", id, e.Describe())
+		}
+	}
+	return &Handler{chat: chat, tenants: tenants, client: client, dispatcher: d, opts: opts}, nil
+}
+
+// Verify handles GET: Meta envia hub.mode=subscribe, hub.verify_token y hub.challenge al registrar el webhook.
+func (h *Handler) Verify(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("hub.mode") != "subscribe" || subtle.ConstantTimeCompare([]byte(q.Get("hub.verify_token")), []byte(h.opts.VerifyToken)) != 1 {
+		slog.WarnContext(r.Context(), "whatsapp verify rejected", "mode", q.Get("hub.mode"))
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = io.WriteString(w, q.Get("hub.challenge"))
+}
+
+// ServeHTTP handles POST: verifica la firma, encola los mensajes y responde 200 sin esperar al agente
+// (Meta reintenta las notificaciones que no reciben 200 a tiempo; los reintentos se deduplican por ID).
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !ValidSignature(h.opts.AppSecret, body, r.Header.Get(SignatureHeader)) {
+		slog.WarnContext(r.Context(), "whatsapp signature rejected", "remote_addr", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var n notification
+	if err := json.Unmarshal(body, &n); err != nil {
+		slog.WarnContext(r.Context(), "whatsapp decode error", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	count := 0
+	for _, e := range n.Entry {
+		for _, c := range e.Changes {
+			if c.Field != "messages" {
+				continue
+			}
+			for _, m := range c.Value.Messages {
+				h.dispatch(r.Context(), c.Value.Metadata.PhoneNumberID, m, count)
+				count++
+			}
+		}
+	}
+	middleware.Annotate(r.Context(), "wa_messages", count)
+	w.WriteHeader(http.StatusOK)
+}
+
+// dispatch maps one inbound message to a ChatRequest and queues the agent call.
+// Cada mensaje tiene request ID propio ("<request_id>-<i>"), como los items de un batch.
+func (h *Handler) dispatch(ctx context.Context, phoneNumberID string, m inboundMessage, i int) {
+	tenant, ok := h.tenants[phoneNumberID]
+	if !ok {
+		slog.WarnContext(ctx, "whatsapp unknown phone_number_id", "phone_number_id", phoneNumberID, "message_id", m.ID)
+		h.dispatcher.Record(channel.OutcomeIgnored)
+		return
+	}
+	text := m.text()
+	if text == "" {
+		slog.InfoContext(ctx, "whatsapp unsupported message", "type", m.Type, "message_id", m.ID, "id_empresa", tenant.IdEmpresa)
+		h.dispatcher.Record(channel.OutcomeIgnored)
+		return
+	}
+	sessionID, err := strconv.Atoi(m.From)
+	if err != nil {
+		slog.WarnContext(ctx, "whatsapp invalid sender", "from", m.From, "message_id", m.ID)
+		h.dispatcher.Record(channel.OutcomeIgnored)
+		return
+	}
+
+	req := tenant.request(text, sessionID)
+	ctx = middleware.WithRequestID(ctx, fmt.Sprintf("%s-%d", middleware.GetRequestID(ctx), i))
+	ctx = logging.WithTarget(ctx, req.IdEmpresa, req.SessionID)
+	h.dispatcher.Dispatch(ctx, phoneNumberID+":"+m.From, m.ID, func(ctx context.Context) {
+		h.reply(ctx, phoneNumberID, tenant, m.From, req)
+	})
+}
+
+// reply calls the agent and sends its messages (o el fallback) al usuario.
+func (h *Handler) reply(ctx context.Context, phoneNumberID string, tenant Tenant, to string, req *handler.ChatRequest) {
+	if e := h.chat.Validate(req, "es"); e != nil {
+		slog.WarnContext(ctx, "whatsapp invalid request", "request_id", middleware.GetRequestID(ctx), "id_empresa", req.IdEmpresa, "err", e.Describe())
+		h.dispatcher.Record(channel.OutcomeIgnored)
+		return
+	}
+	resp := h.chat.ChatV2(ctx, req)
+	if err := h.client.Send(ctx, phoneNumberID, tenant.AccessToken, to, resp.Messages); err != nil {
+		slog.WarnContext(ctx, "whatsapp send failed", "request_id", middleware.GetRequestID(ctx), "id_empresa", req.IdEmpresa, "session_id", req.SessionID, "err", err)
+		h.dispatcher.Record(channel.OutcomeSendFailed)
+		return
+	}
+	h.dispatcher.Record(channel.OutcomeReplied)
+}
+
+// ValidSignature checks X-Hub-Signature-256 ("sha256=<hex>") against the app secret.
+func ValidSignature(secret string, body []byte, header string) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// notification is the webhook payload (solo los campos que usa el gateway).
+type notification struct {
+	Object string `json:"object"`
+	Entry  []struct {
+		ID      string `json:"id"`
+		Changes []struct {
+			Field string `json:"field"`
+			Value struct {
+				Metadata struct {
+					PhoneNumberID string `json:"phone_number_id"`
+				} `json:"metadata"`
+				Messages []inboundMessage `json:"messages"`
+			} `json:"value"`
+		} `json:"changes"`
+	} `json:"entry"`
+}
+
+// inboundMessage is one user message. Los estados de entrega (statuses) no se procesan.
+type inboundMessage struct {
+	ID   string `json:"id"`
+	From string `json:"from"` // wa_id del usuario
+	Type string `json:"type"`
+	Text struct {
+		Body string `json:"body"`
+	} `json:"text"`
+	Interactive struct {
+		ButtonReply struct {
+			ID    string `json:"id"`
+			Title string `json:"title"`
+		} `json:"button_reply"`
+		ListReply struct {
+			ID    string `json:"id"`
+			Title string `json:"title"`
+		} `json:"list_reply"`
+	} `json:"interactive"`
+	Button struct {
+		Payload string `json:"payload"`
+		Text    string `json:"text"`
+	} `json:"button"`
+}
+
+// text returns what goes to the agent as message: el texto, o el payload del boton elegido
+// (domain.Button.Payload). "" = tipo no soportado (audio, imagen, ubicacion...).
+func (m inboundMessage) text() string {
+	switch m.Type {
+	case "text":
+		return m.Text.Body
+	case "interactive":
+		if id := m.Interactive.ButtonReply.ID; id != "" {
+			return id
+		}
+		return m.Interactive.ListReply.ID
+	case "button":
+		if m.Button.Payload != "" {
+			return m.Button.Payload
+		}
+		return m.Button.Text
+	}
+	return ""
+}
@@ -1,43 +1,331 @@
-package config
-
-import (
-	"fmt"
-	"os"
-
-	"github.com/ilyakaznacheev/cleanenv"
-	"github.com/joho/godotenv"
-)
-
-// Config holds gateway server configuration from environment.
-// Agent-specific configuration (URLs, enabled flags) lives in agent.Registry.
-type Config struct {
-	HTTPPort    int    `env:"GATEWAY_HTTP_PORT" env-default:"8000"`
-	CORSOrigins string `env:"CORS_ALLOWED_ORIGINS" env-default:"*"`
-
-	// LogLevel: debug, info, warn, error. En desarrollo usar debug; en produccion info.
-	LogLevel string `env:"LOG_LEVEL" env-default:"info"`
-
-	// HTTP server timeouts (seconds). Protect against slowloris and hung connections.
-	ReadHeaderTimeoutSec int `env:"GATEWAY_READ_HEADER_TIMEOUT_SEC" env-default:"10"` // max time to read request headers
-	ReadTimeoutSec       int `env:"GATEWAY_READ_TIMEOUT_SEC" env-default:"40"`         // max time to read full request (headers + body)
-	WriteTimeoutSec      int `env:"GATEWAY_WRITE_TIMEOUT_SEC" env-default:"35"`        // max time to write response; must be > AGENT_TIMEOUT + 5s buffer
-	IdleTimeoutSec       int `env:"GATEWAY_IDLE_TIMEOUT_SEC" env-default:"60"`         // max idle time between requests (keep-alive); 0 = disabled
-
-	AgentTimeoutSec int `env:"AGENT_TIMEOUT" env-default:"25"` // must be < GATEWAY_WRITE_TIMEOUT_SEC - 5s
-}
-
-// Load reads configuration from environment (and optional .env file).
-// In dev: godotenv loads .env into OS env. In Docker: env_file already injects vars.
-// godotenv does NOT overwrite existing env vars — real env always wins.
-func Load() (*Config, error) {
-	if err := godotenv.Load(); err != nil {
-		if !os.IsNotExist(err) {
-			return nil, fmt.Errorf("config: loading .env: %w", err)
-		}
-	}
-	var c Config
-	if err := cleanenv.ReadEnv(&c); err != nil {
-		return nil, fmt.Errorf("config: %w", err)
-	}
-	return &c, nil
-}
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/joho/godotenv"
+)
+
+// Config holds gateway server configuration from environment.
+// Agent-specific configuration (URLs, enabled flags) lives in agent.Registry.
+type Config struct {
+	HTTPPort int `env:"GATEWAY_HTTP_PORT" env-default:"8000"`
+
+	// CORS: politica por defecto. Las politicas por ruta (CORS_ROUTE_<NAME>_*) se cargan en CORSRoutes.
+	CORSOrigins          string `env:"CORS_ALLOWED_ORIGINS" env-default:"*"` // exacto, https://*.dominio o re:<regex>
+	CORSAllowedHeaders   string `env:"CORS_ALLOWED_HEADERS" env-default:"Content-Type,Accept,Authorization,X-Request-ID"`
+	CORSExposedHeaders   string `env:"CORS_EXPOSED_HEADERS" env-default:"X-Request-ID"`
+	CORSAllowCredentials bool   `env:"CORS_ALLOW_CREDENTIALS" env-default:"true"` // solo se envia para origenes exactos
+	CORSMaxAgeSec        int    `env:"CORS_MAX_AGE_SEC" env-default:"600"`
+	CORSRoutes           []CORSRoute
+
+	// LogLevel: debug, info, warn, error. En desarrollo usar debug; en produccion info.
+	LogLevel string `env:"LOG_LEVEL" env-default:"info"`
+	// Access log: rutas excluidas (exactas o prefijo con "*") y proxies confiables (IPs/CIDRs)
+	// cuyo X-Forwarded-For / X-Real-IP se usa como remote_ip.
+	LogExcludePaths string `env:"LOG_EXCLUDE_PATHS" env-default:"/metrics,/health,/livez,/readyz"`
+	TrustedProxies  string `env:"TRUSTED_PROXIES"`
+
+	// Listener de operacion (metrics, health, pprof, diagnostico). Por defecto solo localhost;
+	// vacio = deshabilitado y /metrics, /health, /debug/stats y /admin siguen en el puerto publico.
+	AdminAddr string `env:"ADMIN_ADDR" env-default:"127.0.0.1:9090"`
+	// Token Bearer de los endpoints /admin (nivel de log en caliente) y /debug del listener admin.
+	// Vacio = /admin deshabilitado; /debug queda abierto en el listener admin (solo si ADMIN_ADDR es loopback).
+	AdminToken string `env:"ADMIN_TOKEN" secret:"true"`
+
+	// HTTP server timeouts (seconds). Protect against slowloris and hung connections.
+	ReadHeaderTimeoutSec int `env:"GATEWAY_READ_HEADER_TIMEOUT_SEC" env-default:"10"` // max time to read request headers
+	ReadTimeoutSec       int `env:"GATEWAY_READ_TIMEOUT_SEC" env-default:"40"`         // max time to read full request (headers + body)
+	WriteTimeoutSec      int `env:"GATEWAY_WRITE_TIMEOUT_SEC" env-default:"35"`        // max time to write response; must be > AGENT_TIMEOUT + 5s buffer
+	IdleTimeoutSec       int `env:"GATEWAY_IDLE_TIMEOUT_SEC" env-default:"60"`         // max idle time between requests (keep-alive); 0 = disabled
+
+	AgentTimeoutSec int `env:"AGENT_TIMEOUT" env-default:"25"` // must be < GATEWAY_WRITE_TIMEOUT_SEC - 5s
+
+	// Rechaza (400 must_not_be_negative) valores negativos en los campos numericos opcionales de config.
+	// Apagado por defecto: el contrato original los reenviaba al agente sin validar.
+	ValidationStrictConfig bool `env:"VALIDATION_STRICT_CONFIG" env-default:"false"`
+
+	// TLS del listener (opcional). Con cert y key definidos el gateway sirve HTTPS; los archivos se recargan al cambiar.
+	TLSCertFile  string `env:"GATEWAY_TLS_CERT_FILE"`
+	TLSKeyFile   string `env:"GATEWAY_TLS_KEY_FILE"`
+	TLSReloadSec int    `env:"GATEWAY_TLS_RELOAD_SEC" env-default:"30"` // cada cuanto se revisa si cambiaron cert/key
+
+	// Token de servicio firmado (JWT) hacia los agentes. Vacio = deshabilitado.
+	// SERVICE_TOKEN_KEYS: "kid=ruta,kid=ruta"; la primera clave firma, las demas solo se publican (rotacion).
+	ServiceTokenAlg    string `env:"SERVICE_TOKEN_ALG" env-default:"hs256"` // hs256 | ed25519
+	ServiceTokenKeys   string `env:"SERVICE_TOKEN_KEYS"`
+	ServiceTokenTTLSec int    `env:"SERVICE_TOKEN_TTL_SEC" env-default:"60"`
+	ServiceTokenIssuer string `env:"SERVICE_TOKEN_ISSUER" env-default:"maravia-gateway"`
+
+	// Monitor de salud en segundo plano (/health, /readyz leen su cache).
+	HealthProbeIntervalSec int    `env:"HEALTH_PROBE_INTERVAL_SEC" env-default:"10"`
+	HealthProbeTimeoutSec  int    `env:"HEALTH_PROBE_TIMEOUT_SEC" env-default:"2"`
+	HealthHistorySize      int    `env:"HEALTH_HISTORY_SIZE" env-default:"20"`
+	HealthCriticalAgents   string `env:"HEALTH_CRITICAL_AGENTS"` // ej. "cita,venta"; vacio = ninguno bloquea /readyz
+	// Probes fallidas consecutivas que abren el circuit breaker del agente; 0 = las probes no tocan los breakers.
+	HealthBreakerTripFailures int `env:"HEALTH_BREAKER_TRIP_FAILURES" env-default:"3"`
+
+	// Transcript completo de cada intercambio (JSONL rotado). TRANSCRIPT_DIR vacio = deshabilitado.
+	TranscriptDir                string `env:"TRANSCRIPT_DIR"`
+	TranscriptMaxSizeMB          int    `env:"TRANSCRIPT_MAX_SIZE_MB" env-default:"100"`
+	TranscriptMaxAgeHours        int    `env:"TRANSCRIPT_MAX_AGE_HOURS" env-default:"24"`
+	TranscriptRetentionDays      int    `env:"TRANSCRIPT_RETENTION_DAYS" env-default:"30"` // 0 = no borra archivos viejos
+	TranscriptBuffer             int    `env:"TRANSCRIPT_BUFFER" env-default:"1000"`
+	TranscriptRedactPatternsFile string `env:"TRANSCRIPT_REDACT_PATTERNS_FILE"` // un regex por linea, reemplazo "[REDACTED]"
+
+	// WebSocket /api/agent/ws (widget web): limites por conexion y keepalive.
+	WSEnabled           bool   `env:"WS_ENABLED" env-default:"true"`
+	WSAllowedOrigins    string `env:"WS_ALLOWED_ORIGINS" env-default:"*"` // hosts (ej. "*.maravia.pe"); "*" = cualquiera
+	WSMaxConnections    int    `env:"WS_MAX_CONNECTIONS" env-default:"1000"`
+	WSMaxMessageBytes   int    `env:"WS_MAX_MESSAGE_BYTES" env-default:"65536"`
+	WSMaxInFlight       int    `env:"WS_MAX_IN_FLIGHT" env-default:"2"`
+	WSMaxMessagesPerMin int    `env:"WS_MAX_MESSAGES_PER_MIN" env-default:"30"`
+	WSAuthTimeoutSec    int    `env:"WS_AUTH_TIMEOUT_SEC" env-default:"10"`
+	WSIdleTimeoutSec    int    `env:"WS_IDLE_TIMEOUT_SEC" env-default:"300"`
+	WSPingIntervalSec   int    `env:"WS_PING_INTERVAL_SEC" env-default:"30"`
+
+	// POST /api/agent/chat/batch. BATCH_MAX_PER_AGENT suma todos los lotes y debe quedar por debajo
+	// del semaforo por agente (25) para no desplazar al trafico en vivo.
+	BatchMaxItems     int `env:"BATCH_MAX_ITEMS" env-default:"100"`
+	BatchMaxBodyBytes int `env:"BATCH_MAX_BODY_BYTES" env-default:"5242880"` // 5 MB; cada item sigue limitado a 512 KB
+	BatchConcurrency  int `env:"BATCH_CONCURRENCY" env-default:"4"`          // items en paralelo por lote
+	BatchMaxPerAgent  int `env:"BATCH_MAX_PER_AGENT" env-default:"5"`
+	BatchTimeoutSec   int `env:"BATCH_TIMEOUT_SEC" env-default:"300"`
+
+	// Modo async de /api/agent/chat ("async": true): el agente se llama en segundo plano con JOB_TIMEOUT_SEC
+	// y el resultado queda en GET /api/agent/jobs/{id} (y se envia a callback_url si viene).
+	AsyncEnabled            bool   `env:"ASYNC_ENABLED" env-default:"true"`
+	JobTimeoutSec           int    `env:"JOB_TIMEOUT_SEC" env-default:"120"`
+	JobMaxJobs              int    `env:"JOB_MAX_JOBS" env-default:"10000"` // pendientes + terminados sin expirar
+	JobTTLSec               int    `env:"JOB_TTL_SEC" env-default:"3600"`   // desde que termina el job
+	JobCallbackAllowedHosts string `env:"JOB_CALLBACK_ALLOWED_HOSTS"`       // "hooks.maravia.pe,*.maravia.pe"; vacio = cualquiera
+
+	// Webhooks salientes (callback_url de los jobs): firma HMAC-SHA256, reintentos con backoff exponencial
+	// hasta WEBHOOK_MAX_AGE_SEC y luego dead-letter (ver /admin/webhooks).
+	WebhookSecrets           string `env:"WEBHOOK_SECRETS" secret:"true"` // coma: se firma con cada uno (rotacion)
+	WebhookTimeoutSec        int    `env:"WEBHOOK_TIMEOUT_SEC" env-default:"10"`
+	WebhookMaxAgeSec         int    `env:"WEBHOOK_MAX_AGE_SEC" env-default:"3600"`
+	WebhookInitialBackoffSec int    `env:"WEBHOOK_INITIAL_BACKOFF_SEC" env-default:"1"`
+	WebhookMaxBackoffSec     int    `env:"WEBHOOK_MAX_BACKOFF_SEC" env-default:"300"`
+	WebhookConcurrency       int    `env:"WEBHOOK_CONCURRENCY" env-default:"10"`
+	WebhookMaxPending        int    `env:"WEBHOOK_MAX_PENDING" env-default:"10000"`
+	WebhookMaxDeadLetters    int    `env:"WEBHOOK_MAX_DEAD_LETTERS" env-default:"1000"`
+	WebhookAllowPrivateIPs   bool   `env:"WEBHOOK_ALLOW_PRIVATE_IPS" env-default:"false"` // loopback/privadas: solo receptores internos
+
+	// Canal WhatsApp: webhook de la Cloud API de Meta en /webhooks/whatsapp (sin pasar por n8n).
+	// WHATSAPP_TENANTS_FILE mapea cada phone_number_id a id_empresa, api_key, access_token y config.
+	WhatsAppEnabled        bool   `env:"WHATSAPP_ENABLED" env-default:"false"`
+	WhatsAppVerifyToken    string `env:"WHATSAPP_VERIFY_TOKEN" secret:"true"` // handshake GET (hub.verify_token)
+	WhatsAppAppSecret      string `env:"WHATSAPP_APP_SECRET" secret:"true"`   // firma X-Hub-Signature-256
+	WhatsAppTenantsFile    string `env:"WHATSAPP_TENANTS_FILE"`
+	WhatsAppAPIBaseURL     string `env:"WHATSAPP_API_BASE_URL" env-default:"https://graph.facebook.com/v21.0"`
+	WhatsAppSendTimeoutSec int    `env:"WHATSAPP_SEND_TIMEOUT_SEC" env-default:"10"` // por mensaje enviado
+
+	// Canal Telegram: webhook de cada bot en /webhooks/telegram/{bot}. TELEGRAM_BOTS_FILE mapea cada
+	// bot a su token, secret_token, id_empresa, api_key y config.
+	TelegramEnabled        bool   `env:"TELEGRAM_ENABLED" env-default:"false"`
+	TelegramBotsFile       string `env:"TELEGRAM_BOTS_FILE"`
+	TelegramAPIBaseURL     string `env:"TELEGRAM_API_BASE_URL" env-default:"https://api.telegram.org"`
+	TelegramSendTimeoutSec int    `env:"TELEGRAM_SEND_TIMEOUT_SEC" env-default:"10"` // por llamada a la Bot API
+
+	// Canales de mensajeria: el webhook responde de inmediato y el agente se llama en segundo plano
+	// (en orden por conversacion). Limites por canal.
+	ChannelConcurrency int `env:"CHANNEL_CONCURRENCY" env-default:"50"`
+	ChannelMaxPending  int `env:"CHANNEL_MAX_PENDING" env-default:"1000"`
+	ChannelTimeoutSec  int `env:"CHANNEL_TIMEOUT_SEC" env-default:"120"` // agente + envio de la respuesta
+
+	// gRPC (api/gateway/v1): mismo ChatHandler que HTTP. 0 = deshabilitado. Usa el TLS del listener HTTP si esta activo.
+	GRPCPort       int    `env:"GRPC_PORT" env-default:"0"`
+	GRPCAuthTokens string `env:"GRPC_AUTH_TOKENS" secret:"true"`     // lista de tokens Bearer; vacio = sin autenticacion
+	GRPCReflection bool   `env:"GRPC_REFLECTION" env-default:"true"` // para grpcurl / grpcui
+
+	// MCP (Model Context Protocol): cada agente habilitado es un tool. MCP_ENABLED sirve POST /mcp en el
+	// puerto publico; MCP_STDIO convierte el proceso en un servidor MCP por stdin/stdout (sin listeners).
+	MCPEnabled    bool   `env:"MCP_ENABLED" env-default:"false"`
+	MCPAuthTokens string `env:"MCP_AUTH_TOKENS" secret:"true"` // tokens Bearer de POST /mcp; vacio = sin autenticacion
+	MCPStdio      bool   `env:"MCP_STDIO" env-default:"false"`
+
+	// Label "tenant" en gateway_requests_total. Con lista fija solo esos id_empresa tienen label propio;
+	// sin lista, los primeros METRICS_TENANT_LIMIT tenants vistos. El resto cae en "other".
+	MetricsTenants     string `env:"METRICS_TENANTS"` // ej. "12,57,301"
+	MetricsTenantLimit int    `env:"METRICS_TENANT_LIMIT" env-default:"50"`
+
+	// /debug/stats: ventanas deslizantes en memoria (sin Prometheus).
+	StatsMaxTenants int `env:"STATS_MAX_TENANTS" env-default:"200"` // tenants con serie propia; resto = "other"
+	StatsTopTenants int `env:"STATS_TOP_TENANTS" env-default:"10"`  // tenants reportados por ventana
+
+	// Tracing OpenTelemetry (OTLP/HTTP). Deshabilitado: solo se propaga traceparent hacia los agentes.
+	TracingEnabled     bool    `env:"TRACING_ENABLED" env-default:"false"`
+	TracingEndpoint    string  `env:"TRACING_OTLP_ENDPOINT" env-default:"localhost:4318"` // host:port del collector
+	TracingInsecure    bool    `env:"TRACING_OTLP_INSECURE" env-default:"true"`
+	TracingSampleRatio float64 `env:"TRACING_SAMPLE_RATIO" env-default:"1.0"` // 0..1 sobre trazas raiz
+	TracingServiceName string  `env:"TRACING_SERVICE_NAME" env-default:"maravia-gateway"`
+
+	// Guardrails de contenido. Cada regla se activa definiendo su accion: reject | sanitize | log.
+	GuardrailMaxLength          int    `env:"GUARDRAIL_IN_MAX_LENGTH" env-default:"4000"` // runas
+	GuardrailMaxLengthAction    string `env:"GUARDRAIL_IN_MAX_LENGTH_ACTION"`
+	GuardrailControlCharsAction string `env:"GUARDRAIL_IN_CONTROL_CHARS_ACTION"`
+	GuardrailDenyPatternsFile   string `env:"GUARDRAIL_IN_DENY_PATTERNS_FILE"` // un regex por linea
+	GuardrailDenyAction         string `env:"GUARDRAIL_IN_DENY_ACTION"`
+	GuardrailLeakPatternsFile   string `env:"GUARDRAIL_OUT_LEAK_PATTERNS_FILE"` // un regex por linea
+	GuardrailLeakAction         string `env:"GUARDRAIL_OUT_LEAK_ACTION"`
+	GuardrailURLDomains         string `env:"GUARDRAIL_OUT_URL_ALLOWED_DOMAINS"` // dominios permitidos en url (incluye subdominios)
+	GuardrailURLAction          string `env:"GUARDRAIL_OUT_URL_ACTION"`
+
+	// GUARDRAIL_OUT_URL_ALLOWED_DOMAINS_<ID_EMPRESA>: reemplaza la lista global para ese tenant.
+	GuardrailURLTenantDomains map[int]string
+}
+
+// TLSEnabled reports whether the listener should serve HTTPS.
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// CORSRoute is a per-route CORS policy read from CORS_ROUTE_<NAME>_* variables.
+// Los campos vacios heredan el valor de la politica por defecto.
+type CORSRoute struct {
+	Name             string
+	PathPrefix       string // CORS_ROUTE_<NAME>_PATH (obligatorio)
+	Origins          string // CORS_ROUTE_<NAME>_ORIGINS
+	AllowedHeaders   string // CORS_ROUTE_<NAME>_ALLOWED_HEADERS
+	ExposedHeaders   string // CORS_ROUTE_<NAME>_EXPOSED_HEADERS
+	AllowCredentials bool   // CORS_ROUTE_<NAME>_ALLOW_CREDENTIALS
+	MaxAgeSec        int    // CORS_ROUTE_<NAME>_MAX_AGE_SEC
+}
+
+// Load reads configuration from environment (and optional .env file).
+// In dev: godotenv loads .env into OS env. In Docker: env_file already injects vars.
+// godotenv does NOT overwrite existing env vars — real env always wins.
+func Load() (*Config, error) {
+	if err := godotenv.Load(); err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("config: loading .env: %w", err)
+		}
+	}
+	var c Config
+	if err := cleanenv.ReadEnv(&c); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	routes, err := loadCORSRoutes(&c)
+	if err != nil {
+		return nil, err
+	}
+	c.CORSRoutes = routes
+	tenantDomains, err := loadTenantURLDomains()
+	if err != nil {
+		return nil, err
+	}
+	c.GuardrailURLTenantDomains = tenantDomains
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return nil, fmt.Errorf("config: GATEWAY_TLS_CERT_FILE and GATEWAY_TLS_KEY_FILE must be set together")
+	}
+	if c.WhatsAppEnabled && (c.WhatsAppVerifyToken == "" || c.WhatsAppAppSecret == "" || c.WhatsAppTenantsFile == "") {
+		return nil, fmt.Errorf("config: WHATSAPP_ENABLED requires WHATSAPP_VERIFY_TOKEN, WHATSAPP_APP_SECRET and WHATSAPP_TENANTS_FILE")
+	}
+	if c.TelegramEnabled && c.TelegramBotsFile == "" {
+		return nil, fmt.Errorf("config: TELEGRAM_ENABLED requires TELEGRAM_BOTS_FILE")
+	}
+	// Fuera de localhost el listener admin expone pprof y la config: sin token no arranca.
+	if c.AdminAddr != "" && c.AdminToken == "" && !LoopbackAddr(c.AdminAddr) {
+		return nil, fmt.Errorf("config: ADMIN_ADDR %q is not loopback and requires ADMIN_TOKEN", c.AdminAddr)
+	}
+	return &c, nil
+}
+
+// loadCORSRoutes scans CORS_ROUTE_<NAME>_PATH entries (mismo patron que AGENT_<KEY>_URL en el registry).
+func loadCORSRoutes(c *Config) ([]CORSRoute, error) {
+	var routes []CORSRoute
+	for _, env := range os.Environ() {
+		k, v, ok := strings.Cut(env, "=")
+		if !ok || !strings.HasPrefix(k, "CORS_ROUTE_") || !strings.HasSuffix(k, "_PATH") {
+			continue
+		}
+		name := k[len("CORS_ROUTE_") : len(k)-len("_PATH")]
+		path := strings.TrimSpace(v)
+		if name == "" || path == "" {
+			continue
+		}
+		prefix := "CORS_ROUTE_" + name + "_"
+		rt := CORSRoute{
+			Name:             strings.ToLower(name),
+			PathPrefix:       path,
+			Origins:          envOr(prefix+"ORIGINS", c.CORSOrigins),
+			AllowedHeaders:   envOr(prefix+"ALLOWED_HEADERS", c.CORSAllowedHeaders),
+			ExposedHeaders:   envOr(prefix+"EXPOSED_HEADERS", c.CORSExposedHeaders),
+			AllowCredentials: c.CORSAllowCredentials,
+			MaxAgeSec:        c.CORSMaxAgeSec,
+		}
+		if s := os.Getenv(prefix + "ALLOW_CREDENTIALS"); s != "" {
+			b, err := strconv.ParseBool(strings.TrimSpace(s))
+			if err != nil {
+				return nil, fmt.Errorf("config: %sALLOW_CREDENTIALS: %w", prefix, err)
+			}
+			rt.AllowCredentials = b
+		}
+		if s := os.Getenv(prefix + "MAX_AGE_SEC"); s != "" {
+			n, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return nil, fmt.Errorf("config: %sMAX_AGE_SEC: %w", prefix, err)
+			}
+			rt.MaxAgeSec = n
+		}
+		routes = append(routes, rt)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Name < routes[j].Name })
+	return routes, nil
+}
+
+// loadTenantURLDomains scans GUARDRAIL_OUT_URL_ALLOWED_DOMAINS_<ID_EMPRESA> entries.
+func loadTenantURLDomains() (map[int]string, error) {
+	const prefix = "GUARDRAIL_OUT_URL_ALLOWED_DOMAINS_"
+	out := make(map[int]string)
+	for _, env := range os.Environ() {
+		k, v, ok := strings.Cut(env, "=")
+		if !ok || !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		id, err := strconv.Atoi(k[len(prefix):])
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("config: %s: suffix must be an id_empresa > 0", k)
+		}
+		out[id] = strings.TrimSpace(v)
+	}
+	return out, nil
+}
+
+// envOr returns the trimmed env var or def when unset/empty.
+func envOr(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+// IntList parses a comma-separated list of integers (ej. METRICS_TENANTS).
+func IntList(s string) ([]int, error) {
+	var out []int
+	for _, p := range SplitList(s) {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("config: invalid integer %q: %w", p, err)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// SplitList splits a comma-separated env value, trimming blanks.
+func SplitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
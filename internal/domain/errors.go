@@ -1,7 +1,70 @@
-package domain
-
-import "errors"
-
-// ErrEmptyReply indica que el agente respondio HTTP 200 pero con reply vacio.
-// Se define en domain para que proxy y handler puedan usarlo sin acoplarse entre si.
-var ErrEmptyReply = errors.New("agent returned empty reply")
+package domain
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrEmptyReply indica que el agente respondio HTTP 200 pero con reply vacio.
+// Se define en domain para que proxy y handler puedan usarlo sin acoplarse entre si.
+var ErrEmptyReply = errors.New("agent returned empty reply")
+
+// ErrInputRejected indica que una regla de guardrail rechazo el mensaje entrante (no se llama al agente).
+var ErrInputRejected = errors.New("message rejected by guardrail")
+
+// ErrOutputRejected indica que una regla de guardrail rechazo la respuesta del agente.
+var ErrOutputRejected = errors.New("agent reply rejected by guardrail")
+
+// Errores del invoker. proxy los envuelve con %w para que handler y metricas
+// puedan clasificar el motivo del fallback sin parsear mensajes.
+var (
+	ErrAgentDisabled    = errors.New("agent disabled")
+	ErrBackpressure     = errors.New("agent backpressure")
+	ErrBreakerOpen      = errors.New("circuit breaker open")
+	ErrAgentTimeout     = errors.New("agent timeout")
+	ErrAgentUnreachable = errors.New("agent unreachable")
+	ErrAgentStatus      = errors.New("agent returned non-200 status")
+	ErrAgentDecode      = errors.New("agent response decode failed")
+)
+
+// Motivos de fallback (label "reason" en metricas y logs).
+const (
+	ReasonDisabled     = "disabled"
+	ReasonBackpressure = "backpressure"
+	ReasonBreakerOpen  = "breaker_open"
+	ReasonTimeout      = "timeout"
+	ReasonUnreachable  = "unreachable"
+	ReasonHTTPStatus   = "http_status"
+	ReasonDecode       = "decode"
+	ReasonEmptyReply   = "empty_reply"
+	ReasonRejected     = "rejected"
+	ReasonOther        = "other"
+)
+
+// FallbackReason classifies an invoke error into a bounded set of reasons. "" si err es nil.
+func FallbackReason(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrAgentDisabled):
+		return ReasonDisabled
+	case errors.Is(err, ErrBackpressure):
+		return ReasonBackpressure
+	case errors.Is(err, ErrBreakerOpen):
+		return ReasonBreakerOpen
+	case errors.Is(err, ErrAgentTimeout), errors.Is(err, context.DeadlineExceeded):
+		return ReasonTimeout
+	case errors.Is(err, ErrAgentUnreachable):
+		return ReasonUnreachable
+	case errors.Is(err, ErrAgentStatus):
+		return ReasonHTTPStatus
+	case errors.Is(err, ErrAgentDecode):
+		return ReasonDecode
+	case errors.Is(err, ErrEmptyReply):
+		return ReasonEmptyReply
+	case errors.Is(err, ErrInputRejected), errors.Is(err, ErrOutputRejected):
+		return ReasonRejected
+	default:
+		return ReasonOther
+	}
+}
@@ -0,0 +1,120 @@
+package domain
+
+import (
+	"path"
+	"strings"
+)
+
+// Tipos de Message (respuesta enriquecida de los agentes, /api/v2/agent/chat).
+const (
+	MessageText         = "text"
+	MessageImage        = "image"
+	MessageDocument     = "document"
+	MessageButtons      = "buttons"
+	MessageQuickReplies = "quick_replies"
+)
+
+// Message is one part of an agent reply (una burbuja en el canal).
+type Message struct {
+	Type     string   `json:"type"`
+	Text     string   `json:"text,omitempty"`     // text, buttons, quick_replies; caption en image y document
+	URL      string   `json:"url,omitempty"`      // image, document
+	Filename string   `json:"filename,omitempty"` // document
+	Buttons  []Button `json:"buttons,omitempty"`  // buttons, quick_replies
+}
+
+// Button is one option of a buttons or quick_replies message.
+type Button struct {
+	Title   string `json:"title"`
+	Payload string `json:"payload,omitempty"` // texto que se envia como mensaje al elegirlo (vacio = Title)
+	URL     string `json:"url,omitempty"`     // boton de enlace (solo en buttons)
+}
+
+// Valid reports whether m has the fields its type needs.
+func (m Message) Valid() bool {
+	switch m.Type {
+	case MessageText:
+		return strings.TrimSpace(m.Text) != ""
+	case MessageImage, MessageDocument:
+		return m.URL != ""
+	case MessageButtons, MessageQuickReplies:
+		if strings.TrimSpace(m.Text) == "" || len(m.Buttons) == 0 {
+			return false
+		}
+		for _, b := range m.Buttons {
+			if strings.TrimSpace(b.Title) == "" {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// LegacyMessages converts the v1 shape ({reply, url}) into messages: el texto y, si hay url,
+// una imagen o un documento segun la extension. reply vacio = sin mensajes (el agente no respondio).
+func LegacyMessages(reply string, url *string) []Message {
+	if strings.TrimSpace(reply) == "" {
+		return nil
+	}
+	msgs := []Message{{Type: MessageText, Text: reply}}
+	if url != nil && *url != "" {
+		msgs = append(msgs, MediaMessage(*url))
+	}
+	return msgs
+}
+
+// MediaMessage returns an image message for image extensions and a document otherwise.
+func MediaMessage(url string) Message {
+	p := url
+	if i := strings.IndexAny(p, "?#"); i >= 0 {
+		p = p[:i]
+	}
+	switch strings.ToLower(path.Ext(p)) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp":
+		return Message{Type: MessageImage, URL: url}
+	}
+	return Message{Type: MessageDocument, URL: url, Filename: path.Base(p)}
+}
+
+// Flatten reduces messages to the v1 shape: los textos (captions y opciones incluidas) unidos
+// por una linea en blanco y la primera imagen o documento en url; el resto de adjuntos va como link en el texto.
+func Flatten(msgs []Message) (reply string, url *string) {
+	var parts []string
+	for _, m := range msgs {
+		switch m.Type {
+		case MessageImage, MessageDocument:
+			if url == nil {
+				u := m.URL
+				url = &u
+				if m.Text != "" {
+					parts = append(parts, m.Text)
+				}
+				continue
+			}
+			if m.Text != "" {
+				parts = append(parts, m.Text+"\n"+m.URL)
+			} else {
+				parts = append(parts, m.URL)
+			}
+		case MessageButtons, MessageQuickReplies:
+			lines := []string{m.Text}
+			for _, b := range m.Buttons {
+				if b.URL != "" {
+					lines = append(lines, "- "+b.Title+": "+b.URL)
+				} else {
+					lines = append(lines, "- "+b.Title)
+				}
+			}
+			parts = append(parts, strings.Join(lines, "\n"))
+		default:
+			parts = append(parts, m.Text)
+		}
+	}
+	return strings.Join(parts, "\n\n"), url
+}
+
+// IsPlainText reports whether msgs is just one text message (lo que ya expresa el formato v1).
+func IsPlainText(msgs []Message) bool {
+	return len(msgs) == 1 && msgs[0].Type == MessageText
+}
@@ -0,0 +1,130 @@
+package grpcapi
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	gatewayv1 "gateway/api/gateway/v1"
+	"gateway/internal/middleware"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const requestIDKey = "x-request-id"
+
+// ---------------------------------------------------------------------------
+// Request ID (equivalente a middleware.RequestID)
+// ---------------------------------------------------------------------------
+
+// withRequestID toma x-request-id de la metadata o genera uno, lo guarda en ctx y lo devuelve en el header.
+func withRequestID(ctx context.Context) context.Context {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(requestIDKey); len(v) > 0 {
+			id = v[0]
+		}
+	}
+	if id == "" {
+		id = middleware.NewRequestID()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, id))
+	return middleware.WithRequestID(ctx, id)
+}
+
+func unaryRequestID(ctx context.Context, req any, _ *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+	return next(withRequestID(ctx), req)
+}
+
+func streamRequestID(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, next grpc.StreamHandler) error {
+	return next(srv, &contextStream{ServerStream: ss, ctx: withRequestID(ss.Context())})
+}
+
+// contextStream reemplaza el contexto de un ServerStream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context { return s.ctx }
+
+// ---------------------------------------------------------------------------
+// Access log (equivalente a middleware.Logger)
+// ---------------------------------------------------------------------------
+
+func logRPC(ctx context.Context, method string, start time.Time, err error) {
+	remote := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		remote = p.Addr.String()
+	}
+	slog.InfoContext(ctx, "grpc request",
+		"request_id", middleware.GetRequestID(ctx),
+		"method", method,
+		"code", status.Code(err).String(),
+		"duration_ms", time.Since(start).Milliseconds(),
+		"remote_addr", remote,
+	)
+}
+
+func unaryLogger(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := next(ctx, req)
+	logRPC(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+func streamLogger(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, next grpc.StreamHandler) error {
+	start := time.Now()
+	err := next(srv, ss)
+	logRPC(ss.Context(), info.FullMethod, start, err)
+	return err
+}
+
+// ---------------------------------------------------------------------------
+// Auth (Bearer, mismo chequeo que middleware.AdminAuth)
+// ---------------------------------------------------------------------------
+
+// public: metodos sin autenticacion (health y reflection, para probes y grpcurl).
+func public(method string) bool {
+	return method == gatewayv1.GatewayService_Health_FullMethodName ||
+		strings.HasPrefix(method, "/grpc.reflection.")
+}
+
+func authorize(ctx context.Context, tokens []string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, h := range md.Get("authorization") {
+		for _, t := range tokens {
+			if middleware.ValidBearer(h, t) {
+				return nil
+			}
+		}
+	}
+	return status.Error(codes.Unauthenticated, "unauthorized")
+}
+
+func unaryAuth(tokens []string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+		if len(tokens) > 0 && !public(info.FullMethod) {
+			if err := authorize(ctx, tokens); err != nil {
+				return nil, err
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+func streamAuth(tokens []string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, next grpc.StreamHandler) error {
+		if len(tokens) > 0 && !public(info.FullMethod) {
+			if err := authorize(ss.Context(), tokens); err != nil {
+				return err
+			}
+		}
+		return next(srv, ss)
+	}
+}
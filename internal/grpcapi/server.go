@@ -0,0 +1,177 @@
+// Package grpcapi sirve GatewayService (api/gateway/v1) sobre el mismo ChatHandler que HTTP:
+// routing, guardrails, circuit breakers, semaforos, metricas y transcript no cambian.
+package grpcapi
+
+import (
+	"context"
+	"crypto/tls"
+	"strings"
+
+	gatewayv1 "gateway/api/gateway/v1"
+	"gateway/internal/agent"
+	"gateway/internal/domain"
+	"gateway/internal/handler"
+	"gateway/internal/logging"
+	"gateway/internal/middleware"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// ChatService runs a chat exchange (implemented by handler.ChatHandler).
+type ChatService interface {
+	Chat(ctx context.Context, req *handler.ChatRequest) handler.ChatResponse
+	Validate(req *handler.ChatRequest, lang string) *handler.ErrorResponse
+}
+
+// Options configures the gRPC server.
+type Options struct {
+	// Tokens Bearer aceptados en la metadata "authorization". Vacio = sin autenticacion.
+	Tokens []string
+	// Reflection registra el servicio de reflection (grpcurl, grpcui).
+	Reflection bool
+	// TLS del listener; nil = texto plano.
+	TLS *tls.Config
+}
+
+// Server implements gatewayv1.GatewayServiceServer.
+type Server struct {
+	gatewayv1.UnimplementedGatewayServiceServer
+	chat   ChatService
+	route  agent.RouteFunc
+	health handler.HealthSource
+}
+
+// New builds the grpc.Server with GatewayService, request-ID, access log and auth interceptors.
+func New(chat ChatService, route agent.RouteFunc, health handler.HealthSource, o Options) *grpc.Server {
+	opts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(handler.MaxRequestBodyBytes),
+		grpc.ChainUnaryInterceptor(unaryRequestID, unaryLogger, unaryAuth(o.Tokens)),
+		grpc.ChainStreamInterceptor(streamRequestID, streamLogger, streamAuth(o.Tokens)),
+	}
+	if o.TLS != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(o.TLS)))
+	}
+	srv := grpc.NewServer(opts...)
+	gatewayv1.RegisterGatewayServiceServer(srv, &Server{chat: chat, route: route, health: health})
+	if o.Reflection {
+		reflection.Register(srv)
+	}
+	return srv
+}
+
+// Chat implements the unary RPC.
+func (s *Server) Chat(ctx context.Context, in *gatewayv1.ChatRequest) (*gatewayv1.ChatResponse, error) {
+	req := fromProto(in)
+	ctx = logging.WithTarget(ctx, req.IdEmpresa, req.SessionID)
+	if err := s.validate(ctx, req); err != nil {
+		return nil, err
+	}
+	resp := s.chat.Chat(ctx, req)
+	return toProto(resp), nil
+}
+
+// ChatStream implements the server-streaming RPC: Processing y luego la respuesta.
+func (s *Server) ChatStream(in *gatewayv1.ChatRequest, stream grpc.ServerStreamingServer[gatewayv1.ChatEvent]) error {
+	req := fromProto(in)
+	ctx := logging.WithTarget(stream.Context(), req.IdEmpresa, req.SessionID)
+	if err := s.validate(ctx, req); err != nil {
+		return err
+	}
+	err := stream.Send(&gatewayv1.ChatEvent{Event: &gatewayv1.ChatEvent_Processing{Processing: &gatewayv1.Processing{
+		RequestId: middleware.GetRequestID(ctx),
+		Agent:     s.route(req.Config.Modalidad),
+	}}})
+	if err != nil {
+		return err
+	}
+	resp := s.chat.Chat(ctx, req)
+	return stream.Send(&gatewayv1.ChatEvent{Event: &gatewayv1.ChatEvent_Reply{Reply: toProto(resp)}})
+}
+
+// Health implements the Health RPC con la cache del monitor (mismo criterio que GET /health).
+func (s *Server) Health(ctx context.Context, _ *gatewayv1.HealthRequest) (*gatewayv1.HealthResponse, error) {
+	agents := s.health.Snapshot(false)
+	ready, _ := s.health.Ready()
+	out := &gatewayv1.HealthResponse{
+		Status: handler.OverallStatus(agents, ready),
+		Ready:  ready,
+		Agents: make(map[string]*gatewayv1.AgentHealth, len(agents)),
+	}
+	for k, a := range agents {
+		out.Agents[k] = &gatewayv1.AgentHealth{
+			Status:              a.Status,
+			Critical:            a.Critical,
+			ConsecutiveFailures: int32(a.ConsecutiveFailures),
+			LatencyMs:           a.LatencyMs,
+			AvgLatencyMs:        a.AvgLatencyMs,
+		}
+	}
+	return out, nil
+}
+
+// validate devuelve InvalidArgument con errdetails.BadRequest (un FieldViolation por error).
+// El idioma sale de la metadata "accept-language", como en HTTP.
+func (s *Server) validate(ctx context.Context, req *handler.ChatRequest) error {
+	var acceptLanguage string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		acceptLanguage = strings.Join(md.Get("accept-language"), ",")
+	}
+	e := s.chat.Validate(req, handler.Lang(acceptLanguage))
+	if e == nil {
+		return nil
+	}
+	br := &errdetails.BadRequest{}
+	for _, f := range e.Errors {
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{Field: f.Field, Description: f.Message})
+	}
+	st, err := status.New(codes.InvalidArgument, e.Detail).WithDetails(br)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, e.Detail)
+	}
+	return st.Err()
+}
+
+func fromProto(in *gatewayv1.ChatRequest) *handler.ChatRequest {
+	c := in.GetConfig()
+	req := &handler.ChatRequest{
+		Message:   in.GetMessage(),
+		SessionID: int(in.GetSessionId()),
+		IdEmpresa: int(in.GetIdEmpresa()),
+		ApiKey:    in.GetApiKey(),
+		Config: handler.ChatConfig{
+			NombreBot:           c.GetNombreBot(),
+			Modalidad:           c.GetModalidad(),
+			FraseSaludo:         c.GetFraseSaludo(),
+			ArchivoSaludo:       c.GetArchivoSaludo(),
+			Personalidad:        c.GetPersonalidad(),
+			FraseDes:            c.GetFraseDes(),
+			FraseNoSabe:         c.GetFraseNoSabe(),
+			CorreoUsuario:       c.GetCorreoUsuario(),
+			DuracionCitaMinutos: int(c.GetDuracionCitaMinutos()),
+			Slots:               int(c.GetSlots()),
+			UsuarioID:           int(c.GetUsuarioId()),
+			IdChatbot:           int(c.GetIdChatbot()),
+		},
+	}
+	if c != nil && c.AgendarUsuario != nil {
+		req.Config.AgendarUsuario = domain.FlexBool{Valid: true, Value: c.GetAgendarUsuario()}
+	}
+	if c != nil && c.AgendarSucursal != nil {
+		req.Config.AgendarSucursal = domain.FlexBool{Valid: true, Value: c.GetAgendarSucursal()}
+	}
+	return req
+}
+
+func toProto(r handler.ChatResponse) *gatewayv1.ChatResponse {
+	out := &gatewayv1.ChatResponse{Reply: r.Reply, SessionId: int64(r.SessionID), Url: r.URL}
+	if r.AgentUsed != nil {
+		out.AgentUsed = *r.AgentUsed
+	}
+	return out
+}
@@ -0,0 +1,149 @@
+// Package guardrail aplica reglas de contenido alrededor de la llamada al agente:
+// sobre el mensaje entrante (largo, deny-list, caracteres de control) y sobre la respuesta
+// (patrones de fuga, dominios permitidos para las urls de imagenes, documentos y botones).
+package guardrail
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"gateway/internal/domain"
+	"gateway/internal/middleware"
+)
+
+// Etapas del pipeline (label "stage" de la metrica).
+const (
+	StageInbound = "inbound"
+	StageReply   = "reply"
+	StageURL     = "url"
+)
+
+// AgentCaller is the downstream caller being guarded (mismo contrato que handler.AgentCaller).
+type AgentCaller interface {
+	InvokeAgent(ctx context.Context, agent, message string, sessionID int, idEmpresa int, apiKey string, configMap map[string]interface{}) ([]domain.Message, error)
+}
+
+// Recorder records one guardrail hit.
+type Recorder interface {
+	RecordGuardrail(stage, rule, action string)
+}
+
+// Pipeline holds the rules for each stage. Un slice vacio = etapa sin reglas.
+type Pipeline struct {
+	Inbound []Rule // sobre message
+	Reply   []Rule // sobre los textos de la respuesta (text, captions, botones)
+	URL     []Rule // sobre las urls de la respuesta
+}
+
+// Empty reports whether the pipeline has no rules at all.
+func (p Pipeline) Empty() bool {
+	return len(p.Inbound) == 0 && len(p.Reply) == 0 && len(p.URL) == 0
+}
+
+// Caller wraps an AgentCaller with the guardrail pipeline. Implements handler.AgentCaller.
+type Caller struct {
+	next     AgentCaller
+	pipeline Pipeline
+	metrics  Recorder
+}
+
+// NewCaller returns next guarded by the pipeline.
+func NewCaller(next AgentCaller, p Pipeline, rec Recorder) *Caller {
+	return &Caller{next: next, pipeline: p, metrics: rec}
+}
+
+// InvokeAgent checks the message, calls the agent and checks every message of the reply.
+// Una regla reject de entrada (o un sanitize que deja el mensaje vacio) devuelve domain.ErrInputRejected sin llamar al agente;
+// una de salida devuelve domain.ErrOutputRejected (el handler responde con fallback).
+// Un mensaje que queda invalido al sanitizar (texto o url vacios) se descarta.
+func (c *Caller) InvokeAgent(ctx context.Context, agent, message string, sessionID int, idEmpresa int, apiKey string, configMap map[string]interface{}) ([]domain.Message, error) {
+	t := Target{Agent: agent, IdEmpresa: idEmpresa, SessionID: sessionID}
+
+	message, ok := c.run(ctx, StageInbound, c.pipeline.Inbound, message, t)
+	if !ok || strings.TrimSpace(message) == "" {
+		return nil, domain.ErrInputRejected
+	}
+
+	msgs, err := c.next.InvokeAgent(ctx, agent, message, sessionID, idEmpresa, apiKey, configMap)
+	if err != nil {
+		return msgs, err
+	}
+
+	out := make([]domain.Message, 0, len(msgs))
+	for _, m := range msgs {
+		m, ok := c.checkMessage(ctx, m, t)
+		if !ok {
+			return nil, domain.ErrOutputRejected
+		}
+		if m.Valid() {
+			out = append(out, m)
+		}
+	}
+	if len(out) == 0 {
+		return nil, domain.ErrOutputRejected
+	}
+	return out, nil
+}
+
+// checkMessage applies the reply rules to the texts of m and the url rules to its urls.
+func (c *Caller) checkMessage(ctx context.Context, m domain.Message, t Target) (domain.Message, bool) {
+	var ok bool
+	if m.Text != "" {
+		if m.Text, ok = c.run(ctx, StageReply, c.pipeline.Reply, m.Text, t); !ok {
+			return m, false
+		}
+	}
+	if m.URL != "" {
+		if m.URL, ok = c.run(ctx, StageURL, c.pipeline.URL, m.URL, t); !ok {
+			return m, false
+		}
+	}
+	if len(m.Buttons) > 0 {
+		buttons := make([]domain.Button, len(m.Buttons))
+		for i, b := range m.Buttons {
+			if b.Title, ok = c.run(ctx, StageReply, c.pipeline.Reply, b.Title, t); !ok {
+				return m, false
+			}
+			if b.URL != "" {
+				if b.URL, ok = c.run(ctx, StageURL, c.pipeline.URL, b.URL, t); !ok {
+					return m, false
+				}
+			}
+			buttons[i] = b
+		}
+		m.Buttons = buttons
+	}
+	return m, true
+}
+
+// run applies the rules in order. Devuelve el texto (posiblemente sanitizado) y false si una regla rechazo.
+func (c *Caller) run(ctx context.Context, stage string, rules []Rule, text string, t Target) (string, bool) {
+	for _, r := range rules {
+		sanitized, violated := r.Apply(text, t)
+		if !violated {
+			continue
+		}
+		action := r.Action()
+		if c.metrics != nil {
+			c.metrics.RecordGuardrail(stage, r.Name(), string(action))
+		}
+		slog.WarnContext(ctx, "guardrail",
+			"request_id", middleware.GetRequestID(ctx),
+			"stage", stage,
+			"rule", r.Name(),
+			"action", string(action),
+			"agent", t.Agent,
+			"id_empresa", t.IdEmpresa,
+			"session_id", t.SessionID,
+			"text_preview", domain.Preview(text, domain.DefaultPreviewLen),
+		)
+		switch action {
+		case ActionReject:
+			return "", false
+		case ActionSanitize:
+			text = sanitized
+		}
+	}
+	return text, true
+}
@@ -0,0 +1,213 @@
+package guardrail
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Action is what the pipeline does when a rule is violated.
+type Action string
+
+const (
+	ActionReject   Action = "reject"   // corta el flujo: el handler responde con fallback
+	ActionSanitize Action = "sanitize" // corrige el texto y sigue
+	ActionLog      Action = "log"      // solo registra (log + metrica)
+)
+
+// ParseAction converts an env value to Action. Vacio = regla deshabilitada (ok=false).
+func ParseAction(s string) (Action, bool, error) {
+	switch a := Action(strings.ToLower(strings.TrimSpace(s))); a {
+	case "":
+		return "", false, nil
+	case ActionReject, ActionSanitize, ActionLog:
+		return a, true, nil
+	default:
+		return "", false, fmt.Errorf("guardrail: unknown action %q (use reject, sanitize or log)", s)
+	}
+}
+
+// Target identifies the exchange being checked (para reglas por tenant).
+type Target struct {
+	Agent     string
+	IdEmpresa int
+	SessionID int
+}
+
+// Rule checks one piece of text. Apply returns the sanitized text and whether the rule was violated.
+// El texto sanitizado solo se usa cuando la accion de la regla es sanitize.
+type Rule interface {
+	Name() string
+	Action() Action
+	Apply(text string, t Target) (sanitized string, violated bool)
+}
+
+type baseRule struct {
+	name   string
+	action Action
+}
+
+func (b baseRule) Name() string   { return b.name }
+func (b baseRule) Action() Action { return b.action }
+
+// MaxLength rejects or truncates texts longer than max runes.
+type MaxLength struct {
+	baseRule
+	max int
+}
+
+// NewMaxLength returns a max_length rule.
+func NewMaxLength(max int, action Action) *MaxLength {
+	return &MaxLength{baseRule: baseRule{name: "max_length", action: action}, max: max}
+}
+
+// Apply implements Rule.
+func (r *MaxLength) Apply(text string, _ Target) (string, bool) {
+	if utf8.RuneCountInString(text) <= r.max {
+		return text, false
+	}
+	return string([]rune(text)[:r.max]), true
+}
+
+// ControlChars flags control characters other than newline, carriage return and tab.
+type ControlChars struct {
+	baseRule
+}
+
+// NewControlChars returns a control_chars rule.
+func NewControlChars(action Action) *ControlChars {
+	return &ControlChars{baseRule: baseRule{name: "control_chars", action: action}}
+}
+
+// Apply implements Rule.
+func (r *ControlChars) Apply(text string, _ Target) (string, bool) {
+	isBad := func(c rune) bool {
+		return (unicode.IsControl(c) || c == utf8.RuneError) && c != '\n' && c != '\r' && c != '\t'
+	}
+	if strings.IndexFunc(text, isBad) < 0 {
+		return text, false
+	}
+	return strings.Map(func(c rune) rune {
+		if isBad(c) {
+			return -1
+		}
+		return c
+	}, text), true
+}
+
+// Patterns flags texts matching any regex. Sanitize reemplaza cada coincidencia por replacement.
+// Se usa tanto para deny-list de entrada (prompt injection) como para fugas en la salida.
+type Patterns struct {
+	baseRule
+	res         []*regexp.Regexp
+	replacement string
+}
+
+// NewPatterns compiles the patterns into a rule with the given name.
+func NewPatterns(name string, patterns []string, replacement string, action Action) (*Patterns, error) {
+	r := &Patterns{baseRule: baseRule{name: name, action: action}, replacement: replacement}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("guardrail %s: pattern %q: %w", name, p, err)
+		}
+		r.res = append(r.res, re)
+	}
+	return r, nil
+}
+
+// Apply implements Rule.
+func (r *Patterns) Apply(text string, _ Target) (string, bool) {
+	violated := false
+	for _, re := range r.res {
+		if re.MatchString(text) {
+			violated = true
+			text = re.ReplaceAllString(text, r.replacement)
+		}
+	}
+	return text, violated
+}
+
+// URLAllowlist flags URLs whose host is not an allowed domain (or a subdomain of one).
+// Sanitize elimina la URL (texto vacio). Sin dominios para el tenant (ni globales) la regla no aplica.
+type URLAllowlist struct {
+	baseRule
+	global    []string
+	perTenant map[int][]string
+}
+
+// NewURLAllowlist returns a url_allowlist rule. perTenant entries replace the global list for that id_empresa.
+func NewURLAllowlist(global []string, perTenant map[int][]string, action Action) *URLAllowlist {
+	m := make(map[int][]string, len(perTenant))
+	for id, d := range perTenant {
+		m[id] = normalizeDomains(d)
+	}
+	return &URLAllowlist{
+		baseRule:  baseRule{name: "url_allowlist", action: action},
+		global:    normalizeDomains(global),
+		perTenant: m,
+	}
+}
+
+// Apply implements Rule.
+func (r *URLAllowlist) Apply(text string, t Target) (string, bool) {
+	if text == "" {
+		return text, false
+	}
+	allowed := r.global
+	if d, ok := r.perTenant[t.IdEmpresa]; ok {
+		allowed = d
+	}
+	if len(allowed) == 0 {
+		return text, false
+	}
+	u, err := url.Parse(strings.TrimSpace(text))
+	if err != nil || u.Hostname() == "" {
+		return "", true
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, d := range allowed {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return text, false
+		}
+	}
+	return "", true
+}
+
+func normalizeDomains(in []string) []string {
+	out := make([]string, 0, len(in))
+	for _, d := range in {
+		d = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "."))
+		if d != "" {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// LoadPatterns reads one regex per line. Lineas vacias y las que empiezan con # se ignoran.
+func LoadPatterns(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("guardrail: %w", err)
+	}
+	defer f.Close()
+	var out []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		out = append(out, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("guardrail: read %s: %w", path, err)
+	}
+	return out, nil
+}
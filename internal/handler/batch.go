@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"gateway/internal/domain"
+	"gateway/internal/logging"
+	"gateway/internal/middleware"
+)
+
+// BatchOptions configures POST /api/agent/chat/batch.
+type BatchOptions struct {
+	MaxItems     int           // items por lote
+	MaxBodyBytes int64         // body completo; cada item sigue limitado a MaxRequestBodyBytes
+	Concurrency  int           // items en paralelo dentro de un lote
+	MaxPerAgent  int           // items de lotes en curso por agente (sumando todos los lotes)
+	Timeout      time.Duration // deadline del lote completo
+}
+
+// BatchHandler handles POST /api/agent/chat/batch: varios ChatRequest en un solo request, con
+// resultado por item en el orden de entrada. Cada item pasa por ChatHandler.chat (metricas,
+// breakers, transcript). MaxPerAgent deja el resto del semaforo de cada agente al trafico en vivo.
+type BatchHandler struct {
+	chat *ChatHandler
+	opts BatchOptions
+
+	mu   sync.Mutex
+	sems map[string]chan struct{} // cupo de lotes por agente, compartido entre lotes
+}
+
+// NewBatchHandler creates a BatchHandler; valores <= 0 toman los defaults.
+func NewBatchHandler(chat *ChatHandler, opts BatchOptions) *BatchHandler {
+	if opts.MaxItems <= 0 {
+		opts.MaxItems = 100
+	}
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = 5 << 20
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+	if opts.MaxPerAgent <= 0 {
+		opts.MaxPerAgent = 5
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Minute
+	}
+	return &BatchHandler{chat: chat, opts: opts, sems: make(map[string]chan struct{})}
+}
+
+type batchRequest struct {
+	Items []json.RawMessage `json:"items"` // se decodifican por separado: un item invalido no invalida el lote
+}
+
+// BatchItemResult is the outcome of one item.
+type BatchItemResult struct {
+	Index    int            `json:"index"`
+	Status   string         `json:"status"`           // "ok" | "error"
+	Reason   string         `json:"reason,omitempty"` // motivo del fallback si code = agent_failed (timeout, backpressure...)
+	Response *ChatResponse  `json:"response,omitempty"`
+	Error    *ErrorResponse `json:"error,omitempty"`
+}
+
+// BatchResponse is the response of POST /api/agent/chat/batch (200 aunque fallen items).
+type BatchResponse struct {
+	Results []BatchItemResult `json:"results"`
+	OK      int               `json:"ok"`
+	Failed  int               `json:"failed"`
+}
+
+// ServeHTTP implements http.Handler.
+func (h *BatchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	lang := requestLang(r)
+	body := http.MaxBytesReader(w, r.Body, h.opts.MaxBodyBytes)
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, errorEnvelope(lang, CodeBatchTooLarge, strconv.FormatInt(h.opts.MaxBodyBytes>>10, 10)+" KB"))
+			return
+		}
+		slog.DebugContext(r.Context(), "batch read error", "err", err)
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON)
+		return
+	}
+	var in batchRequest
+	typeVs, err := decodeTolerant(data, &in)
+	if err != nil {
+		slog.DebugContext(r.Context(), "batch decode error", "err", err)
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON)
+		return
+	}
+	if len(in.Items) == 0 {
+		writeValidationError(w, r, withTypeViolations(typeVs, []violation{{field: "items", code: CodeRequired}}))
+		return
+	}
+	if len(in.Items) > h.opts.MaxItems {
+		writeJSON(w, http.StatusRequestEntityTooLarge, errorEnvelope(lang, CodeBatchTooLarge, strconv.Itoa(h.opts.MaxItems)+" items"))
+		return
+	}
+	middleware.Annotate(r.Context(), "batch_items", len(in.Items))
+
+	// El lote puede durar mas que GATEWAY_WRITE_TIMEOUT_SEC: se extiende el deadline de escritura solo para este request.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(h.opts.Timeout + 5*time.Second))
+	ctx, cancel := context.WithTimeout(r.Context(), h.opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	results := make([]BatchItemResult, len(in.Items))
+	reqs := make([]*ChatRequest, len(in.Items))
+	for i, raw := range in.Items {
+		results[i].Index = i
+		req, e := h.decodeItem(lang, raw)
+		if e != nil {
+			results[i].Status, results[i].Error = "error", e
+			continue
+		}
+		reqs[i] = req
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(h.opts.Concurrency, len(in.Items)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = h.run(ctx, lang, i, reqs[i])
+			}
+		}()
+	}
+	for i, req := range reqs {
+		if req != nil {
+			next <- i
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	resp := BatchResponse{Results: results}
+	for _, res := range results {
+		if res.Status == "ok" {
+			resp.OK++
+		} else {
+			resp.Failed++
+		}
+	}
+	slog.InfoContext(r.Context(), "batch done",
+		"request_id", middleware.GetRequestID(r.Context()),
+		"items", len(results),
+		"ok", resp.OK,
+		"failed", resp.Failed,
+		"duration_ms", time.Since(start).Milliseconds(),
+	)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// decodeItem parses and validates one item. Los errores usan el mismo sobre que POST /api/agent/chat.
+func (h *BatchHandler) decodeItem(lang string, raw json.RawMessage) (*ChatRequest, *ErrorResponse) {
+	if len(raw) > MaxRequestBodyBytes {
+		e := errorEnvelope(lang, CodeBodyTooLarge, "")
+		return nil, &e
+	}
+	var req ChatRequest
+	typeVs, err := decodeTolerant(raw, &req)
+	if err != nil {
+		e := errorEnvelope(lang, CodeInvalidJSON, "")
+		return nil, &e
+	}
+	if vs := withTypeViolations(typeVs, validateChatRequest(&req, h.chat.Router, h.chat.StrictConfig)); len(vs) > 0 {
+		e := validationEnvelope(lang, vs)
+		return nil, &e
+	}
+	return &req, nil
+}
+
+// run executes item i. Cada item tiene request ID propio ("<request_id>-<i>") para logs y transcript.
+func (h *BatchHandler) run(ctx context.Context, lang string, i int, req *ChatRequest) BatchItemResult {
+	res := BatchItemResult{Index: i, Status: "error"}
+	itemCtx := middleware.WithoutAnnotations(ctx)
+	itemCtx = middleware.WithRequestID(itemCtx, fmt.Sprintf("%s-%d", middleware.GetRequestID(ctx), i))
+	itemCtx = logging.WithTarget(itemCtx, req.IdEmpresa, req.SessionID)
+
+	sem := h.agentSem(h.chat.Router(req.Config.Modalidad))
+	select {
+	case sem <- struct{}{}:
+		defer func() { <-sem }()
+	case <-ctx.Done():
+		// Deadline del lote vencido (o cliente desconectado) antes de llegar al agente.
+		e := errorEnvelope(lang, CodeAgentFailed, domain.ReasonTimeout)
+		res.Reason, res.Error = domain.ReasonTimeout, &e
+		return res
+	}
+
+	resp, reason := h.chat.chat(itemCtx, req, h.chat.AgentTimeout)
+	if reason != "" {
+		e := errorEnvelope(lang, CodeAgentFailed, reason)
+		res.Reason, res.Error = reason, &e
+		return res
+	}
+	res.Status, res.Response = "ok", &resp
+	return res
+}
+
+func (h *BatchHandler) agentSem(agent string) chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sem, ok := h.sems[agent]
+	if !ok {
+		sem = make(chan struct{}, h.opts.MaxPerAgent)
+		h.sems[agent] = sem
+	}
+	return sem
+}
@@ -1,220 +1,379 @@
-package handler
-
-import (
-	"context"
-	"encoding/json"
-	"errors"
-	"log/slog"
-	"net/http"
-	"strings"
-	"time"
-
-	"gateway/internal/agent"
-	"gateway/internal/domain"
-	"gateway/internal/middleware"
-)
-
-// MaxRequestBodyBytes es el limite de tamano del body para POST /api/agent/chat (mitiga DoS por bodies enormes).
-const MaxRequestBodyBytes = 512 * 1024 // 512 KB
-
-const fallbackReply = "No pude conectar con el agente. Intenta de nuevo en un momento."
-const emptyReplyMsg = "El agente especializado no pudo generar una respuesta. Intenta de nuevo."
-
-// AgentCaller invokes a downstream agent.
-type AgentCaller interface {
-	InvokeAgent(ctx context.Context, agent, message string, sessionID int, idEmpresa int, apiKey string, configMap map[string]interface{}) (reply string, url *string, err error)
-}
-
-// MetricsRecorder records request metrics.
-type MetricsRecorder interface {
-	Record(agent, status string, duration time.Duration)
-}
-
-// ---------------------------------------------------------------------------
-// Structs de request / response
-// ---------------------------------------------------------------------------
-
-// ChatRequest matches the orquestador contract from n8n.
-type ChatRequest struct {
-	Message   string     `json:"message"`
-	SessionID int        `json:"session_id"`
-	IdEmpresa int        `json:"id_empresa"`
-	ApiKey    string     `json:"api_key"`
-	Config    ChatConfig `json:"config"`
-}
-
-// ChatConfig is the config object inside ChatRequest.
-// Los campos opcionales de tipo bool usan FlexBool para tolerar string, numero o bool de n8n.
-type ChatConfig struct {
-	NombreBot string `json:"nombre_bot"`
-	Modalidad string `json:"modalidad"`
-	FraseSaludo   string `json:"frase_saludo"`
-	ArchivoSaludo string `json:"archivo_saludo"`
-	Personalidad  string `json:"personalidad"`
-	FraseDes      string `json:"frase_des"`
-	FraseNoSabe   string `json:"frase_no_sabe"`
-	CorreoUsuario string `json:"correo_usuario,omitempty"`
-	// Campos opcionales que n8n puede enviar como string, numero o bool
-	DuracionCitaMinutos int             `json:"duracion_cita_minutos"`
-	Slots               int             `json:"slots"`
-	AgendarUsuario      domain.FlexBool `json:"agendar_usuario"`
-	AgendarSucursal     domain.FlexBool `json:"agendar_sucursal"`
-	UsuarioID           int             `json:"usuario_id"`
-	IdChatbot           int             `json:"id_chatbot"`
-}
-
-// ChatResponse matches the orquestador response to n8n.
-type ChatResponse struct {
-	Reply     string  `json:"reply"`
-	SessionID int     `json:"session_id"`
-	AgentUsed *string `json:"agent_used,omitempty"`
-	URL       *string `json:"url"`
-}
-
-// ChatHandler handles POST /api/agent/chat.
-type ChatHandler struct {
-	Caller       AgentCaller
-	Router       agent.RouteFunc // maps modalidad → agent key
-	AgentTimeout time.Duration
-	Metrics      MetricsRecorder
-}
-
-// ServeHTTP implements http.Handler.
-func (h *ChatHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Limitar tamano del body por peticion para evitar DoS (bodies de MB/GB).
-	body := http.MaxBytesReader(w, r.Body, MaxRequestBodyBytes)
-	defer body.Close()
-
-	var req ChatRequest
-	if err := json.NewDecoder(body).Decode(&req); err != nil {
-		var maxBytesErr *http.MaxBytesError
-		if errors.As(err, &maxBytesErr) {
-			writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"detail": "Body demasiado grande (max. 512 KB)"})
-			return
-		}
-		slog.Debug("chat decode error", "err", err)
-		writeJSON(w, http.StatusBadRequest, map[string]string{"detail": "JSON invalido"})
-		return
-	}
-
-	// Validation (same as orquestador)
-	if strings.TrimSpace(req.Message) == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"detail": "El campo 'message' no puede estar vacio"})
-		return
-	}
-	if req.SessionID <= 0 {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"detail": "El campo 'session_id' debe ser un entero mayor a 0"})
-		return
-	}
-	if strings.TrimSpace(req.ApiKey) == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"detail": "El campo 'api_key' no puede estar vacio"})
-		return
-	}
-	if strings.TrimSpace(req.Config.Modalidad) == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"detail": "El campo 'config.modalidad' no puede estar vacio"})
-		return
-	}
-	if req.IdEmpresa <= 0 {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"detail": "El campo 'id_empresa' debe ser un numero mayor a 0"})
-		return
-	}
-
-	agent := h.Router(req.Config.Modalidad)
-	if agent == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"detail": "Modalidad no reconocida: " + req.Config.Modalidad})
-		return
-	}
-	configMap := configToMap(req.Config)
-
-	// Log de entrada: que llega al gateway y a donde se deriva.
-	rid := middleware.GetRequestID(r.Context())
-	slog.Info("→ request entrada",
-		"request_id", rid,
-		"modalidad", req.Config.Modalidad,
-		"agent", agent,
-		"session_id", req.SessionID,
-		"id_empresa", req.IdEmpresa,
-		"id_chatbot", req.Config.IdChatbot,
-		"message_preview", domain.Preview(req.Message, domain.DefaultPreviewLen),
-	)
-
-	agentCtx, cancel := context.WithTimeout(r.Context(), h.AgentTimeout)
-	defer cancel()
-
-	start := time.Now()
-	reply, url, err := h.Caller.InvokeAgent(agentCtx, agent, req.Message, req.SessionID, req.IdEmpresa, req.ApiKey, configMap)
-	elapsed := time.Since(start)
-
-	status := "ok"
-	if err != nil {
-		status = "error"
-	}
-	h.Metrics.Record(agent, status, elapsed)
-
-	if err != nil {
-		slog.Warn("agent invoke failed", "request_id", rid, "agent", agent, "session_id", req.SessionID, "err", err, "duration_ms", elapsed.Milliseconds())
-		fallback := fallbackReply
-		if errors.Is(err, domain.ErrEmptyReply) {
-			fallback = emptyReplyMsg
-		}
-		slog.Info("← respuesta n8n (fallback)",
-			"request_id", rid,
-			"agent", agent,
-			"session_id", req.SessionID,
-			"status", "fallback",
-			"reply_preview", domain.Preview(fallback, domain.DefaultPreviewLen),
-		)
-		writeJSON(w, http.StatusOK, ChatResponse{
-			Reply:     fallback,
-			SessionID: req.SessionID,
-			AgentUsed: &agent,
-			URL:       nil,
-		})
-		return
-	}
-
-	slog.Info("← respuesta n8n (ok)",
-		"request_id", rid,
-		"agent", agent,
-		"session_id", req.SessionID,
-		"duration_ms", elapsed.Milliseconds(),
-		"reply_preview", domain.Preview(reply, domain.DefaultPreviewLen),
-	)
-	resp := ChatResponse{
-		Reply:     reply,
-		SessionID: req.SessionID,
-		AgentUsed: &agent,
-		URL:       url,
-	}
-	writeJSON(w, http.StatusOK, resp)
-}
-
-func configToMap(c ChatConfig) map[string]interface{} {
-	m := map[string]interface{}{
-		"nombre_bot":     c.NombreBot,
-		"frase_saludo":   c.FraseSaludo,
-		"archivo_saludo": c.ArchivoSaludo,
-		"personalidad":   c.Personalidad,
-		"frase_des":      c.FraseDes,
-		"frase_no_sabe":  c.FraseNoSabe,
-		"modalidad":      c.Modalidad,
-		"correo_usuario": c.CorreoUsuario,
-	}
-	m["duracion_cita_minutos"] = c.DuracionCitaMinutos
-	m["slots"] = c.Slots
-	if c.AgendarUsuario.Valid {
-		m["agendar_usuario"] = c.AgendarUsuario.Value
-	}
-	if c.AgendarSucursal.Valid {
-		m["agendar_sucursal"] = c.AgendarSucursal.Value
-	}
-	m["usuario_id"] = c.UsuarioID
-	m["id_chatbot"] = c.IdChatbot
-	return m
-}
-
-func writeJSON(w http.ResponseWriter, status int, v interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	_ = json.NewEncoder(w).Encode(v)
-}
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"gateway/internal/agent"
+	"gateway/internal/domain"
+	"gateway/internal/logging"
+	"gateway/internal/middleware"
+	"gateway/internal/transcript"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("gateway/internal/handler")
+
+// MaxRequestBodyBytes es el limite de tamano del body para POST /api/agent/chat (mitiga DoS por bodies enormes).
+const MaxRequestBodyBytes = 512 * 1024 // 512 KB
+
+const fallbackReply = "No pude conectar con el agente. Intenta de nuevo en un momento."
+const emptyReplyMsg = "El agente especializado no pudo generar una respuesta. Intenta de nuevo."
+const rejectedInputMsg = "No pude procesar tu mensaje. Intenta escribirlo de otra forma o de manera mas breve."
+const rejectedOutputMsg = "No puedo darte una respuesta a ese mensaje. Intenta con otra consulta."
+
+// HeaderFallbackReason lleva el motivo (domain.Reason*) cuando /api/agent/chat responde 200 con un
+// fallback del gateway en vez de la respuesta del agente. Sin el header, la respuesta es del agente.
+const HeaderFallbackReason = "X-Gateway-Fallback-Reason"
+
+// AgentCaller invokes a downstream agent.
+type AgentCaller interface {
+	InvokeAgent(ctx context.Context, agent, message string, sessionID int, idEmpresa int, apiKey string, configMap map[string]interface{}) ([]domain.Message, error)
+}
+
+// MetricsRecorder records request metrics.
+type MetricsRecorder interface {
+	Record(agent, status string, idEmpresa int, duration time.Duration)
+	RecordFallback(agent, reason string)
+}
+
+// ---------------------------------------------------------------------------
+// Structs de request / response
+// ---------------------------------------------------------------------------
+
+// ChatRequest matches the orquestador contract from n8n.
+type ChatRequest struct {
+	Message   string     `json:"message"`
+	SessionID int        `json:"session_id"`
+	IdEmpresa int        `json:"id_empresa"`
+	ApiKey    string     `json:"api_key"`
+	Config    ChatConfig `json:"config"`
+}
+
+// ChatConfig is the config object inside ChatRequest.
+// Los campos opcionales de tipo bool usan FlexBool para tolerar string, numero o bool de n8n.
+type ChatConfig struct {
+	NombreBot string `json:"nombre_bot"`
+	Modalidad string `json:"modalidad"`
+	FraseSaludo   string `json:"frase_saludo"`
+	ArchivoSaludo string `json:"archivo_saludo"`
+	Personalidad  string `json:"personalidad"`
+	FraseDes      string `json:"frase_des"`
+	FraseNoSabe   string `json:"frase_no_sabe"`
+	CorreoUsuario string `json:"correo_usuario,omitempty"`
+	// Campos opcionales que n8n puede enviar como string, numero o bool
+	DuracionCitaMinutos int             `json:"duracion_cita_minutos"`
+	Slots               int             `json:"slots"`
+	AgendarUsuario      domain.FlexBool `json:"agendar_usuario"`
+	AgendarSucursal     domain.FlexBool `json:"agendar_sucursal"`
+	UsuarioID           int             `json:"usuario_id"`
+	IdChatbot           int             `json:"id_chatbot"`
+}
+
+// ChatResponse matches the orquestador response to n8n.
+// Si el agente responde con varios mensajes se aplanan (domain.Flatten).
+type ChatResponse struct {
+	Reply     string  `json:"reply"`
+	SessionID int     `json:"session_id"`
+	AgentUsed *string `json:"agent_used,omitempty"`
+	URL       *string `json:"url"`
+}
+
+// ChatResponseV2 is the response of POST /api/v2/agent/chat: la respuesta completa del agente
+// (burbujas, imagenes, documentos, botones). Un agente con formato {reply,url} llega como text + image/document.
+type ChatResponseV2 struct {
+	Messages  []domain.Message `json:"messages"`
+	SessionID int              `json:"session_id"`
+	AgentUsed *string          `json:"agent_used,omitempty"`
+}
+
+// ChatHandler handles POST /api/agent/chat and POST /api/v2/agent/chat.
+type ChatHandler struct {
+	Caller       AgentCaller
+	Router       agent.RouteFunc // maps modalidad → agent key
+	AgentTimeout time.Duration
+	Metrics      MetricsRecorder
+	Transcripts  transcript.Sink // nil = sin transcript
+	Async        *AsyncOptions  // nil = modo async deshabilitado
+	StrictConfig bool           // rechaza negativos en los campos numericos de config (VALIDATION_STRICT_CONFIG)
+}
+
+// ServeHTTP implements http.Handler (v1: respuesta ChatResponse).
+func (h *ChatHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, false)
+}
+
+// ServeV2 handles POST /api/v2/agent/chat: mismo request que v1, respuesta ChatResponseV2.
+func (h *ChatHandler) ServeV2(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, true)
+}
+
+func (h *ChatHandler) serve(w http.ResponseWriter, r *http.Request, v2 bool) {
+	// Limitar tamano del body por peticion para evitar DoS (bodies de MB/GB).
+	body := http.MaxBytesReader(w, r.Body, MaxRequestBodyBytes)
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeError(w, r, http.StatusRequestEntityTooLarge, CodeBodyTooLarge)
+			return
+		}
+		slog.DebugContext(r.Context(), "chat read error", "err", err)
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON)
+		return
+	}
+	var in chatEnvelope
+	typeVs, err := decodeTolerant(data, &in)
+	if err != nil {
+		slog.DebugContext(r.Context(), "chat decode error", "err", err)
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON)
+		return
+	}
+	req := in.ChatRequest
+	async := in.Async.Value || in.CallbackURL != ""
+
+	middleware.Annotate(r.Context(), "id_empresa", req.IdEmpresa)
+	// Overrides de debug por tenant/sesion: los logs *Context del resto del request los ven.
+	r = r.WithContext(logging.WithTarget(r.Context(), req.IdEmpresa, req.SessionID))
+
+	// Validation (same as orquestador): se reportan todas las violaciones juntas.
+	_, span := tracer.Start(r.Context(), "chat.validate")
+	vs := withTypeViolations(typeVs, validateChatRequest(&req, h.Router, h.StrictConfig))
+	if async {
+		vs = append(vs, h.validateAsync(in.CallbackURL)...)
+	}
+	span.SetAttributes(attribute.Int("violations", len(vs)))
+	span.End()
+	if len(vs) > 0 {
+		writeValidationError(w, r, vs)
+		return
+	}
+
+	if async {
+		h.submit(w, r, &req, in.CallbackURL, v2)
+		return
+	}
+	if v2 {
+		resp, reason := h.chatV2(r.Context(), &req, h.AgentTimeout)
+		setFallbackReason(w, reason)
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	resp, reason := h.chat(r.Context(), &req, h.AgentTimeout)
+	setFallbackReason(w, reason)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// setFallbackReason marca la respuesta como fallback (HeaderFallbackReason); reason vacio = no hace nada.
+func setFallbackReason(w http.ResponseWriter, reason string) {
+	if reason != "" {
+		w.Header().Set(HeaderFallbackReason, reason)
+	}
+}
+
+// Chat routes a validated request, invokes the agent and builds the response (fallback incluido).
+// Compartido por POST /api/agent/chat y el WebSocket: mismas metricas, breakers, backpressure y transcript.
+func (h *ChatHandler) Chat(ctx context.Context, req *ChatRequest) ChatResponse {
+	resp, _ := h.chat(ctx, req, h.AgentTimeout)
+	return resp
+}
+
+// ChatV2 is Chat with the reply as messages (sin aplanar).
+func (h *ChatHandler) ChatV2(ctx context.Context, req *ChatRequest) ChatResponseV2 {
+	resp, _ := h.chatV2(ctx, req, h.AgentTimeout)
+	return resp
+}
+
+// chat is Chat plus the fallback reason ("" = respuesta del agente), para quien reporta errores por item.
+// timeout limita la llamada al agente (AgentTimeout, o el deadline de los jobs async).
+func (h *ChatHandler) chat(ctx context.Context, req *ChatRequest, timeout time.Duration) (ChatResponse, string) {
+	msgs, agent, reason := h.exchange(ctx, req, timeout)
+	reply, url := domain.Flatten(msgs)
+	return ChatResponse{
+		Reply:     reply,
+		SessionID: req.SessionID,
+		AgentUsed: &agent,
+		URL:       url,
+	}, reason
+}
+
+// chatV2 is ChatV2 plus the fallback reason.
+func (h *ChatHandler) chatV2(ctx context.Context, req *ChatRequest, timeout time.Duration) (ChatResponseV2, string) {
+	msgs, agent, reason := h.exchange(ctx, req, timeout)
+	return ChatResponseV2{
+		Messages:  msgs,
+		SessionID: req.SessionID,
+		AgentUsed: &agent,
+	}, reason
+}
+
+// ChatAgent is ChatV2 addressed to an agent by key (sin routing por modalidad) plus the fallback
+// reason ("" = respuesta del agente). Para transportes que eligen el agente (MCP: un tool por agente).
+func (h *ChatHandler) ChatAgent(ctx context.Context, agent string, req *ChatRequest) (ChatResponseV2, string) {
+	msgs, reason := h.invoke(ctx, req, agent, h.AgentTimeout)
+	return ChatResponseV2{
+		Messages:  msgs,
+		SessionID: req.SessionID,
+		AgentUsed: &agent,
+	}, reason
+}
+
+// exchange routes the request and invokes the agent. Devuelve los mensajes de la respuesta
+// (o el texto de fallback), el agente y el motivo del fallback ("" = respuesta del agente).
+func (h *ChatHandler) exchange(ctx context.Context, req *ChatRequest, timeout time.Duration) ([]domain.Message, string, string) {
+	_, span := tracer.Start(ctx, "chat.route", trace.WithAttributes(attribute.String("modalidad", req.Config.Modalidad)))
+	agent := h.Router(req.Config.Modalidad)
+	span.SetAttributes(attribute.String("agent", agent))
+	span.End()
+	msgs, reason := h.invoke(ctx, req, agent, timeout)
+	return msgs, agent, reason
+}
+
+// invoke calls agent with req: metricas, fallback, logs y transcript.
+func (h *ChatHandler) invoke(ctx context.Context, req *ChatRequest, agent string, timeout time.Duration) ([]domain.Message, string) {
+	middleware.Annotate(ctx, "agent", agent)
+	configMap := configToMap(req.Config)
+
+	// Log de entrada: que llega al gateway y a donde se deriva.
+	rid := middleware.GetRequestID(ctx)
+	slog.InfoContext(ctx, "→ request entrada",
+		"request_id", rid,
+		"modalidad", req.Config.Modalidad,
+		"agent", agent,
+		"session_id", req.SessionID,
+		"id_empresa", req.IdEmpresa,
+		"id_chatbot", req.Config.IdChatbot,
+		"message_preview", domain.Preview(req.Message, domain.DefaultPreviewLen),
+	)
+
+	agentCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	msgs, err := h.Caller.InvokeAgent(agentCtx, agent, req.Message, req.SessionID, req.IdEmpresa, req.ApiKey, configMap)
+	elapsed := time.Since(start)
+
+	// Un rechazo de guardrail (entrada o salida) no es un error del agente: metricas y transcript lo cuentan aparte.
+	rejected := errors.Is(err, domain.ErrInputRejected) || errors.Is(err, domain.ErrOutputRejected)
+	status := "ok"
+	switch {
+	case rejected:
+		status = "rejected"
+	case err != nil:
+		status = "error"
+	}
+	h.Metrics.Record(agent, status, req.IdEmpresa, elapsed)
+
+	if err != nil {
+		reason := domain.FallbackReason(err)
+		h.Metrics.RecordFallback(agent, reason)
+		middleware.Annotate(ctx, "fallback_reason", reason)
+		slog.WarnContext(ctx, "agent invoke failed", "request_id", rid, "agent", agent, "session_id", req.SessionID, "reason", reason, "err", err, "duration_ms", elapsed.Milliseconds())
+		fallback := fallbackReply
+		switch {
+		case errors.Is(err, domain.ErrEmptyReply):
+			fallback = emptyReplyMsg
+		case errors.Is(err, domain.ErrInputRejected):
+			fallback = rejectedInputMsg
+		case errors.Is(err, domain.ErrOutputRejected):
+			fallback = rejectedOutputMsg
+		}
+		outcome := transcript.OutcomeFallback
+		if rejected {
+			outcome = transcript.OutcomeRejected
+		}
+		msgs = []domain.Message{{Type: domain.MessageText, Text: fallback}}
+		h.record(ctx, req, agent, msgs, elapsed, outcome, reason)
+		slog.InfoContext(ctx, "← respuesta n8n (fallback)",
+			"request_id", rid,
+			"agent", agent,
+			"session_id", req.SessionID,
+			"status", "fallback",
+			"reply_preview", domain.Preview(fallback, domain.DefaultPreviewLen),
+		)
+		return msgs, reason
+	}
+
+	h.record(ctx, req, agent, msgs, elapsed, transcript.OutcomeOK, "")
+	reply, _ := domain.Flatten(msgs)
+	slog.InfoContext(ctx, "← respuesta n8n (ok)",
+		"request_id", rid,
+		"agent", agent,
+		"session_id", req.SessionID,
+		"duration_ms", elapsed.Milliseconds(),
+		"messages", len(msgs),
+		"reply_preview", domain.Preview(reply, domain.DefaultPreviewLen),
+	)
+	return msgs, ""
+}
+
+// record sends the exchange to the transcript sink (si esta configurado).
+// reply/url guardan la version aplanada; messages, la respuesta completa si no era un solo texto.
+func (h *ChatHandler) record(ctx context.Context, req *ChatRequest, agent string, msgs []domain.Message, elapsed time.Duration, outcome, reason string) {
+	if h.Transcripts == nil {
+		return
+	}
+	reply, url := domain.Flatten(msgs)
+	rec := transcript.Record{
+		Time:      time.Now().UTC(),
+		RequestID: middleware.GetRequestID(ctx),
+		IdEmpresa: req.IdEmpresa,
+		SessionID: req.SessionID,
+		Modalidad: req.Config.Modalidad,
+		Agent:     agent,
+		Message:   req.Message,
+		Config:    configToMap(req.Config),
+		Reply:     reply,
+		URL:       url,
+		LatencyMs: elapsed.Milliseconds(),
+		Outcome:   outcome,
+		Reason:    reason,
+	}
+	if !domain.IsPlainText(msgs) {
+		rec.Messages = msgs
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		rec.TraceID = sc.TraceID().String()
+	}
+	h.Transcripts.Write(rec)
+}
+
+func configToMap(c ChatConfig) map[string]interface{} {
+	m := map[string]interface{}{
+		"nombre_bot":     c.NombreBot,
+		"frase_saludo":   c.FraseSaludo,
+		"archivo_saludo": c.ArchivoSaludo,
+		"personalidad":   c.Personalidad,
+		"frase_des":      c.FraseDes,
+		"frase_no_sabe":  c.FraseNoSabe,
+		"modalidad":      c.Modalidad,
+		"correo_usuario": c.CorreoUsuario,
+	}
+	m["duracion_cita_minutos"] = c.DuracionCitaMinutos
+	m["slots"] = c.Slots
+	if c.AgendarUsuario.Valid {
+		m["agendar_usuario"] = c.AgendarUsuario.Value
+	}
+	if c.AgendarSucursal.Valid {
+		m["agendar_sucursal"] = c.AgendarSucursal.Value
+	}
+	m["usuario_id"] = c.UsuarioID
+	m["id_chatbot"] = c.IdChatbot
+	return m
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"time"
+
+	"gateway/internal/agent"
+	"gateway/internal/config"
+)
+
+// BuildInfo is the response of GET /debug/buildinfo.
+type BuildInfo struct {
+	GoVersion    string    `json:"go_version"`
+	Module       string    `json:"module,omitempty"`
+	Version      string    `json:"version,omitempty"`
+	Revision     string    `json:"vcs_revision,omitempty"`
+	RevisionTime string    `json:"vcs_time,omitempty"`
+	Modified     bool      `json:"vcs_modified,omitempty"`
+	OS           string    `json:"os"`
+	Arch         string    `json:"arch"`
+	NumCPU       int       `json:"num_cpu"`
+	Goroutines   int       `json:"goroutines"`
+	StartedAt    time.Time `json:"started_at"`
+	UptimeSec    int64     `json:"uptime_sec"`
+	Deps         []string  `json:"deps,omitempty"` // "path@version"
+}
+
+// BuildInfoHandler serves GET /debug/buildinfo: version, VCS revision and dependencies embedded by the Go toolchain.
+func BuildInfoHandler(startedAt time.Time) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := BuildInfo{
+			GoVersion:  runtime.Version(),
+			OS:         runtime.GOOS,
+			Arch:       runtime.GOARCH,
+			NumCPU:     runtime.NumCPU(),
+			Goroutines: runtime.NumGoroutine(),
+			StartedAt:  startedAt.UTC(),
+			UptimeSec:  int64(time.Since(startedAt).Seconds()),
+		}
+		if bi, ok := debug.ReadBuildInfo(); ok {
+			info.Module = bi.Main.Path
+			info.Version = bi.Main.Version
+			for _, s := range bi.Settings {
+				switch s.Key {
+				case "vcs.revision":
+					info.Revision = s.Value
+				case "vcs.time":
+					info.RevisionTime = s.Value
+				case "vcs.modified":
+					info.Modified = s.Value == "true"
+				}
+			}
+			for _, d := range bi.Deps {
+				info.Deps = append(info.Deps, d.Path+"@"+d.Version)
+			}
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, info)
+	})
+}
+
+// GoroutinesHandler serves GET /debug/goroutines: stack de todas las goroutines en texto plano
+// (equivale a /debug/pprof/goroutine?debug=2).
+func GoroutinesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = pprof.Lookup("goroutine").WriteTo(w, 2)
+	})
+}
+
+// AgentLister provides the registered agents (implemented by agent.Registry).
+type AgentLister interface {
+	All() []agent.AgentInfo
+}
+
+type configAgent struct {
+	URL           string `json:"url"`
+	Enabled       bool   `json:"enabled"`
+	HealthURL     string `json:"health_url,omitempty"`
+	ForwardAPIKey bool   `json:"forward_api_key"`
+	TLSCAFile     string `json:"tls_ca_file,omitempty"`
+	TLSCertFile   string `json:"tls_cert_file,omitempty"`
+	TLSKeyFile    string `json:"tls_key_file,omitempty"`
+	TLSServerName string `json:"tls_server_name,omitempty"`
+}
+
+type configResponse struct {
+	Config map[string]any         `json:"config"`
+	Agents map[string]configAgent `json:"agents"`
+}
+
+// ConfigHandler serves GET /debug/config: la configuracion efectiva con secretos y passwords de URLs redactados.
+func ConfigHandler(cfg *config.Config, agents AgentLister) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := configResponse{Config: cfg.Redacted(), Agents: make(map[string]configAgent)}
+		for _, a := range agents.All() {
+			resp.Agents[a.Key] = configAgent{
+				URL:           config.RedactURL(a.URL),
+				Enabled:       a.Enabled,
+				HealthURL:     config.RedactURL(a.HealthURL),
+				ForwardAPIKey: a.ForwardAPIKey,
+				TLSCAFile:     a.TLS.CAFile,
+				TLSCertFile:   a.TLS.CertFile,
+				TLSKeyFile:    a.TLS.KeyFile,
+				TLSServerName: a.TLS.ServerName,
+			}
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, resp)
+	})
+}
@@ -1,101 +1,84 @@
-package handler
-
-import (
-	"encoding/json"
-	"io"
-	"net/http"
-	"sync"
-	"time"
-
-	"gateway/internal/agent"
-
-	"github.com/prometheus/client_golang/prometheus/promhttp"
-)
-
-const healthCheckTimeout = 2 * time.Second
-
-// AgentLister provides the list of agents for health checks.
-type AgentLister interface {
-	All() []agent.AgentInfo
-}
-
-// HealthHandler handles GET /health.
-type HealthHandler struct {
-	agents AgentLister
-	client *http.Client
-}
-
-// NewHealthHandler returns a health handler that checks all registered agents.
-func NewHealthHandler(agents AgentLister) *HealthHandler {
-	return &HealthHandler{
-		agents: agents,
-		client: &http.Client{
-			Timeout: healthCheckTimeout,
-		},
-	}
-}
-
-// ServeHTTP implements http.Handler.
-func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	agentInfos := h.agents.All()
-	agentStatuses := make(map[string]string, len(agentInfos))
-	allOK := true
-
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-
-	for _, a := range agentInfos {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			s := checkAgent(h.client, a)
-			mu.Lock()
-			agentStatuses[a.Key] = s
-			if s != "ok" && s != "disabled" {
-				allOK = false
-			}
-			mu.Unlock()
-		}()
-	}
-	wg.Wait()
-
-	status := "ok"
-	code := http.StatusOK
-	if !allOK {
-		status = "degraded"
-		code = http.StatusServiceUnavailable
-	}
-	w.WriteHeader(code)
-	_ = json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":  status,
-		"service": "gateway",
-		"agents":  agentStatuses,
-	})
-}
-
-// checkAgent verifica la salud de un agente individual.
-func checkAgent(client *http.Client, a agent.AgentInfo) string {
-	if !a.Enabled {
-		return "disabled"
-	}
-	if a.HealthURL == "" {
-		return "no_url"
-	}
-	resp, err := client.Get(a.HealthURL)
-	if err != nil {
-		return "unreachable"
-	}
-	io.Copy(io.Discard, resp.Body)
-	resp.Body.Close()
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		return "ok"
-	}
-	return "unreachable"
-}
-
-// MetricsHandler returns Prometheus metrics (GET /metrics).
-func MetricsHandler() http.Handler {
-	return promhttp.Handler()
-}
+package handler
+
+import (
+	"net/http"
+
+	"gateway/internal/health"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// HealthSource provides cached agent health (implemented by health.Monitor).
+type HealthSource interface {
+	Snapshot(withHistory bool) map[string]health.AgentHealth
+	Ready() (bool, []string)
+}
+
+// HealthHandler handles GET /health, GET /livez and GET /readyz.
+// Ninguno llama a los agentes: leen la cache del monitor en segundo plano.
+type HealthHandler struct {
+	source HealthSource
+}
+
+// NewHealthHandler returns a health handler backed by the background monitor.
+func NewHealthHandler(source HealthSource) *HealthHandler {
+	return &HealthHandler{source: source}
+}
+
+// ServeHTTP implements GET /health: detalle por agente desde la cache.
+// Responde 503 solo si un agente critico esta caido (o aun no termino la primera ronda de probes).
+// ?history=1 incluye el historial de probes de cada agente.
+func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	agents := h.source.Snapshot(r.URL.Query().Get("history") == "1")
+	ready, _ := h.source.Ready()
+
+	status := OverallStatus(agents, ready)
+	code := http.StatusOK
+	if !ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeHealth(w, code, map[string]interface{}{
+		"status":  status,
+		"service": "gateway",
+		"agents":  agents,
+	})
+}
+
+// OverallStatus summarizes agent health: ok, degraded (algun agente caido) o unavailable (no ready).
+func OverallStatus(agents map[string]health.AgentHealth, ready bool) string {
+	if !ready {
+		return "unavailable"
+	}
+	for _, a := range agents {
+		if a.Status != health.StatusOK && a.Status != health.StatusDisabled {
+			return "degraded"
+		}
+	}
+	return "ok"
+}
+
+// Live implements GET /livez: solo salud del proceso. Nunca depende de los agentes
+// para que Kubernetes no reinicie un gateway sano cuando un agente cae.
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Ready implements GET /readyz: 200 cuando todos los agentes criticos estan ok.
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	ready, down := h.source.Ready()
+	if !ready {
+		writeHealth(w, http.StatusServiceUnavailable, map[string]interface{}{"status": "not_ready", "critical_down": down})
+		return
+	}
+	writeHealth(w, http.StatusOK, map[string]string{"status": "ready"})
+}
+
+func writeHealth(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, code, v)
+}
+
+// MetricsHandler returns Prometheus metrics (GET /metrics).
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
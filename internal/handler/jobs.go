@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gateway/internal/domain"
+	"gateway/internal/jobs"
+	"gateway/internal/middleware"
+)
+
+// JobRunner runs async chat jobs (implemented by jobs.Runner).
+type JobRunner interface {
+	Submit(ctx context.Context, requestID, callbackURL string, fn jobs.Func) (jobs.Job, error)
+	Get(id string) (jobs.Job, bool)
+}
+
+// AsyncOptions enables the async mode of POST /api/agent/chat ("async": true o callback_url).
+type AsyncOptions struct {
+	Jobs          JobRunner
+	Timeout       time.Duration // deadline de la llamada al agente; reemplaza AgentTimeout
+	CallbackHosts []string      // hosts permitidos en callback_url ("hooks.maravia.pe", "*.maravia.pe"); vacio = cualquiera
+}
+
+// chatEnvelope is the body of POST /api/agent/chat: ChatRequest mas los campos del modo async.
+type chatEnvelope struct {
+	ChatRequest
+	Async       domain.FlexBool `json:"async"`
+	CallbackURL string          `json:"callback_url"` // implica async
+}
+
+// jobAccepted is the 202 response of an async chat.
+type jobAccepted struct {
+	JobID     string      `json:"job_id"`
+	Status    jobs.Status `json:"status"`
+	StatusURL string      `json:"status_url"`
+}
+
+// validateAsync checks the async fields; la validacion del ChatRequest es la de siempre.
+func (h *ChatHandler) validateAsync(callbackURL string) []violation {
+	if h.Async == nil {
+		return []violation{{field: "async", code: CodeInvalidValue, arg: "disabled"}}
+	}
+	if callbackURL == "" {
+		return nil
+	}
+	u, err := url.Parse(callbackURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return []violation{{field: "callback_url", code: CodeInvalidValue, arg: callbackURL}}
+	}
+	if !hostAllowed(u.Hostname(), h.Async.CallbackHosts) {
+		return []violation{{field: "callback_url", code: CodeInvalidValue, arg: u.Hostname()}}
+	}
+	return nil
+}
+
+// submit starts the job and answers 202 with its ID. El agente se llama con Async.Timeout;
+// el result es ChatResponseV2 si el request llego por /api/v2.
+func (h *ChatHandler) submit(w http.ResponseWriter, r *http.Request, req *ChatRequest, callbackURL string, v2 bool) {
+	// El job sobrevive al request: sin anotaciones del access log (la linea ya se habra escrito).
+	ctx := middleware.WithoutAnnotations(r.Context())
+	job, err := h.Async.Jobs.Submit(ctx, middleware.GetRequestID(ctx), callbackURL, func(ctx context.Context) (any, string) {
+		if v2 {
+			return h.chatV2(ctx, req, h.Async.Timeout)
+		}
+		return h.chat(ctx, req, h.Async.Timeout)
+	})
+	switch {
+	case errors.Is(err, jobs.ErrFull):
+		writeError(w, r, http.StatusServiceUnavailable, CodeTooManyJobs)
+		return
+	case err != nil:
+		writeError(w, r, http.StatusServiceUnavailable, CodeUnavailable)
+		return
+	}
+	middleware.Annotate(r.Context(), "job_id", job.ID)
+	statusURL := "/api/agent/jobs/" + job.ID
+	w.Header().Set("Location", statusURL)
+	writeJSON(w, http.StatusAccepted, jobAccepted{JobID: job.ID, Status: job.Status, StatusURL: statusURL})
+}
+
+// Job handles GET /api/agent/jobs/{id}: estado y, al terminar, el ChatResponse en result.
+func (h *ChatHandler) Job(w http.ResponseWriter, r *http.Request) {
+	if h.Async == nil {
+		writeError(w, r, http.StatusNotFound, CodeNotFound)
+		return
+	}
+	job, ok := h.Async.Jobs.Get(r.PathValue("id"))
+	if !ok {
+		writeError(w, r, http.StatusNotFound, CodeNotFound)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, job)
+}
+
+// hostAllowed matches host against exact hosts and "*.dominio" (solo subdominios). Lista vacia = cualquiera.
+func hostAllowed(host string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	host = strings.ToLower(host)
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		if suffix, ok := strings.CutPrefix(p, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == p {
+			return true
+		}
+	}
+	return false
+}
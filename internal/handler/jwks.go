@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"net/http"
+
+	"gateway/internal/svctoken"
+)
+
+// KeySetProvider exposes the public keys used to sign service tokens.
+type KeySetProvider interface {
+	JWKS() svctoken.JWKS
+}
+
+// JWKSHandler serves GET /.well-known/jwks.json so agents can verify service tokens.
+// Con HS256 la lista de claves es vacia: el secreto compartido se distribuye fuera de banda.
+func JWKSHandler(keys KeySetProvider) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Cache corto: tras una rotacion los agentes ven la clave nueva en pocos minutos.
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		writeJSON(w, http.StatusOK, keys.JWKS())
+	})
+}
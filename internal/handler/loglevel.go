@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gateway/internal/logging"
+)
+
+// LogLevelController changes log levels at runtime (implemented by logging.Controller).
+type LogLevelController interface {
+	Level() slog.Level
+	SetLevel(l slog.Level)
+	AddOverride(idEmpresa, sessionID int, ttl time.Duration) logging.Override
+	RemoveOverride(idEmpresa, sessionID int) bool
+	Overrides() []logging.Override
+}
+
+// LogLevelHandler serves the admin log level API:
+//
+//	GET    /admin/log-level            nivel actual + overrides activos
+//	PUT    /admin/log-level            {"level":"debug"}
+//	POST   /admin/log-level/overrides  {"id_empresa":12,"ttl_sec":600} o {"session_id":345}
+//	DELETE /admin/log-level/overrides?id_empresa=12 (o ?session_id=345)
+type LogLevelHandler struct {
+	Levels LogLevelController
+}
+
+type logLevelResponse struct {
+	Level     string             `json:"level"`
+	Overrides []logging.Override `json:"overrides"`
+}
+
+type setLevelRequest struct {
+	Level string `json:"level"`
+}
+
+type overrideRequest struct {
+	IdEmpresa int `json:"id_empresa"`
+	SessionID int `json:"session_id"`
+	TTLSec    int `json:"ttl_sec"` // 0 = logging.DefaultOverrideTTL; maximo logging.MaxOverrideTTL
+}
+
+// Get returns the current level and overrides.
+func (h *LogLevelHandler) Get(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.state())
+}
+
+// Set changes the global level.
+func (h *LogLevelHandler) Set(w http.ResponseWriter, r *http.Request) {
+	var req setLevelRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON)
+		return
+	}
+	level, ok := logging.ParseLevel(req.Level)
+	if !ok {
+		writeValidationError(w, r, []violation{{field: "level", code: CodeInvalidValue, arg: req.Level}})
+		return
+	}
+	h.Levels.SetLevel(level)
+	writeJSON(w, http.StatusOK, h.state())
+}
+
+// AddOverride enables debug logs for one tenant or session during ttl_sec.
+func (h *LogLevelHandler) AddOverride(w http.ResponseWriter, r *http.Request) {
+	var req overrideRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON)
+		return
+	}
+	if vs := validateOverrideTarget(req.IdEmpresa, req.SessionID); len(vs) > 0 {
+		writeValidationError(w, r, vs)
+		return
+	}
+	if req.TTLSec < 0 {
+		writeValidationError(w, r, []violation{{field: "ttl_sec", code: CodeMustNotBeNegative}})
+		return
+	}
+	o := h.Levels.AddOverride(req.IdEmpresa, req.SessionID, time.Duration(req.TTLSec)*time.Second)
+	writeJSON(w, http.StatusCreated, o)
+}
+
+// RemoveOverride deletes an override before it expires.
+func (h *LogLevelHandler) RemoveOverride(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var vs []violation
+	idEmpresa, sessionID := 0, 0
+	for _, p := range []struct {
+		field string
+		dst   *int
+	}{{"id_empresa", &idEmpresa}, {"session_id", &sessionID}} {
+		if v := q.Get(p.field); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				vs = append(vs, violation{field: p.field, code: CodeInvalidType, arg: "string"})
+				continue
+			}
+			*p.dst = n
+		}
+	}
+	if len(vs) == 0 {
+		vs = validateOverrideTarget(idEmpresa, sessionID)
+	}
+	if len(vs) > 0 {
+		writeValidationError(w, r, vs)
+		return
+	}
+	if !h.Levels.RemoveOverride(idEmpresa, sessionID) {
+		writeError(w, r, http.StatusNotFound, CodeNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *LogLevelHandler) state() logLevelResponse {
+	return logLevelResponse{
+		Level:     logging.LevelName(h.Levels.Level()),
+		Overrides: h.Levels.Overrides(),
+	}
+}
+
+// validateOverrideTarget exige exactamente uno de id_empresa / session_id, positivo.
+func validateOverrideTarget(idEmpresa, sessionID int) []violation {
+	switch {
+	case idEmpresa < 0:
+		return []violation{{field: "id_empresa", code: CodeMustBePositive}}
+	case sessionID < 0:
+		return []violation{{field: "session_id", code: CodeMustBePositive}}
+	case (idEmpresa > 0) == (sessionID > 0):
+		return []violation{{field: "id_empresa", code: CodeOneOfRequired, arg: "id_empresa, session_id"}}
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gateway/internal/agent"
+	"gateway/internal/domain"
+	"gateway/internal/logging"
+	"gateway/internal/middleware"
+)
+
+// Headers alternativos a metadata para clientes OpenAI que no permiten campos extra en el body.
+const (
+	HeaderIdEmpresa  = "X-Id-Empresa"
+	HeaderSessionID  = "X-Session-Id"
+	HeaderChatConfig = "X-Chat-Config" // JSON con la forma de ChatConfig
+)
+
+// Tipos de error de la API de OpenAI.
+const (
+	openAIInvalidRequest = "invalid_request_error"
+	openAIAuthentication = "authentication_error"
+	openAINotFound       = "not_found_error"
+)
+
+// OpenAIHandler handles POST /v1/chat/completions y GET /v1/models: fachada compatible con los SDKs de OpenAI.
+// model = clave de agente o modalidad; el ultimo mensaje "user" es message; api_key = Bearer.
+type OpenAIHandler struct {
+	Chat *ChatHandler
+}
+
+type openAIRequest struct {
+	Model    string          `json:"model"`
+	Messages []openAIMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Metadata openAIMetadata  `json:"metadata"`
+}
+
+type openAIMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"` // string o [{"type":"text","text":"..."}]
+}
+
+// openAIMetadata: OpenAI define metadata como map de strings; se aceptan tambien numeros y config como objeto.
+type openAIMetadata struct {
+	IdEmpresa domain.FlexInt  `json:"id_empresa"`
+	SessionID domain.FlexInt  `json:"session_id"`
+	Config    json.RawMessage `json:"config"` // objeto ChatConfig o string con ese JSON
+}
+
+type openAICompletion struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []openAIChoice `json:"choices"`
+	Usage   *openAIUsage   `json:"usage,omitempty"`
+}
+
+type openAIChoice struct {
+	Index        int          `json:"index"`
+	Message      *openAIReply `json:"message,omitempty"` // chat.completion
+	Delta        *openAIReply `json:"delta,omitempty"`   // chat.completion.chunk
+	FinishReason *string      `json:"finish_reason"`
+}
+
+// openAIReply es message (o delta). URL no es parte del estandar: los SDKs la conservan como campo extra.
+type openAIReply struct {
+	Role    string  `json:"role,omitempty"`
+	Content *string `json:"content,omitempty"`
+	URL     *string `json:"url,omitempty"`
+}
+
+// openAIUsage: el gateway no cuenta tokens; se informa en cero para los SDKs que lo esperan.
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type openAIError struct {
+	Error openAIErrorBody `json:"error"`
+}
+
+type openAIErrorBody struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Param   *string `json:"param"`
+	Code    string  `json:"code"`
+}
+
+// ServeHTTP implements http.Handler (POST /v1/chat/completions).
+func (h *OpenAIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	lang := requestLang(r)
+	body := http.MaxBytesReader(w, r.Body, MaxRequestBodyBytes)
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeOpenAIError(w, http.StatusRequestEntityTooLarge, openAIInvalidRequest, errorEnvelope(lang, CodeBodyTooLarge, ""))
+			return
+		}
+		slog.DebugContext(r.Context(), "openai read error", "err", err)
+		writeOpenAIError(w, http.StatusBadRequest, openAIInvalidRequest, errorEnvelope(lang, CodeInvalidJSON, ""))
+		return
+	}
+	var in openAIRequest
+	typeVs, err := decodeTolerant(data, &in)
+	if err != nil {
+		slog.DebugContext(r.Context(), "openai decode error", "err", err)
+		writeOpenAIError(w, http.StatusBadRequest, openAIInvalidRequest, errorEnvelope(lang, CodeInvalidJSON, ""))
+		return
+	}
+
+	req, vs := h.chatRequest(r, &in)
+	vs = withTypeViolations(typeVs, vs)
+	middleware.Annotate(r.Context(), "id_empresa", req.IdEmpresa, "model", in.Model)
+	r = r.WithContext(logging.WithTarget(r.Context(), req.IdEmpresa, req.SessionID))
+	if len(vs) > 0 {
+		writeOpenAIError(w, http.StatusBadRequest, openAIInvalidRequest, validationEnvelope(lang, vs))
+		return
+	}
+	if req.ApiKey == "" {
+		writeOpenAIError(w, http.StatusUnauthorized, openAIAuthentication, validationEnvelope(lang, []violation{{field: "api_key", code: CodeRequired}}))
+		return
+	}
+	if strings.TrimSpace(in.Model) == "" {
+		writeOpenAIError(w, http.StatusBadRequest, openAIInvalidRequest, validationEnvelope(lang, []violation{{field: "model", code: CodeRequired}}))
+		return
+	}
+	modalidad, ok := h.resolveModel(in.Model)
+	if !ok {
+		writeOpenAIError(w, http.StatusNotFound, openAINotFound, errorEnvelope(lang, CodeModelNotFound, in.Model))
+		return
+	}
+	req.Config.Modalidad = modalidad
+	if vs := validateChatRequest(&req, h.Chat.Router, h.Chat.StrictConfig); len(vs) > 0 {
+		writeOpenAIError(w, http.StatusBadRequest, openAIInvalidRequest, validationEnvelope(lang, vs))
+		return
+	}
+
+	id := "chatcmpl-" + middleware.GetRequestID(r.Context())
+	created := time.Now().Unix()
+	if !in.Stream {
+		resp := h.Chat.Chat(r.Context(), &req)
+		stop := "stop"
+		writeJSON(w, http.StatusOK, openAICompletion{
+			ID:      id,
+			Object:  "chat.completion",
+			Created: created,
+			Model:   in.Model,
+			Choices: []openAIChoice{{
+				Message:      &openAIReply{Role: "assistant", Content: &resp.Reply, URL: resp.URL},
+				FinishReason: &stop,
+			}},
+			Usage: &openAIUsage{},
+		})
+		return
+	}
+
+	// Streaming (SSE): el agente no hace streaming, asi que se emite el rol de inmediato
+	// (el cliente muestra "escribiendo"), luego la respuesta completa en un chunk y el cierre.
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // sin buffering en nginx
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	chunk := func(delta openAIReply, finish *string) {
+		writeSSE(w, openAICompletion{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   in.Model,
+			Choices: []openAIChoice{{Delta: &delta, FinishReason: finish}},
+		})
+		_ = rc.Flush()
+	}
+	chunk(openAIReply{Role: "assistant"}, nil)
+	resp := h.Chat.Chat(r.Context(), &req)
+	chunk(openAIReply{Content: &resp.Reply, URL: resp.URL}, nil)
+	stop := "stop"
+	chunk(openAIReply{}, &stop)
+	_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	_ = rc.Flush()
+}
+
+// Models handles GET /v1/models: las claves de agente alcanzables por alguna modalidad.
+func (h *OpenAIHandler) Models(w http.ResponseWriter, r *http.Request) {
+	type model struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		Created int64  `json:"created"`
+		OwnedBy string `json:"owned_by"`
+	}
+	out := struct {
+		Object string  `json:"object"`
+		Data   []model `json:"data"`
+	}{Object: "list", Data: []model{}}
+	for _, k := range agent.RoutedAgents() {
+		out.Data = append(out.Data, model{ID: k, Object: "model", OwnedBy: "maravia"})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// resolveModel devuelve la modalidad para model: primero como modalidad, luego como clave de agente.
+func (h *OpenAIHandler) resolveModel(model string) (string, bool) {
+	if h.Chat.Router(model) != "" {
+		return model, true
+	}
+	return agent.ModalidadFor(model)
+}
+
+// chatRequest arma el ChatRequest desde headers y metadata (metadata tiene prioridad).
+// No valida el resultado: solo reporta los errores de formato de headers y mensajes.
+func (h *OpenAIHandler) chatRequest(r *http.Request, in *openAIRequest) (ChatRequest, []violation) {
+	var req ChatRequest
+	var vs []violation
+	if key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		req.ApiKey = strings.TrimSpace(key)
+	}
+
+	headerInt := func(name string) int {
+		s := strings.TrimSpace(r.Header.Get(name))
+		if s == "" {
+			return 0
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			vs = append(vs, violation{field: name, code: CodeInvalidType, arg: "string"})
+		}
+		return n
+	}
+	req.IdEmpresa = headerInt(HeaderIdEmpresa)
+	req.SessionID = headerInt(HeaderSessionID)
+	if in.Metadata.IdEmpresa.Valid {
+		req.IdEmpresa = in.Metadata.IdEmpresa.Value
+	}
+	if in.Metadata.SessionID.Valid {
+		req.SessionID = in.Metadata.SessionID.Value
+	}
+
+	cfg := []byte(r.Header.Get(HeaderChatConfig))
+	field := HeaderChatConfig
+	if len(in.Metadata.Config) > 0 && string(in.Metadata.Config) != "null" {
+		cfg, field = in.Metadata.Config, "metadata.config"
+		var s string
+		if json.Unmarshal(cfg, &s) == nil {
+			cfg = []byte(s) // metadata de OpenAI: valores string
+		}
+	}
+	if len(cfg) > 0 {
+		typeVs, err := decodeTolerant(cfg, &req.Config)
+		if err != nil {
+			vs = append(vs, violation{field: field, code: CodeInvalidType, arg: "json"})
+		}
+		for _, v := range typeVs {
+			v.field = field + "." + v.field
+			vs = append(vs, v)
+		}
+	}
+
+	msg, ok := lastUserMessage(in.Messages)
+	if !ok {
+		vs = append(vs, violation{field: "messages", code: CodeInvalidType, arg: "content"})
+	}
+	req.Message = msg
+	return req, vs
+}
+
+// lastUserMessage extrae el texto del ultimo mensaje con role "user". El historial lo mantiene el
+// agente por session_id; system/assistant se ignoran. ok=false si el content no es string ni partes de texto.
+func lastUserMessage(msgs []openAIMessage) (string, bool) {
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role != "user" {
+			continue
+		}
+		c := msgs[i].Content
+		var s string
+		if err := json.Unmarshal(c, &s); err == nil {
+			return s, true
+		}
+		var parts []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(c, &parts); err != nil {
+			return "", false
+		}
+		var texts []string
+		for _, p := range parts {
+			if p.Type == "text" {
+				texts = append(texts, p.Text)
+			}
+		}
+		return strings.Join(texts, "\n"), true
+	}
+	return "", true // sin mensaje user: validateChatRequest reporta message vacio
+}
+
+func writeOpenAIError(w http.ResponseWriter, status int, typ string, e ErrorResponse) {
+	body := openAIErrorBody{Message: e.Detail, Type: typ, Code: e.Code}
+	if len(e.Errors) > 0 {
+		body.Code = e.Errors[0].Code
+		body.Param = &e.Errors[0].Field
+	}
+	writeJSON(w, status, openAIError{Error: body})
+}
+
+func writeSSE(w http.ResponseWriter, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "data: %s\n\n", b)
+}
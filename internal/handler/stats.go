@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"gateway/internal/proxy"
+	"gateway/internal/stats"
+)
+
+// StatsSource provides the rolling window stats (implemented by stats.Collector).
+type StatsSource interface {
+	Snapshot() map[string]stats.WindowReport
+}
+
+// AgentStateSource provides breaker state and semaphore occupancy (implemented by proxy.Invoker).
+type AgentStateSource interface {
+	AgentStates() map[string]proxy.AgentState
+}
+
+// StatsHandler handles GET /debug/stats.
+type StatsHandler struct {
+	Stats  StatsSource
+	Agents AgentStateSource
+}
+
+type statsResponse struct {
+	GeneratedAt time.Time                     `json:"generated_at"`
+	Windows     map[string]stats.WindowReport `json:"windows"`
+	Agents      map[string]proxy.AgentState   `json:"agents"`
+}
+
+// ServeHTTP implements http.Handler.
+func (h *StatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, statsResponse{
+		GeneratedAt: time.Now().UTC(),
+		Windows:     h.Stats.Snapshot(),
+		Agents:      h.Agents.AgentStates(),
+	})
+}
+
+// MultiRecorder fans out request metrics to several recorders (ej. Prometheus y /debug/stats).
+type MultiRecorder []MetricsRecorder
+
+// Record implements MetricsRecorder.
+func (m MultiRecorder) Record(agent, status string, idEmpresa int, duration time.Duration) {
+	for _, r := range m {
+		r.Record(agent, status, idEmpresa, duration)
+	}
+}
+
+// RecordFallback implements MetricsRecorder.
+func (m MultiRecorder) RecordFallback(agent, reason string) {
+	for _, r := range m {
+		r.RecordFallback(agent, reason)
+	}
+}
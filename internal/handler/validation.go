@@ -0,0 +1,353 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gateway/internal/agent"
+)
+
+// Codigos estables de error. Son parte del contrato: n8n e integradores ramifican por ellos.
+const (
+	CodeInvalidJSON      = "invalid_json"      // body no es JSON valido
+	CodeBodyTooLarge     = "body_too_large"    // body > MaxRequestBodyBytes
+	CodeValidationFailed = "validation_failed" // uno o mas campos invalidos (ver errors)
+
+	CodeRequired          = "required"             // campo vacio u omitido
+	CodeMustBePositive    = "must_be_positive"     // entero <= 0
+	CodeMustNotBeNegative = "must_not_be_negative" // entero < 0 (campos numericos opcionales de config)
+	CodeInvalidType       = "invalid_type"         // tipo JSON incorrecto (ej. string en session_id)
+	CodeUnknownModalidad  = "unknown_modalidad"    // modalidad sin agente asociado
+	CodeInvalidValue      = "invalid_value"        // valor fuera del conjunto permitido (ej. nivel de log)
+	CodeOneOfRequired     = "one_of_required"      // se requiere exactamente uno de varios campos
+
+	CodeNotFound = "not_found" // recurso inexistente (endpoints admin)
+
+	// WebSocket (/api/agent/ws): eventos "error" en la conexion.
+	CodeAuthRequired    = "auth_required"      // primer mensaje distinto de "auth"
+	CodeUnknownType     = "unknown_type"       // campo type desconocido
+	CodeRateLimited     = "rate_limited"       // mas de WS_MAX_MESSAGES_PER_MIN
+	CodeTooManyInFlight = "too_many_in_flight" // mas de WS_MAX_IN_FLIGHT mensajes sin respuesta
+	CodeUnavailable     = "unavailable"        // gateway apagandose o sin cupo de conexiones
+
+	// OpenAI (/v1/chat/completions).
+	CodeModelNotFound = "model_not_found" // model no es agente ni modalidad
+
+	// Lotes (/api/agent/chat/batch).
+	CodeBatchTooLarge = "batch_too_large" // mas items o bytes que BATCH_MAX_ITEMS / BATCH_MAX_BODY_BYTES
+	CodeAgentFailed   = "agent_failed"    // el item recibio fallback; el motivo va en reason
+
+	// Modo async (/api/agent/chat con "async": true).
+	CodeTooManyJobs = "too_many_jobs" // JOB_MAX_JOBS jobs pendientes o sin expirar
+)
+
+// FieldError is one violation in the error envelope.
+type FieldError struct {
+	Field   string `json:"field"`   // ruta JSON: "session_id", "config.modalidad"
+	Code    string `json:"code"`    // codigo estable (CodeRequired, ...)
+	Message string `json:"message"` // mensaje localizado segun Accept-Language
+}
+
+// ErrorResponse is the error envelope for 4xx responses.
+// Detail se mantiene por compatibilidad: es el mensaje del primer error.
+type ErrorResponse struct {
+	Detail string       `json:"detail"`
+	Code   string       `json:"code"`
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// Describe flattens the envelope into one line ("session_id: ...; message: ..."), para logs y para
+// transportes sin el sobre JSON (canales, MCP).
+func (e *ErrorResponse) Describe() string {
+	if len(e.Errors) == 0 {
+		return e.Detail
+	}
+	parts := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		parts[i] = fe.Field + ": " + fe.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+// violation is a failed check before localization.
+type violation struct {
+	field string
+	code  string
+	arg   string // valor ofensivo para el mensaje (ej. modalidad no reconocida)
+}
+
+// validateChatRequest collects every violation in req (no se detiene en el primero).
+// El orden sigue al de las validaciones originales para que detail no cambie: message, session_id,
+// api_key, config.modalidad vacia, id_empresa y por ultimo modalidad no reconocida.
+// strictConfig agrega los chequeos de los campos numericos opcionales de config (ChatHandler.StrictConfig).
+func validateChatRequest(req *ChatRequest, route agent.RouteFunc, strictConfig bool) []violation {
+	var vs []violation
+	if strings.TrimSpace(req.Message) == "" {
+		vs = append(vs, violation{field: "message", code: CodeRequired})
+	}
+	if req.SessionID <= 0 {
+		vs = append(vs, violation{field: "session_id", code: CodeMustBePositive})
+	}
+	if strings.TrimSpace(req.ApiKey) == "" {
+		vs = append(vs, violation{field: "api_key", code: CodeRequired})
+	}
+	modalidad := strings.TrimSpace(req.Config.Modalidad) != ""
+	if !modalidad {
+		vs = append(vs, violation{field: "config.modalidad", code: CodeRequired})
+	}
+	if req.IdEmpresa <= 0 {
+		vs = append(vs, violation{field: "id_empresa", code: CodeMustBePositive})
+	}
+	if modalidad && route != nil && route(req.Config.Modalidad) == "" {
+		vs = append(vs, violation{field: "config.modalidad", code: CodeUnknownModalidad, arg: req.Config.Modalidad})
+	}
+	if strictConfig {
+		vs = append(vs, validateConfigNumbers(&req.Config)...)
+	}
+	return vs
+}
+
+// Validate checks req like POST /api/agent/chat. nil = valido; si no, el sobre validation_failed
+// localizado en lang ("es", "en"). Para transportes fuera de net/http (gRPC).
+func (h *ChatHandler) Validate(req *ChatRequest, lang string) *ErrorResponse {
+	vs := validateChatRequest(req, h.Router, h.StrictConfig)
+	if len(vs) == 0 {
+		return nil
+	}
+	resp := validationEnvelope(lang, vs)
+	return &resp
+}
+
+// ValidateAgent is Validate for ChatAgent: el agente ya esta elegido, asi que config.modalidad
+// solo tiene que venir informada (se reenvia al agente) y no se resuelve con el Router.
+func (h *ChatHandler) ValidateAgent(req *ChatRequest, lang string) *ErrorResponse {
+	vs := validateChatRequest(req, nil, h.StrictConfig)
+	if len(vs) == 0 {
+		return nil
+	}
+	resp := validationEnvelope(lang, vs)
+	return &resp
+}
+
+// validateConfigNumbers rejects negative values in the optional numeric fields of config.
+// El contrato original los reenviaba sin validar; solo con VALIDATION_STRICT_CONFIG.
+func validateConfigNumbers(c *ChatConfig) []violation {
+	var vs []violation
+	nonNegative := []struct {
+		field string
+		v     int
+	}{
+		{"config.duracion_cita_minutos", c.DuracionCitaMinutos},
+		{"config.slots", c.Slots},
+		{"config.usuario_id", c.UsuarioID},
+		{"config.id_chatbot", c.IdChatbot},
+	}
+	for _, f := range nonNegative {
+		if f.v < 0 {
+			vs = append(vs, violation{field: f.field, code: CodeMustNotBeNegative})
+		}
+	}
+	return vs
+}
+
+// decodeTolerant decodes data into v skipping the fields with a wrong JSON type: devuelve una violacion
+// invalid_type por cada uno y deja el resto de v decodificado, para que las reglas de validacion corran
+// igual y el cliente reciba todos los errores juntos. err != nil solo si data no es JSON valido (o el
+// tipo incorrecto es el del documento entero).
+func decodeTolerant(data []byte, v any) ([]violation, error) {
+	var vs []violation
+	var tree map[string]any
+	for {
+		err := json.NewDecoder(bytes.NewReader(data)).Decode(v)
+		var typeErr *json.UnmarshalTypeError
+		if err == nil {
+			return vs, nil
+		}
+		if !errors.As(err, &typeErr) || typeErr.Field == "" {
+			return vs, err
+		}
+		vs = append(vs, violation{field: typeErr.Field, code: CodeInvalidType, arg: typeErr.Value})
+		// encoding/json informa solo el primer campo con tipo incorrecto: se quita del documento y se
+		// decodifica de nuevo para encontrar el siguiente.
+		if tree == nil {
+			dec := json.NewDecoder(bytes.NewReader(data))
+			dec.UseNumber()
+			if dec.Decode(&tree) != nil {
+				return vs, nil
+			}
+		}
+		if !deletePath(tree, strings.Split(typeErr.Field, ".")) {
+			return vs, nil
+		}
+		if data, err = json.Marshal(tree); err != nil {
+			return vs, nil
+		}
+	}
+}
+
+// deletePath removes the value at path ("config.slots", "messages.0.role") from a decoded JSON document.
+func deletePath(node any, path []string) bool {
+	switch n := node.(type) {
+	case map[string]any:
+		child, ok := n[path[0]]
+		if !ok {
+			return false
+		}
+		if len(path) == 1 {
+			delete(n, path[0])
+			return true
+		}
+		return deletePath(child, path[1:])
+	case []any:
+		i, err := strconv.Atoi(path[0])
+		if err != nil || i < 0 || i >= len(n) {
+			return false
+		}
+		if len(path) == 1 {
+			n[i] = nil // null deja el elemento en su valor cero sin correr los indices
+			return true
+		}
+		return deletePath(n[i], path[1:])
+	}
+	return false
+}
+
+// withTypeViolations merges decode violations with the rule violations: primero las de tipo (detail no
+// cambia respecto de un body con un solo campo mal tipado) y despues las reglas de los demas campos. Un
+// campo con tipo incorrecto queda en cero y la regla lo reportaria de nuevo (ej. session_id), asi que se
+// omite.
+func withTypeViolations(typed, rules []violation) []violation {
+	if len(typed) == 0 {
+		return rules
+	}
+	seen := make(map[string]bool, len(typed))
+	for _, v := range typed {
+		seen[v.field] = true
+	}
+	vs := append([]violation(nil), typed...)
+	for _, v := range rules {
+		if !seen[v.field] {
+			vs = append(vs, v)
+		}
+	}
+	return vs
+}
+
+// messages: catalogo por idioma. %[1]s = campo, %[2]s = valor.
+var messages = map[string]map[string]string{
+	"es": {
+		CodeInvalidJSON:       "JSON invalido",
+		CodeBodyTooLarge:      "Body demasiado grande (max. 512 KB)",
+		CodeRequired:          "El campo '%[1]s' no puede estar vacio",
+		CodeMustBePositive:    "El campo '%[1]s' debe ser un entero mayor a 0",
+		CodeMustNotBeNegative: "El campo '%[1]s' no puede ser negativo",
+		CodeInvalidType:       "El campo '%[1]s' tiene un tipo invalido (%[2]s)",
+		CodeUnknownModalidad:  "Modalidad no reconocida: %[2]s",
+		CodeInvalidValue:      "El campo '%[1]s' tiene un valor invalido: %[2]s",
+		CodeOneOfRequired:     "Indicar exactamente uno de: %[2]s",
+		CodeNotFound:          "No encontrado",
+		CodeAuthRequired:      "Enviar primero un mensaje de tipo 'auth'",
+		CodeUnknownType:       "Tipo de mensaje desconocido: %[2]s",
+		CodeRateLimited:       "Demasiados mensajes; espera un momento",
+		CodeTooManyInFlight:   "Espera la respuesta anterior antes de enviar otro mensaje",
+		CodeUnavailable:       "Servicio no disponible; reconecta en unos segundos",
+		CodeModelNotFound:     "Modelo desconocido: %[2]s",
+		CodeBatchTooLarge:     "Lote demasiado grande (max. %[2]s)",
+		CodeAgentFailed:       "El agente no respondio (%[2]s)",
+		CodeTooManyJobs:       "Demasiados jobs pendientes; intenta mas tarde",
+	},
+	"en": {
+		CodeInvalidJSON:       "Invalid JSON",
+		CodeBodyTooLarge:      "Body too large (max. 512 KB)",
+		CodeRequired:          "Field '%[1]s' must not be empty",
+		CodeMustBePositive:    "Field '%[1]s' must be an integer greater than 0",
+		CodeMustNotBeNegative: "Field '%[1]s' must not be negative",
+		CodeInvalidType:       "Field '%[1]s' has an invalid type (%[2]s)",
+		CodeUnknownModalidad:  "Unknown modalidad: %[2]s",
+		CodeInvalidValue:      "Field '%[1]s' has an invalid value: %[2]s",
+		CodeOneOfRequired:     "Provide exactly one of: %[2]s",
+		CodeNotFound:          "Not found",
+		CodeAuthRequired:      "Send an 'auth' message first",
+		CodeUnknownType:       "Unknown message type: %[2]s",
+		CodeRateLimited:       "Too many messages; wait a moment",
+		CodeTooManyInFlight:   "Wait for the previous reply before sending another message",
+		CodeUnavailable:       "Service unavailable; reconnect in a few seconds",
+		CodeModelNotFound:     "Unknown model: %[2]s",
+		CodeBatchTooLarge:     "Batch too large (max. %[2]s)",
+		CodeAgentFailed:       "The agent did not respond (%[2]s)",
+		CodeTooManyJobs:       "Too many pending jobs; try again later",
+	},
+}
+
+// fieldMessages overrides the code message for one field (clave "codigo:campo"): textos del contrato
+// original que n8n compara y que no siguen la plantilla del codigo.
+var fieldMessages = map[string]map[string]string{
+	"es": {
+		CodeMustBePositive + ":id_empresa": "El campo '%[1]s' debe ser un numero mayor a 0",
+	},
+}
+
+// defaultLang es el idioma del contrato original con n8n.
+const defaultLang = "es"
+
+// requestLang is Lang for the request's Accept-Language header.
+func requestLang(r *http.Request) string {
+	return Lang(r.Header.Get("Accept-Language"))
+}
+
+// Lang picks the first supported language from an Accept-Language value (sin q-values: el orden manda).
+// Exportado para transportes fuera de net/http (metadata accept-language de gRPC).
+func Lang(acceptLanguage string) string {
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		base, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if _, ok := messages[base]; ok {
+			return base
+		}
+	}
+	return defaultLang
+}
+
+func localize(lang, code, field, arg string) string {
+	tmpl, ok := fieldMessages[lang][code+":"+field]
+	if !ok {
+		tmpl, ok = messages[lang][code]
+	}
+	if !ok {
+		tmpl = messages[defaultLang][code]
+	}
+	if !strings.Contains(tmpl, "%") {
+		return tmpl
+	}
+	return fmt.Sprintf(tmpl, field, arg)
+}
+
+// writeError writes an envelope without field errors (invalid_json, body_too_large).
+func writeError(w http.ResponseWriter, r *http.Request, status int, code string) {
+	writeJSON(w, status, errorEnvelope(requestLang(r), code, ""))
+}
+
+// writeValidationError writes a 400 envelope listing every violation.
+func writeValidationError(w http.ResponseWriter, r *http.Request, vs []violation) {
+	writeJSON(w, http.StatusBadRequest, validationEnvelope(requestLang(r), vs))
+}
+
+// errorEnvelope builds an envelope without field errors. arg se usa en mensajes con %[2]s.
+func errorEnvelope(lang, code, arg string) ErrorResponse {
+	return ErrorResponse{Detail: localize(lang, code, "", arg), Code: code}
+}
+
+// validationEnvelope builds the validation_failed envelope listing every violation.
+func validationEnvelope(lang string, vs []violation) ErrorResponse {
+	resp := ErrorResponse{Code: CodeValidationFailed, Errors: make([]FieldError, 0, len(vs))}
+	for _, v := range vs {
+		resp.Errors = append(resp.Errors, FieldError{Field: v.field, Code: v.code, Message: localize(lang, v.code, v.field, v.arg)})
+	}
+	resp.Detail = resp.Errors[0].Message
+	return resp
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http"
+
+	"gateway/internal/webhook"
+)
+
+// DeadLetterQueue exposes failed webhook deliveries (implemented by webhook.Dispatcher).
+type DeadLetterQueue interface {
+	DeadLetters() []webhook.Delivery
+	Redeliver(id string) bool
+	RedeliverAll() int
+}
+
+// WebhookAdminHandler serves the admin dead-letter API:
+//
+//	GET  /admin/webhooks/dead-letters                 entregas fallidas (sin payload)
+//	POST /admin/webhooks/dead-letters/{id}/redeliver  reencola una (mismo ID de entrega)
+//	POST /admin/webhooks/dead-letters/redeliver       reencola todas
+type WebhookAdminHandler struct {
+	Queue DeadLetterQueue
+}
+
+type deadLettersResponse struct {
+	Count      int                `json:"count"`
+	Deliveries []webhook.Delivery `json:"deliveries"`
+}
+
+type redeliverResponse struct {
+	Requeued int `json:"requeued"`
+}
+
+// List returns the dead-letter list (mas antigua primero).
+func (h *WebhookAdminHandler) List(w http.ResponseWriter, r *http.Request) {
+	dead := h.Queue.DeadLetters()
+	writeJSON(w, http.StatusOK, deadLettersResponse{Count: len(dead), Deliveries: dead})
+}
+
+// Redeliver requeues one delivery.
+func (h *WebhookAdminHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	if !h.Queue.Redeliver(r.PathValue("id")) {
+		writeError(w, r, http.StatusNotFound, CodeNotFound)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, redeliverResponse{Requeued: 1})
+}
+
+// RedeliverAll requeues every dead-lettered delivery.
+func (h *WebhookAdminHandler) RedeliverAll(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusAccepted, redeliverResponse{Requeued: h.Queue.RedeliverAll()})
+}
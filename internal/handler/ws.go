@@ -0,0 +1,450 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"gateway/internal/logging"
+	"gateway/internal/middleware"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+// Defaults del endpoint WebSocket.
+const (
+	DefaultWSMaxConnections    = 1000
+	DefaultWSMaxMessageBytes   = 64 * 1024
+	DefaultWSMaxInFlight       = 2
+	DefaultWSMaxMessagesPerMin = 30
+	DefaultWSAuthTimeout       = 10 * time.Second
+	DefaultWSIdleTimeout       = 5 * time.Minute
+	DefaultWSPingInterval      = 30 * time.Second
+
+	wsWriteTimeout = 10 * time.Second
+)
+
+// Tipos de mensaje del protocolo WebSocket.
+const (
+	wsTypeAuth       = "auth"       // cliente: id_empresa + api_key (+ session_id por defecto)
+	wsTypeChat       = "chat"       // cliente: ChatRequest (message, session_id, config)
+	wsTypePing       = "ping"       // cliente: keepalive de aplicacion
+	wsTypeReady      = "ready"      // servidor: auth aceptado
+	wsTypeProcessing = "processing" // servidor: mensaje validado, el agente esta respondiendo
+	wsTypeReply      = "reply"      // servidor: ChatResponse
+	wsTypeError      = "error"      // servidor: ErrorResponse
+	wsTypePong       = "pong"       // servidor: respuesta a ping
+)
+
+// WSOptions configures the WebSocket chat endpoint. Campos en cero usan los defaults.
+type WSOptions struct {
+	OriginPatterns    []string // hosts permitidos (path.Match, ej. "*.maravia.pe"); "*" = cualquiera
+	MaxConnections    int      // conexiones abiertas en todo el gateway
+	MaxMessageBytes   int64    // mensaje mas grande aceptado; mayor = cierre 1009
+	MaxInFlight       int      // mensajes de chat sin respuesta por conexion
+	MaxMessagesPerMin int      // mensajes de chat por minuto por conexion
+	AuthTimeout       time.Duration
+	IdleTimeout       time.Duration // sin mensajes del cliente = cierre
+	PingInterval      time.Duration // ping de protocolo; sin pong en PingInterval = cierre
+}
+
+// WSMetrics tracks open connections (implemented by metrics.Recorder).
+type WSMetrics interface {
+	AddWSConnections(delta int)
+}
+
+// WSHandler handles GET /api/agent/ws: chat del widget web sobre una conexion persistente.
+// Cada mensaje pasa por ChatHandler.Chat (mismo routing, breakers, semaforos, metricas y transcript).
+type WSHandler struct {
+	chat    *ChatHandler
+	opts    WSOptions
+	metrics WSMetrics
+
+	mu       sync.Mutex
+	conns    map[*wsConn]struct{}
+	reserved int // upgrades en curso, cuentan para MaxConnections
+	closing  bool
+	wg       sync.WaitGroup // una entrada por conexion abierta
+}
+
+// NewWSHandler builds the WebSocket handler. metrics puede ser nil.
+func NewWSHandler(chat *ChatHandler, opts WSOptions, metrics WSMetrics) *WSHandler {
+	if opts.MaxConnections <= 0 {
+		opts.MaxConnections = DefaultWSMaxConnections
+	}
+	if opts.MaxMessageBytes <= 0 {
+		opts.MaxMessageBytes = DefaultWSMaxMessageBytes
+	}
+	if opts.MaxInFlight <= 0 {
+		opts.MaxInFlight = DefaultWSMaxInFlight
+	}
+	if opts.MaxMessagesPerMin <= 0 {
+		opts.MaxMessagesPerMin = DefaultWSMaxMessagesPerMin
+	}
+	if opts.AuthTimeout <= 0 {
+		opts.AuthTimeout = DefaultWSAuthTimeout
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = DefaultWSIdleTimeout
+	}
+	if opts.PingInterval <= 0 {
+		opts.PingInterval = DefaultWSPingInterval
+	}
+	return &WSHandler{chat: chat, opts: opts, metrics: metrics, conns: make(map[*wsConn]struct{})}
+}
+
+// wsInbound is a client message. Los campos de ChatRequest van al mismo nivel que type.
+type wsInbound struct {
+	Type string `json:"type"`
+	ID   string `json:"id,omitempty"` // correlacion del cliente, se devuelve en processing/reply/error
+	ChatRequest
+}
+
+// wsOutbound is a server event. ChatResponse (reply) y ErrorResponse (error) se aplanan al mismo nivel.
+type wsOutbound struct {
+	Type         string `json:"type"`
+	ID           string `json:"id,omitempty"`
+	RequestID    string `json:"request_id,omitempty"`
+	ConnectionID string `json:"connection_id,omitempty"`
+	Agent        string `json:"agent,omitempty"` // processing
+	*ChatResponse
+	*ErrorResponse
+}
+
+// wsConn is the state of one connection.
+type wsConn struct {
+	h    *WSHandler
+	conn *websocket.Conn
+	id   string // request ID del upgrade; los mensajes usan <id>-<n>
+	lang string
+	ctx  context.Context
+
+	idEmpresa int
+	apiKey    string
+	sessionID int // session_id por defecto (auth)
+
+	seq      int
+	inFlight chan struct{} // semaforo por conexion
+	pending  sync.WaitGroup
+	window   time.Time // inicio de la ventana de rate limit
+	count    int
+}
+
+// ServeHTTP implements http.Handler.
+func (h *WSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	lang := requestLang(r)
+	if !h.reserve() {
+		writeJSON(w, http.StatusServiceUnavailable, errorEnvelope(lang, CodeUnavailable, ""))
+		return
+	}
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
+		OriginPatterns:     h.opts.OriginPatterns,
+		InsecureSkipVerify: slices.Contains(h.opts.OriginPatterns, "*"),
+	})
+	if err != nil {
+		// Accept ya respondio (400/403).
+		h.release(nil)
+		slog.DebugContext(r.Context(), "ws upgrade failed", "err", err)
+		return
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(h.opts.MaxMessageBytes)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	c := &wsConn{
+		h:        h,
+		conn:     conn,
+		id:       middleware.GetRequestID(r.Context()),
+		lang:     lang,
+		ctx:      ctx,
+		inFlight: make(chan struct{}, h.opts.MaxInFlight),
+	}
+	h.register(c)
+	defer h.release(c)
+
+	go c.keepalive(cancel)
+	n := c.serve()
+	cancel()
+	c.pending.Wait()
+	middleware.Annotate(r.Context(), "ws_messages", n)
+}
+
+// Shutdown stops accepting messages, waits for pending replies and closes every connection
+// with 1001 (going away). Las conexiones que no terminan antes de ctx se cierran igual.
+func (h *WSHandler) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	h.closing = true
+	conns := make([]*wsConn, 0, len(h.conns))
+	for c := range h.conns {
+		conns = append(conns, c)
+	}
+	h.mu.Unlock()
+
+	for _, c := range conns {
+		go func() {
+			done := make(chan struct{})
+			go func() { c.pending.Wait(); close(done) }()
+			select {
+			case <-done:
+			case <-ctx.Done():
+			}
+			_ = c.conn.Close(websocket.StatusGoingAway, "server shutting down")
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() { h.wg.Wait(); close(done) }()
+	select {
+	case <-done:
+		if len(conns) > 0 {
+			slog.Info("ws connections closed", "count", len(conns))
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("ws shutdown: %w", ctx.Err())
+	}
+}
+
+func (h *WSHandler) reserve() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closing || len(h.conns)+h.reserved >= h.opts.MaxConnections {
+		return false
+	}
+	h.reserved++
+	return true
+}
+
+func (h *WSHandler) register(c *wsConn) {
+	h.mu.Lock()
+	h.reserved--
+	h.conns[c] = struct{}{}
+	h.wg.Add(1)
+	closing := h.closing
+	h.mu.Unlock()
+	if h.metrics != nil {
+		h.metrics.AddWSConnections(1)
+	}
+	if closing {
+		// Upgrade que termino despues de Shutdown: no la vio el barrido.
+		_ = c.conn.Close(websocket.StatusGoingAway, "server shutting down")
+	}
+}
+
+// release libera la reserva (c == nil: el upgrade fallo) o da de baja la conexion.
+func (h *WSHandler) release(c *wsConn) {
+	h.mu.Lock()
+	if c == nil {
+		h.reserved--
+		h.mu.Unlock()
+		return
+	}
+	delete(h.conns, c)
+	h.mu.Unlock()
+	h.wg.Done()
+	if h.metrics != nil {
+		h.metrics.AddWSConnections(-1)
+	}
+}
+
+// begin registra un mensaje pendiente salvo que el gateway se este apagando
+// (bajo h.mu para no sumar a pending mientras Shutdown ya espera).
+func (h *WSHandler) begin(c *wsConn) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closing {
+		return false
+	}
+	c.pending.Add(1)
+	return true
+}
+
+// keepalive envia pings de protocolo; si el cliente no responde con pong a tiempo se cierra la conexion.
+func (c *wsConn) keepalive(cancel context.CancelFunc) {
+	t := time.NewTicker(c.h.opts.PingInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-t.C:
+			ctx, stop := context.WithTimeout(c.ctx, c.h.opts.PingInterval)
+			err := c.conn.Ping(ctx)
+			stop()
+			if err != nil {
+				if c.ctx.Err() == nil {
+					slog.InfoContext(c.ctx, "ws keepalive timeout", "request_id", c.id, "err", err)
+					_ = c.conn.Close(websocket.StatusPolicyViolation, "keepalive timeout")
+				}
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// serve lee mensajes hasta que el cliente cierra, vence un timeout o el gateway se apaga.
+// Devuelve la cantidad de mensajes de chat procesados.
+func (c *wsConn) serve() int {
+	auth := time.AfterFunc(c.h.opts.AuthTimeout, func() {
+		_ = c.conn.Close(websocket.StatusPolicyViolation, "auth timeout")
+	})
+	idle := time.AfterFunc(c.h.opts.IdleTimeout, func() {
+		_ = c.conn.Close(websocket.StatusNormalClosure, "idle timeout")
+	})
+	defer idle.Stop()
+	defer auth.Stop()
+
+	authenticated := false
+	for {
+		typ, data, err := c.conn.Read(c.ctx)
+		if err != nil {
+			if status := websocket.CloseStatus(err); status == websocket.StatusNormalClosure || status == websocket.StatusGoingAway {
+				slog.DebugContext(c.ctx, "ws closed by client", "request_id", c.id)
+			} else if !errors.Is(err, context.Canceled) {
+				slog.DebugContext(c.ctx, "ws read", "request_id", c.id, "err", err)
+			}
+			return c.seq
+		}
+		idle.Reset(c.h.opts.IdleTimeout)
+
+		var msg wsInbound
+		if typ != websocket.MessageText {
+			c.sendError("", errorEnvelope(c.lang, CodeInvalidJSON, ""))
+			continue
+		}
+		typeVs, err := decodeTolerant(data, &msg)
+		if err != nil {
+			c.sendError(msg.ID, errorEnvelope(c.lang, CodeInvalidJSON, ""))
+			continue
+		}
+
+		switch {
+		case msg.Type == wsTypePing:
+			c.send(wsOutbound{Type: wsTypePong, ID: msg.ID})
+		case msg.Type == wsTypeAuth:
+			if authenticated {
+				c.sendError(msg.ID, errorEnvelope(c.lang, CodeInvalidValue, wsTypeAuth))
+				continue
+			}
+			if vs := withTypeViolations(typeVs, validateWSAuth(&msg.ChatRequest)); len(vs) > 0 {
+				c.sendError(msg.ID, validationEnvelope(c.lang, vs))
+				continue
+			}
+			authenticated = true
+			auth.Stop()
+			c.idEmpresa, c.apiKey, c.sessionID = msg.IdEmpresa, msg.ApiKey, msg.SessionID
+			middleware.Annotate(c.ctx, "id_empresa", c.idEmpresa)
+			slog.InfoContext(c.ctx, "ws authenticated", "request_id", c.id, "id_empresa", c.idEmpresa)
+			c.send(wsOutbound{Type: wsTypeReady, ID: msg.ID, ConnectionID: c.id})
+		case !authenticated:
+			c.sendError(msg.ID, errorEnvelope(c.lang, CodeAuthRequired, ""))
+			_ = c.conn.Close(websocket.StatusPolicyViolation, "auth required")
+			return c.seq
+		case msg.Type == wsTypeChat:
+			c.chat(msg, typeVs)
+		case len(typeVs) > 0: // type con tipo incorrecto: se reporta el campo, no "tipo desconocido"
+			c.sendError(msg.ID, validationEnvelope(c.lang, typeVs))
+		default:
+			c.sendError(msg.ID, errorEnvelope(c.lang, CodeUnknownType, msg.Type))
+		}
+	}
+}
+
+// chat valida el mensaje, aplica los limites de la conexion y responde de forma asincrona.
+// El cliente puede tener hasta MaxInFlight mensajes en curso; las respuestas llevan su id.
+// typeVs son los campos con tipo incorrecto del mensaje: se reportan junto con el resto de las reglas.
+func (c *wsConn) chat(msg wsInbound, typeVs []violation) {
+	if !c.allow() {
+		c.sendError(msg.ID, errorEnvelope(c.lang, CodeRateLimited, ""))
+		return
+	}
+
+	req := msg.ChatRequest
+	var vs []violation
+	if req.IdEmpresa != 0 && req.IdEmpresa != c.idEmpresa {
+		vs = append(vs, violation{field: "id_empresa", code: CodeInvalidValue, arg: fmt.Sprint(req.IdEmpresa)})
+	}
+	req.IdEmpresa, req.ApiKey = c.idEmpresa, c.apiKey
+	if req.SessionID == 0 {
+		req.SessionID = c.sessionID
+	}
+	vs = append(vs, withTypeViolations(typeVs, validateChatRequest(&req, c.h.chat.Router, c.h.chat.StrictConfig))...)
+	if len(vs) > 0 {
+		c.sendError(msg.ID, validationEnvelope(c.lang, vs))
+		return
+	}
+
+	select {
+	case c.inFlight <- struct{}{}:
+	default:
+		c.sendError(msg.ID, errorEnvelope(c.lang, CodeTooManyInFlight, ""))
+		return
+	}
+	if !c.h.begin(c) {
+		<-c.inFlight
+		c.sendError(msg.ID, errorEnvelope(c.lang, CodeUnavailable, ""))
+		return
+	}
+
+	c.seq++
+	rid := fmt.Sprintf("%s-%d", c.id, c.seq)
+	// Cada mensaje tiene su request ID y target de log; no se anota en la linea de acceso del upgrade.
+	ctx := middleware.WithRequestID(middleware.WithoutAnnotations(c.ctx), rid)
+	ctx = logging.WithTarget(ctx, req.IdEmpresa, req.SessionID)
+	c.send(wsOutbound{Type: wsTypeProcessing, ID: msg.ID, RequestID: rid, Agent: c.h.chat.Router(req.Config.Modalidad)})
+
+	go func() {
+		defer c.pending.Done()
+		defer func() { <-c.inFlight }()
+		ctx, span := tracer.Start(ctx, "ws.chat")
+		defer span.End()
+		resp := c.h.chat.Chat(ctx, &req)
+		c.send(wsOutbound{Type: wsTypeReply, ID: msg.ID, RequestID: rid, ChatResponse: &resp})
+	}()
+}
+
+// allow aplica MaxMessagesPerMin en ventanas fijas de un minuto.
+func (c *wsConn) allow() bool {
+	now := time.Now()
+	if now.Sub(c.window) >= time.Minute {
+		c.window, c.count = now, 0
+	}
+	c.count++
+	return c.count <= c.h.opts.MaxMessagesPerMin
+}
+
+func (c *wsConn) sendError(id string, e ErrorResponse) {
+	c.send(wsOutbound{Type: wsTypeError, ID: id, ErrorResponse: &e})
+}
+
+// send escribe un evento JSON. Conn.Write admite escrituras concurrentes (respuestas en paralelo).
+func (c *wsConn) send(ev wsOutbound) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.ctx), wsWriteTimeout)
+	defer cancel()
+	if err := wsjson.Write(ctx, c.conn, ev); err != nil {
+		slog.DebugContext(c.ctx, "ws write", "request_id", c.id, "type", ev.Type, "err", err)
+	}
+}
+
+// validateWSAuth checks the auth message: id_empresa y api_key obligatorios, session_id opcional.
+func validateWSAuth(req *ChatRequest) []violation {
+	var vs []violation
+	if req.IdEmpresa <= 0 {
+		vs = append(vs, violation{field: "id_empresa", code: CodeMustBePositive})
+	}
+	if req.ApiKey == "" {
+		vs = append(vs, violation{field: "api_key", code: CodeRequired})
+	}
+	if req.SessionID < 0 {
+		vs = append(vs, violation{field: "session_id", code: CodeMustNotBeNegative})
+	}
+	return vs
+}
@@ -0,0 +1,291 @@
+// Package health sondea periodicamente a los agentes en segundo plano y guarda el resultado en cache.
+// /health y /readyz leen de esta cache: las probes de Kubernetes no generan trafico hacia los agentes.
+package health
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"gateway/internal/agent"
+	"gateway/internal/tlsutil"
+)
+
+// Estados de un agente.
+const (
+	StatusUnknown     = "unknown" // aun no se sondeo
+	StatusOK          = "ok"
+	StatusUnreachable = "unreachable"
+	StatusDisabled    = "disabled"
+	StatusNoURL       = "no_url"
+)
+
+// Defaults del monitor.
+const (
+	DefaultInterval    = 10 * time.Second
+	DefaultTimeout     = 2 * time.Second
+	DefaultHistorySize = 20
+)
+
+// Probe is one health check result kept in history.
+type Probe struct {
+	At        time.Time `json:"at"`
+	OK        bool      `json:"ok"`
+	LatencyMs int64     `json:"latency_ms"`
+}
+
+// AgentHealth is the cached health of one agent.
+type AgentHealth struct {
+	Status              string     `json:"status"`
+	Critical            bool       `json:"critical"`
+	LastCheck           *time.Time `json:"last_check,omitempty"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LatencyMs           int64      `json:"latency_ms"`     // ultima probe
+	AvgLatencyMs        int64      `json:"avg_latency_ms"` // promedio de las probes exitosas en el historial
+	History             []Probe    `json:"history,omitempty"`
+}
+
+// Options configures the monitor. Campos en cero usan los defaults.
+type Options struct {
+	Interval    time.Duration
+	Timeout     time.Duration
+	HistorySize int
+	Critical    []string      // agentes que deben estar ok para /readyz
+	TLSReload   time.Duration // revision del certificado de cliente de los agentes con mTLS; 0 = tlsutil.DefaultReloadInterval
+	Observer    ProbeObserver // nil = nadie consume los resultados (ej. circuit breakers del invoker)
+}
+
+// ProbeObserver receives every probe result (implemented by proxy.Invoker).
+type ProbeObserver interface {
+	ObserveProbe(agent string, ok bool)
+}
+
+// AgentLister provides the agents to probe.
+type AgentLister interface {
+	All() []agent.AgentInfo
+}
+
+type agentState struct {
+	info    agent.AgentInfo
+	client  *http.Client
+	health  AgentHealth
+	history []Probe // ring buffer
+	next    int
+}
+
+// Monitor probes every agent in the background and caches the results.
+type Monitor struct {
+	opts     Options
+	client   *http.Client
+	critical map[string]bool
+
+	mu      sync.RWMutex
+	agents  map[string]*agentState
+	started bool // true tras la primera ronda completa
+}
+
+// NewMonitor builds a monitor for the given agents. Call Run to start probing.
+func NewMonitor(agents AgentLister, opts Options) *Monitor {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.HistorySize <= 0 {
+		opts.HistorySize = DefaultHistorySize
+	}
+	m := &Monitor{
+		opts:     opts,
+		client:   &http.Client{Timeout: opts.Timeout},
+		critical: make(map[string]bool, len(opts.Critical)),
+		agents:   make(map[string]*agentState),
+	}
+	probed := make(map[string]bool)
+	for _, a := range agents.All() {
+		probed[a.Key] = a.Enabled && a.HealthURL != ""
+	}
+	// Un critico que nunca se sondea (deshabilitado, sin health URL o inexistente) dejaria /readyz
+	// en 503 para siempre: se ignora con un warning.
+	for _, k := range opts.Critical {
+		if !probed[k] {
+			slog.Warn("health critical agent ignored: not probed (disabled, no health URL or unknown)", "agent", k)
+			continue
+		}
+		m.critical[k] = true
+	}
+	for _, a := range agents.All() {
+		st := &agentState{
+			info:   a,
+			client: m.client,
+			health: AgentHealth{Status: StatusUnknown, Critical: m.critical[a.Key]},
+		}
+		if !a.TLS.IsZero() {
+			// Misma CA / certificado de cliente que las llamadas de chat.
+			tlsCfg, err := tlsutil.ClientConfig(a.TLS, opts.TLSReload)
+			if err != nil {
+				slog.Warn("health tls config", "agent", a.Key, "err", err)
+			} else {
+				st.client = &http.Client{Timeout: opts.Timeout, Transport: &http.Transport{TLSClientConfig: tlsCfg}}
+			}
+		}
+		switch {
+		case !a.Enabled:
+			st.health.Status = StatusDisabled
+		case a.HealthURL == "":
+			st.health.Status = StatusNoURL
+		}
+		m.agents[a.Key] = st
+	}
+	return m
+}
+
+// Run probes all agents every interval until ctx is done. La primera ronda se ejecuta de inmediato.
+func (m *Monitor) Run(ctx context.Context) {
+	m.probeAll(ctx)
+	m.mu.Lock()
+	m.started = true
+	m.mu.Unlock()
+
+	t := time.NewTicker(m.opts.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			m.probeAll(ctx)
+		}
+	}
+}
+
+// probeAll sondea en paralelo todos los agentes habilitados con health URL.
+func (m *Monitor) probeAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for key, st := range m.agents {
+		if !st.info.Enabled || st.info.HealthURL == "" {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			ok := m.probe(ctx, st)
+			m.record(key, ok, start, time.Since(start))
+			if m.opts.Observer != nil && ctx.Err() == nil {
+				m.opts.Observer.ObserveProbe(key, ok)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (m *Monitor) probe(ctx context.Context, st *agentState) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, st.info.HealthURL, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := st.client.Do(req)
+	if err != nil {
+		return false
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
+
+func (m *Monitor) record(key string, ok bool, at time.Time, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st := m.agents[key]
+	p := Probe{At: at.UTC(), OK: ok, LatencyMs: latency.Milliseconds()}
+	if len(st.history) < m.opts.HistorySize {
+		st.history = append(st.history, p)
+	} else {
+		st.history[st.next] = p
+	}
+	st.next = (st.next + 1) % m.opts.HistorySize
+
+	h := &st.health
+	h.LastCheck = &p.At
+	h.LatencyMs = p.LatencyMs
+	if ok {
+		if h.Status != StatusOK && h.Status != StatusUnknown {
+			slog.Info("agent health recovered", "agent", key, "after_failures", h.ConsecutiveFailures)
+		}
+		h.Status = StatusOK
+		h.LastSuccess = &p.At
+		h.ConsecutiveFailures = 0
+	} else {
+		if h.Status == StatusOK {
+			slog.Warn("agent health check failed", "agent", key)
+		}
+		h.Status = StatusUnreachable
+		h.ConsecutiveFailures++
+	}
+}
+
+// Snapshot returns a copy of every agent's cached health. withHistory incluye el historial ordenado.
+func (m *Monitor) Snapshot(withHistory bool) map[string]AgentHealth {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make(map[string]AgentHealth, len(m.agents))
+	for key, st := range m.agents {
+		h := st.health
+		ordered := st.ordered()
+		h.AvgLatencyMs = avgLatency(ordered)
+		if withHistory {
+			h.History = ordered
+		}
+		out[key] = h
+	}
+	return out
+}
+
+// Ready reports whether the first probe round finished and every critical agent is ok.
+// Devuelve tambien los agentes criticos que no estan ok.
+func (m *Monitor) Ready() (bool, []string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if !m.started {
+		return false, nil
+	}
+	var down []string
+	for key := range m.critical {
+		if m.agents[key].health.Status != StatusOK {
+			down = append(down, key)
+		}
+	}
+	sort.Strings(down)
+	return len(down) == 0, down
+}
+
+// ordered devuelve el historial del mas antiguo al mas reciente.
+func (st *agentState) ordered() []Probe {
+	if len(st.history) == 0 {
+		return nil
+	}
+	// Mientras el buffer no esta lleno next == len(history) y history[next:] es vacio.
+	out := make([]Probe, 0, len(st.history))
+	out = append(out, st.history[st.next:]...)
+	return append(out, st.history[:st.next]...)
+}
+
+func avgLatency(ps []Probe) int64 {
+	var sum, n int64
+	for _, p := range ps {
+		if p.OK {
+			sum += p.LatencyMs
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / n
+}
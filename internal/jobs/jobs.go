@@ -0,0 +1,154 @@
+// Package jobs guarda y ejecuta los jobs del modo async de POST /api/agent/chat: estado en memoria,
+// acotado a MaxJobs y con TTL desde que terminan. Un reinicio del gateway pierde los jobs.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Status is the job lifecycle state.
+type Status string
+
+const (
+	StatusPending Status = "pending" // aceptado, todavia sin llamar al agente
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"   // el agente respondio
+	StatusFailed  Status = "failed" // fallback (ver Reason); Result trae la respuesta de fallback
+)
+
+// ErrFull is returned by Create when MaxJobs jobs are pending, running or not yet expired.
+var ErrFull = errors.New("job store full")
+
+// Job is the state of an async chat. Es tambien el payload del callback.
+type Job struct {
+	ID          string     `json:"id"`
+	Status      Status     `json:"status"`
+	RequestID   string     `json:"request_id,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	Reason      string     `json:"reason,omitempty"` // motivo del fallback (timeout, backpressure, ...)
+	Result      any        `json:"result,omitempty"`
+	CallbackURL string     `json:"-"`
+}
+
+// Finished reports whether the job reached a final state.
+func (j *Job) Finished() bool {
+	return j.Status == StatusDone || j.Status == StatusFailed
+}
+
+// Store keeps jobs in memory. Los terminados se borran TTL despues de FinishedAt; los pendientes
+// y en curso no expiran (los acota el timeout del job).
+type Store struct {
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	maxJobs int
+	ttl     time.Duration
+}
+
+// NewStore creates a store; maxJobs <= 0 = 10000, ttl <= 0 = 1h.
+func NewStore(maxJobs int, ttl time.Duration) *Store {
+	if maxJobs <= 0 {
+		maxJobs = 10000
+	}
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &Store{jobs: make(map[string]*Job), maxJobs: maxJobs, ttl: ttl}
+}
+
+// Create registers a pending job. El ID es aleatorio (128 bits): conocerlo permite consultar el job.
+func (s *Store) Create(requestID, callbackURL string) (Job, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.jobs) >= s.maxJobs {
+		s.expireLocked(now)
+		if len(s.jobs) >= s.maxJobs {
+			return Job{}, ErrFull
+		}
+	}
+	j := &Job{ID: newID(), Status: StatusPending, RequestID: requestID, CreatedAt: now.UTC(), CallbackURL: callbackURL}
+	s.jobs[j.ID] = j
+	return *j, nil
+}
+
+// Get returns a copy of the job. ok=false si no existe o ya expiro.
+func (s *Store) Get(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok || s.expired(j, time.Now()) {
+		return Job{}, false
+	}
+	return *j, true
+}
+
+// Start marks the job as running.
+func (s *Store) Start(id string) {
+	now := time.Now().UTC()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if j, ok := s.jobs[id]; ok {
+		j.Status = StatusRunning
+		j.StartedAt = &now
+	}
+}
+
+// Finish stores the result. reason "" = done; si no, failed con ese motivo.
+func (s *Store) Finish(id string, result any, reason string) (Job, bool) {
+	now := time.Now().UTC()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	j.Status = StatusDone
+	if reason != "" {
+		j.Status = StatusFailed
+	}
+	j.Reason = reason
+	j.Result = result
+	j.FinishedAt = &now
+	return *j, true
+}
+
+// Run removes expired jobs every interval until ctx is done.
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			s.mu.Lock()
+			s.expireLocked(now)
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *Store) expireLocked(now time.Time) {
+	for id, j := range s.jobs {
+		if s.expired(j, now) {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+func (s *Store) expired(j *Job, now time.Time) bool {
+	return j.FinishedAt != nil && now.Sub(*j.FinishedAt) > s.ttl
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,109 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by Submit after Shutdown.
+var ErrClosed = errors.New("job runner closed")
+
+// EventFinished is the webhook event of a finished job.
+const EventFinished = "job.finished"
+
+// Notifier delivers a finished job to its callback URL (implemented by webhook.Dispatcher).
+type Notifier interface {
+	Send(ctx context.Context, url, event string, payload any)
+}
+
+// Func runs the job. reason "" = exito; result se guarda tal cual en Job.Result.
+type Func func(ctx context.Context) (result any, reason string)
+
+// Runner executes jobs in background with their own deadline (independiente del request HTTP).
+type Runner struct {
+	store    *Store
+	timeout  time.Duration
+	notifier Notifier // nil = callback_url no se entrega
+
+	base   context.Context // se cancela si Shutdown vence antes de que terminen los jobs
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewRunner creates a runner over store. timeout es el deadline de cada job.
+func NewRunner(store *Store, timeout time.Duration, notifier Notifier) *Runner {
+	base, cancel := context.WithCancel(context.Background())
+	return &Runner{store: store, timeout: timeout, notifier: notifier, base: base, cancel: cancel}
+}
+
+// Submit creates a job and runs fn in background. ctx aporta los valores (request ID, traza, overrides
+// de log); su cancelacion no afecta al job. requestID queda en el job para correlacionar con los logs.
+func (r *Runner) Submit(ctx context.Context, requestID, callbackURL string, fn Func) (Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return Job{}, ErrClosed
+	}
+	job, err := r.store.Create(requestID, callbackURL)
+	if err != nil {
+		return Job{}, err
+	}
+	r.wg.Add(1)
+	go r.run(context.WithoutCancel(ctx), job, fn)
+	return job, nil
+}
+
+// Get returns the job by ID.
+func (r *Runner) Get(id string) (Job, bool) {
+	return r.store.Get(id)
+}
+
+func (r *Runner) run(ctx context.Context, job Job, fn Func) {
+	defer r.wg.Done()
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	stop := context.AfterFunc(r.base, cancel)
+	defer func() {
+		stop()
+		cancel()
+	}()
+
+	r.store.Start(job.ID)
+	result, reason := fn(ctx)
+	done, ok := r.store.Finish(job.ID, result, reason)
+	if !ok {
+		return
+	}
+	slog.InfoContext(ctx, "job finished", "job_id", job.ID, "status", done.Status, "reason", reason)
+	if done.CallbackURL != "" && r.notifier != nil {
+		r.notifier.Send(ctx, done.CallbackURL, EventFinished, done)
+	}
+}
+
+// Shutdown stops accepting jobs and waits for the running ones. Si ctx vence, los cancela
+// (terminan como failed, con su callback) y espera a que salgan.
+func (r *Runner) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		r.cancel()
+		return nil
+	case <-ctx.Done():
+		r.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
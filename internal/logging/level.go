@@ -0,0 +1,266 @@
+// Package logging controla el nivel de log en caliente: un slog.LevelVar global (endpoint admin o SIGUSR1)
+// y overrides temporales que activan debug solo para ciertos id_empresa o session_id.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limites de los overrides temporales.
+const (
+	DefaultOverrideTTL = 15 * time.Minute
+	MaxOverrideTTL     = 24 * time.Hour
+)
+
+// ParseLevel converts debug, info, warn(ing) or error to a slog.Level. ok=false si el valor es desconocido.
+func ParseLevel(s string) (slog.Level, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, true
+	case "info":
+		return slog.LevelInfo, true
+	case "warn", "warning":
+		return slog.LevelWarn, true
+	case "error":
+		return slog.LevelError, true
+	default:
+		return slog.LevelInfo, false
+	}
+}
+
+// LevelName returns the lowercase name used in config and the admin API.
+func LevelName(l slog.Level) string {
+	return strings.ToLower(l.String())
+}
+
+// Override turns on debug logging for one tenant or session until ExpiresAt.
+type Override struct {
+	IdEmpresa int       `json:"id_empresa,omitempty"`
+	SessionID int       `json:"session_id,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type overrideKey struct {
+	idEmpresa int
+	sessionID int
+}
+
+// Controller holds the runtime log level and the temporary debug overrides.
+type Controller struct {
+	level   *slog.LevelVar
+	initial slog.Level
+
+	mu        sync.Mutex
+	overrides map[overrideKey]time.Time
+	// snapshot inmutable leido sin lock en Enabled (camino caliente de cada slog.Debug).
+	active atomic.Pointer[map[overrideKey]time.Time]
+}
+
+// NewController starts at the given level (LOG_LEVEL).
+func NewController(initial slog.Level) *Controller {
+	c := &Controller{
+		level:     new(slog.LevelVar),
+		initial:   initial,
+		overrides: make(map[overrideKey]time.Time),
+	}
+	c.level.Set(initial)
+	return c
+}
+
+// Level returns the current global level.
+func (c *Controller) Level() slog.Level { return c.level.Level() }
+
+// SetLevel changes the global level for every logger.
+func (c *Controller) SetLevel(l slog.Level) {
+	old := c.level.Level()
+	c.level.Set(l)
+	slog.Warn("log level changed", "from", LevelName(old), "to", LevelName(l))
+}
+
+// Toggle alterna entre debug y el nivel inicial (SIGUSR1). Si el inicial ya es debug, alterna con info.
+func (c *Controller) Toggle() slog.Level {
+	next := slog.LevelDebug
+	if c.level.Level() == slog.LevelDebug {
+		next = c.initial
+		if next == slog.LevelDebug {
+			next = slog.LevelInfo
+		}
+	}
+	c.SetLevel(next)
+	return next
+}
+
+// AddOverride enables debug logs for idEmpresa or sessionID (uno de los dos, > 0) during ttl.
+// ttl <= 0 usa DefaultOverrideTTL; se recorta a MaxOverrideTTL.
+func (c *Controller) AddOverride(idEmpresa, sessionID int, ttl time.Duration) Override {
+	if ttl <= 0 {
+		ttl = DefaultOverrideTTL
+	}
+	if ttl > MaxOverrideTTL {
+		ttl = MaxOverrideTTL
+	}
+	exp := time.Now().Add(ttl).UTC()
+	c.mu.Lock()
+	c.overrides[overrideKey{idEmpresa, sessionID}] = exp
+	c.publish()
+	c.mu.Unlock()
+	slog.Warn("log debug override added", "id_empresa", idEmpresa, "session_id", sessionID, "expires_at", exp)
+	return Override{IdEmpresa: idEmpresa, SessionID: sessionID, ExpiresAt: exp}
+}
+
+// RemoveOverride deletes an override before it expires. false si no existia.
+func (c *Controller) RemoveOverride(idEmpresa, sessionID int) bool {
+	k := overrideKey{idEmpresa, sessionID}
+	c.mu.Lock()
+	_, ok := c.overrides[k]
+	delete(c.overrides, k)
+	c.publish()
+	c.mu.Unlock()
+	if ok {
+		slog.Warn("log debug override removed", "id_empresa", idEmpresa, "session_id", sessionID)
+	}
+	return ok
+}
+
+// Overrides lists the active overrides sorted by expiration.
+func (c *Controller) Overrides() []Override {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]Override, 0, len(c.overrides))
+	for k, exp := range c.overrides {
+		if exp.After(now) {
+			out = append(out, Override{IdEmpresa: k.idEmpresa, SessionID: k.sessionID, ExpiresAt: exp})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ExpiresAt.Before(out[j].ExpiresAt) })
+	return out
+}
+
+// Run borra los overrides vencidos cada interval hasta que ctx termine. Enabled ya ignora los
+// vencidos; Run solo libera memoria y deja constancia en el log.
+func (c *Controller) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			c.expire(now)
+		}
+	}
+}
+
+func (c *Controller) expire(now time.Time) {
+	c.mu.Lock()
+	var expired []overrideKey
+	for k, exp := range c.overrides {
+		if !exp.After(now) {
+			expired = append(expired, k)
+			delete(c.overrides, k)
+		}
+	}
+	if len(expired) > 0 {
+		c.publish()
+	}
+	c.mu.Unlock()
+	for _, k := range expired {
+		slog.Info("log debug override expired", "id_empresa", k.idEmpresa, "session_id", k.sessionID)
+	}
+}
+
+// publish copia el mapa al snapshot atomico. Requiere c.mu.
+func (c *Controller) publish() {
+	if len(c.overrides) == 0 {
+		c.active.Store(nil)
+		return
+	}
+	m := make(map[overrideKey]time.Time, len(c.overrides))
+	for k, v := range c.overrides {
+		m[k] = v
+	}
+	c.active.Store(&m)
+}
+
+// enabled reports whether a record at level should be logged for ctx.
+func (c *Controller) enabled(ctx context.Context, level slog.Level) bool {
+	if level >= c.level.Level() {
+		return true
+	}
+	if level < slog.LevelDebug {
+		return false
+	}
+	m := c.active.Load()
+	if m == nil || ctx == nil {
+		return false
+	}
+	t, ok := ctx.Value(targetKey).(target)
+	if !ok {
+		return false
+	}
+	now := time.Now()
+	if exp, ok := (*m)[overrideKey{idEmpresa: t.idEmpresa}]; ok && t.idEmpresa > 0 && exp.After(now) {
+		return true
+	}
+	if exp, ok := (*m)[overrideKey{sessionID: t.sessionID}]; ok && t.sessionID > 0 && exp.After(now) {
+		return true
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
+// Contexto
+// ---------------------------------------------------------------------------
+
+type ctxKey string
+
+const targetKey ctxKey = "log_target"
+
+type target struct {
+	idEmpresa int
+	sessionID int
+}
+
+// WithTarget tags ctx with the tenant and session of the request so overrides can match
+// los logs emitidos con slog.*Context a lo largo del request (handler, guardrails, invoker).
+func WithTarget(ctx context.Context, idEmpresa, sessionID int) context.Context {
+	return context.WithValue(ctx, targetKey, target{idEmpresa: idEmpresa, sessionID: sessionID})
+}
+
+// ---------------------------------------------------------------------------
+// slog.Handler
+// ---------------------------------------------------------------------------
+
+// Handler filters records with the Controller. El handler envuelto debe aceptar debug
+// (HandlerOptions.Level = slog.LevelDebug): el filtro real es Enabled.
+type Handler struct {
+	slog.Handler
+	c *Controller
+}
+
+// NewHandler wraps h with the controller's level and overrides.
+func NewHandler(h slog.Handler, c *Controller) *Handler {
+	return &Handler{Handler: h, c: c}
+}
+
+// Enabled implements slog.Handler.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.c.enabled(ctx, level)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{Handler: h.Handler.WithAttrs(attrs), c: h.c}
+}
+
+// WithGroup implements slog.Handler.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{Handler: h.Handler.WithGroup(name), c: h.c}
+}
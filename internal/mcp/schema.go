@@ -0,0 +1,77 @@
+package mcp
+
+import (
+	"reflect"
+	"strings"
+
+	"gateway/internal/domain"
+	"gateway/internal/handler"
+)
+
+// required are the ChatRequest fields the tool always needs (los mismos que valida POST /api/agent/chat).
+var required = []string{"message", "session_id", "id_empresa", "api_key"}
+
+// fieldSchema adds descriptions and bounds per JSON path. config.modalidad no se expone: el tool
+// ya elige el agente.
+var fieldSchema = map[string]map[string]any{
+	"message":                      {"description": "Mensaje del usuario final para el agente.", "minLength": 1},
+	"session_id":                   {"description": "Identificador de la conversacion; el agente guarda el historial por session_id.", "minimum": 1},
+	"id_empresa":                   {"description": "Tenant (empresa) que atiende la conversacion.", "minimum": 1},
+	"api_key":                      {"description": "api_key del tenant."},
+	"config":                       {"description": "Configuracion del bot del tenant (mismo objeto config de POST /api/agent/chat)."},
+	"config.modalidad":             nil,
+	"config.duracion_cita_minutos": {"minimum": 0},
+	"config.slots":                 {"minimum": 0},
+	"config.usuario_id":            {"minimum": 0},
+	"config.id_chatbot":            {"minimum": 0},
+}
+
+var (
+	flexBool = reflect.TypeFor[domain.FlexBool]()
+	flexInt  = reflect.TypeFor[domain.FlexInt]()
+)
+
+// inputSchema derives the tool input schema (JSON Schema) from handler.ChatRequest y sus tags json:
+// un campo nuevo en ChatRequest aparece en los tools sin tocar este paquete.
+func inputSchema() map[string]any {
+	s := objectSchema(reflect.TypeFor[handler.ChatRequest](), "")
+	s["required"] = required
+	return s
+}
+
+func objectSchema(t reflect.Type, prefix string) map[string]any {
+	props := map[string]any{}
+	for f := range t.Fields() {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		p := prefix + name
+		extra, ok := fieldSchema[p]
+		if ok && extra == nil {
+			continue
+		}
+		var prop map[string]any
+		switch {
+		case f.Type == flexBool:
+			prop = map[string]any{"type": "boolean"}
+		case f.Type == flexInt:
+			prop = map[string]any{"type": "integer"}
+		case f.Type.Kind() == reflect.Struct:
+			prop = objectSchema(f.Type, p+".")
+		case f.Type.Kind() == reflect.String:
+			prop = map[string]any{"type": "string"}
+		case f.Type.Kind() == reflect.Bool:
+			prop = map[string]any{"type": "boolean"}
+		case f.Type.Kind() >= reflect.Int && f.Type.Kind() <= reflect.Uint64:
+			prop = map[string]any{"type": "integer"}
+		default:
+			continue
+		}
+		for k, v := range extra {
+			prop[k] = v
+		}
+		props[name] = prop
+	}
+	return map[string]any{"type": "object", "properties": props}
+}
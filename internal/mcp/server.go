@@ -0,0 +1,269 @@
+// Package mcp sirve los agentes como tools de MCP (Model Context Protocol) por stdio y HTTP.
+// Cada agente habilitado del registry es un tool; la llamada pasa por el mismo ChatHandler que
+// POST /api/agent/chat: guardrails, circuit breakers, semaforos, metricas y transcript no cambian.
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"runtime/debug"
+	"slices"
+	"strings"
+
+	"gateway/internal/agent"
+	"gateway/internal/domain"
+	"gateway/internal/handler"
+	"gateway/internal/logging"
+	"gateway/internal/middleware"
+)
+
+// ServerName identifies the gateway in the initialize response.
+const ServerName = "maravia-gateway"
+
+// protocolVersions are the MCP revisions the server speaks, newest first.
+var protocolVersions = []string{"2025-11-25", "2025-06-18", "2025-03-26", "2024-11-05"}
+
+// Codigos de error JSON-RPC 2.0.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// instructions is the hint MCP clients pass to the model.
+const instructions = "Cada tool es un agente especializado de MaravIA. session_id identifica la conversacion: " +
+	"el agente guarda el historial, asi que usa el mismo session_id en los mensajes siguientes."
+
+// ChatService runs a chat exchange against a given agent (implemented by handler.ChatHandler).
+type ChatService interface {
+	ChatAgent(ctx context.Context, agent string, req *handler.ChatRequest) (handler.ChatResponseV2, string)
+	ValidateAgent(req *handler.ChatRequest, lang string) *handler.ErrorResponse
+}
+
+// Options configures the MCP server.
+type Options struct {
+	// Tokens Bearer aceptados en POST /mcp. Vacio = sin autenticacion (stdio no autentica).
+	Tokens []string
+}
+
+// Server answers MCP requests (initialize, ping, tools/list, tools/call).
+type Server struct {
+	chat    ChatService
+	agents  *agent.Registry
+	tokens  []string
+	schema  map[string]any
+	version string
+}
+
+// New builds the server. Los tools se listan del registry en cada tools/list (solo agentes habilitados).
+func New(chat ChatService, agents *agent.Registry, o Options) *Server {
+	version := "(devel)"
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "" {
+		version = bi.Main.Version
+	}
+	return &Server{chat: chat, agents: agents, tokens: o.Tokens, schema: inputSchema(), version: version}
+}
+
+// request is a JSON-RPC 2.0 request or notification (sin id).
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// notification reports whether no response is expected (notificaciones y respuestas del cliente).
+func (r *request) notification() bool {
+	return len(r.ID) == 0 || r.Method == ""
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func errorResponse(id json.RawMessage, code int, msg string) *response {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &response{JSONRPC: "2.0", ID: id, Error: &rpcError{Code: code, Message: msg}}
+}
+
+// decode parses one message. Un error ya viene como respuesta JSON-RPC (parse error o invalid request).
+func decode(raw []byte) (*request, *response) {
+	var req request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return nil, errorResponse(nil, codeParseError, "parse error: "+err.Error())
+	}
+	if req.JSONRPC != "2.0" {
+		return nil, errorResponse(req.ID, codeInvalidRequest, `invalid request: jsonrpc must be "2.0"`)
+	}
+	return &req, nil
+}
+
+// call runs a request and returns its response (nil para notificaciones).
+func (s *Server) call(ctx context.Context, req *request) *response {
+	if req.notification() {
+		return nil
+	}
+	var (
+		result any
+		rerr   *rpcError
+	)
+	switch req.Method {
+	case "initialize":
+		result = s.initialize(req.Params)
+	case "ping":
+		result = struct{}{}
+	case "tools/list":
+		result = map[string]any{"tools": s.tools()}
+	case "tools/call":
+		result, rerr = s.callTool(ctx, req.Params)
+	default:
+		rerr = &rpcError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+	if rerr != nil {
+		return &response{JSONRPC: "2.0", ID: req.ID, Error: rerr}
+	}
+	return &response{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
+
+// initialize negotiates the protocol version: la del cliente si la soportamos, si no la mas nueva.
+func (s *Server) initialize(params json.RawMessage) map[string]any {
+	var p struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	_ = json.Unmarshal(params, &p)
+	version := protocolVersions[0]
+	if slices.Contains(protocolVersions, p.ProtocolVersion) {
+		version = p.ProtocolVersion
+	}
+	return map[string]any{
+		"protocolVersion": version,
+		"capabilities":    map[string]any{"tools": map[string]any{}},
+		"serverInfo":      map[string]any{"name": ServerName, "version": s.version},
+		"instructions":    instructions,
+	}
+}
+
+// tools lists one tool per enabled agent, ordenados por clave.
+func (s *Server) tools() []map[string]any {
+	out := []map[string]any{}
+	for _, a := range s.agents.All() {
+		if !a.Enabled {
+			continue
+		}
+		desc := fmt.Sprintf("Envia un mensaje al agente %s y devuelve su respuesta.", a.Key)
+		if m, ok := agent.ModalidadFor(a.Key); ok {
+			desc = fmt.Sprintf("Envia un mensaje al agente %s (modalidad %q) y devuelve su respuesta.", a.Key, m)
+		}
+		out = append(out, map[string]any{
+			"name":        a.Key,
+			"description": desc,
+			"inputSchema": s.schema,
+		})
+	}
+	return out
+}
+
+// toolResult is the structuredContent of tools/call: ChatResponseV2 mas el motivo del fallback.
+type toolResult struct {
+	handler.ChatResponseV2
+	Reason string `json:"reason,omitempty"`
+}
+
+// callTool invokes the agent named by the tool. Los errores del agente (fallback) y de validacion
+// van como resultado con isError, para que el modelo los vea; tool desconocido es error JSON-RPC.
+func (s *Server) callTool(ctx context.Context, params json.RawMessage) (any, *rpcError) {
+	var p struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "invalid params: " + err.Error()}
+	}
+	if !s.agents.Enabled(p.Name) {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "unknown tool: " + p.Name}
+	}
+
+	var req handler.ChatRequest
+	if len(p.Arguments) > 0 {
+		if err := json.Unmarshal(p.Arguments, &req); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) && typeErr.Field != "" {
+				return toolError(fmt.Sprintf("%s: invalid type %s", typeErr.Field, typeErr.Value)), nil
+			}
+			return toolError("invalid arguments: " + err.Error()), nil
+		}
+	}
+	// El agente recibe la modalidad que lo enruta en HTTP; sin modalidad, su clave.
+	req.Config.Modalidad = p.Name
+	if m, ok := agent.ModalidadFor(p.Name); ok {
+		req.Config.Modalidad = m
+	}
+	middleware.Annotate(ctx, "id_empresa", req.IdEmpresa, "tool", p.Name)
+	ctx = logging.WithTarget(ctx, req.IdEmpresa, req.SessionID)
+	if e := s.chat.ValidateAgent(&req, "en"); e != nil {
+		slog.DebugContext(ctx, "mcp invalid arguments", "request_id", middleware.GetRequestID(ctx), "tool", p.Name, "code", e.Code)
+		return toolError(e.Describe()), nil
+	}
+
+	resp, reason := s.chat.ChatAgent(ctx, p.Name, &req)
+	content := contentBlocks(resp.Messages)
+	if reason != "" {
+		content = append(content, textBlock("agent_failed: "+reason))
+	}
+	return map[string]any{
+		"content":           content,
+		"structuredContent": toolResult{ChatResponseV2: resp, Reason: reason},
+		"isError":           reason != "",
+	}, nil
+}
+
+func toolError(text string) map[string]any {
+	return map[string]any{"content": []map[string]any{textBlock(text)}, "isError": true}
+}
+
+// contentBlocks maps the agent reply to MCP content: imagenes y documentos como resource_link
+// (el gateway solo tiene la URL) y el resto como texto aplanado (botones incluidos).
+func contentBlocks(msgs []domain.Message) []map[string]any {
+	out := make([]map[string]any, 0, len(msgs))
+	for _, m := range msgs {
+		switch m.Type {
+		case domain.MessageImage, domain.MessageDocument:
+			name := m.Filename
+			if name == "" {
+				p := m.URL
+				if i := strings.IndexAny(p, "?#"); i >= 0 {
+					p = p[:i]
+				}
+				name = path.Base(p)
+			}
+			link := map[string]any{"type": "resource_link", "uri": m.URL, "name": name}
+			if m.Text != "" {
+				link["description"] = m.Text
+			}
+			out = append(out, link)
+		default:
+			text, _ := domain.Flatten([]domain.Message{m})
+			out = append(out, textBlock(text))
+		}
+	}
+	return out
+}
+
+func textBlock(text string) map[string]any {
+	return map[string]any{"type": "text", "text": text}
+}
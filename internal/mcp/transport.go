@@ -0,0 +1,179 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"gateway/internal/handler"
+	"gateway/internal/middleware"
+)
+
+// maxMessageBytes limita cada mensaje JSON-RPC (linea en stdio, body en HTTP).
+const maxMessageBytes = handler.MaxRequestBodyBytes
+
+// ---------------------------------------------------------------------------
+// stdio: un mensaje JSON por linea en in, respuestas por linea en out
+// ---------------------------------------------------------------------------
+
+// ServeStdio serves MCP over in/out until in hits EOF or ctx is cancelled. Los requests se atienden
+// en paralelo (cada uno con su request ID); notifications/cancelled cancela el request indicado.
+// Al volver, los requests en curso ya respondieron.
+func (s *Server) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	var (
+		mu       sync.Mutex // out e inflight
+		wg       sync.WaitGroup
+		inflight = make(map[string]context.CancelFunc)
+	)
+	enc := json.NewEncoder(out)
+	write := func(resp *response) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := enc.Encode(resp); err != nil {
+			slog.Warn("mcp stdio write", "err", err)
+		}
+	}
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		sc := bufio.NewScanner(in)
+		sc.Buffer(make([]byte, 0, 64<<10), maxMessageBytes)
+		for sc.Scan() {
+			select {
+			case lines <- bytes.Clone(sc.Bytes()):
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr <- sc.Err()
+	}()
+	defer wg.Wait()
+
+	for {
+		var line []byte
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-readErr:
+			return err
+		case line = <-lines:
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		req, errResp := decode(line)
+		if errResp != nil {
+			write(errResp)
+			continue
+		}
+		if req.Method == "notifications/cancelled" {
+			var p struct {
+				RequestID json.RawMessage `json:"requestId"`
+			}
+			_ = json.Unmarshal(req.Params, &p)
+			mu.Lock()
+			if cancel, ok := inflight[string(p.RequestID)]; ok {
+				cancel()
+			}
+			mu.Unlock()
+			continue
+		}
+		if req.notification() {
+			continue
+		}
+
+		callCtx, cancel := context.WithCancel(middleware.WithRequestID(ctx, middleware.NewRequestID()))
+		key := string(req.ID)
+		mu.Lock()
+		inflight[key] = cancel
+		mu.Unlock()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			resp := s.call(callCtx, req)
+			mu.Lock()
+			delete(inflight, key)
+			mu.Unlock()
+			cancelled := callCtx.Err() != nil && ctx.Err() == nil
+			cancel()
+			logCall(callCtx, req, resp, start)
+			if cancelled {
+				return // cancelado por el cliente: no se responde
+			}
+			write(resp)
+		}()
+	}
+}
+
+// logCall is the access log line of a stdio request (equivalente a middleware.Logger).
+func logCall(ctx context.Context, req *request, resp *response, start time.Time) {
+	code := 0
+	if resp.Error != nil {
+		code = resp.Error.Code
+	}
+	slog.InfoContext(ctx, "mcp request",
+		"request_id", middleware.GetRequestID(ctx),
+		"method", req.Method,
+		"code", code,
+		"duration_ms", time.Since(start).Milliseconds(),
+	)
+}
+
+// ---------------------------------------------------------------------------
+// HTTP (Streamable HTTP sin sesion ni SSE): POST /mcp con un mensaje, respuesta JSON
+// ---------------------------------------------------------------------------
+
+// ServeHTTP handles POST /mcp. Un request se responde con 200 y el JSON-RPC de respuesta; las
+// notificaciones con 202. No hay stream de servidor (GET responde 405 desde el router).
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if len(s.tokens) > 0 && !s.authorized(r.Header.Get("Authorization")) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="mcp"`)
+		writeJSON(w, http.StatusUnauthorized, errorResponse(nil, codeInvalidRequest, "unauthorized"))
+		return
+	}
+	raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageBytes))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, errorResponse(nil, codeInvalidRequest, "message too large"))
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, errorResponse(nil, codeParseError, "parse error: "+err.Error()))
+		return
+	}
+	req, errResp := decode(raw)
+	if errResp != nil {
+		writeJSON(w, http.StatusBadRequest, errResp)
+		return
+	}
+	middleware.Annotate(r.Context(), "mcp_method", req.Method)
+	if req.notification() {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.call(r.Context(), req))
+}
+
+func (s *Server) authorized(authorization string) bool {
+	for _, t := range s.tokens {
+		if middleware.ValidBearer(authorization, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
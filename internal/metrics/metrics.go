@@ -1,41 +1,262 @@
-package metrics
-
-import (
-	"time"
-
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
-)
-
-// Recorder wraps Prometheus metrics for chat requests.
-type Recorder struct {
-	requestsTotal   *prometheus.CounterVec
-	requestDuration *prometheus.HistogramVec
-}
-
-// NewRecorder creates a Recorder with registered Prometheus metrics.
-func NewRecorder() *Recorder {
-	return &Recorder{
-		requestsTotal: promauto.NewCounterVec(
-			prometheus.CounterOpts{
-				Name: "gateway_requests_total",
-				Help: "Total chat requests by agent and status",
-			},
-			[]string{"agent", "status"},
-		),
-		requestDuration: promauto.NewHistogramVec(
-			prometheus.HistogramOpts{
-				Name:    "gateway_request_duration_seconds",
-				Help:    "Chat request duration in seconds",
-				Buckets: prometheus.DefBuckets,
-			},
-			[]string{"agent"},
-		),
-	}
-}
-
-// Record registers a completed request with the given agent, status, and duration.
-func (r *Recorder) Record(agent, status string, duration time.Duration) {
-	r.requestsTotal.WithLabelValues(agent, status).Inc()
-	r.requestDuration.WithLabelValues(agent).Observe(duration.Seconds())
-}
+package metrics
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Valores del gauge gateway_circuit_breaker_state.
+const (
+	BreakerClosed   = 0
+	BreakerHalfOpen = 1
+	BreakerOpen     = 2
+)
+
+// tenantOther agrupa los tenants que no tienen label propio (limite de cardinalidad).
+const tenantOther = "other"
+
+// TenantPolicy bounds the cardinality of the "tenant" label.
+// Allow fija la lista de id_empresa con label propio; si esta vacia, los primeros Limit tenants
+// vistos obtienen label propio y el resto se agrupa en "other".
+type TenantPolicy struct {
+	Allow []int
+	Limit int
+}
+
+// Recorder wraps Prometheus metrics for chat requests.
+type Recorder struct {
+	requestsTotal   *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	guardrailEvents *prometheus.CounterVec
+	fallbacksTotal  *prometheus.CounterVec
+	inFlight        *prometheus.GaugeVec
+	breakerState    *prometheus.GaugeVec
+	retriesTotal    *prometheus.CounterVec
+	semaphoreWait   *prometheus.HistogramVec
+	wsConnections   prometheus.Gauge
+	webhookAttempts *prometheus.CounterVec
+	webhookResults  *prometheus.CounterVec
+	webhookDuration *prometheus.HistogramVec
+	webhookPending  prometheus.Gauge
+	webhookDead     prometheus.Gauge
+	channelMessages *prometheus.CounterVec
+
+	tenantMu    sync.Mutex
+	tenantAllow map[int]bool // nil = modo "primeros N"
+	tenantSeen  map[int]bool
+	tenantLimit int
+}
+
+// NewRecorder creates a Recorder with registered Prometheus metrics.
+func NewRecorder(tenants TenantPolicy) *Recorder {
+	r := &Recorder{
+		requestsTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gateway_requests_total",
+				Help: "Total chat requests by agent, status and tenant",
+			},
+			[]string{"agent", "status", "tenant"},
+		),
+		requestDuration: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "gateway_request_duration_seconds",
+				Help:    "Chat request duration in seconds",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"agent"},
+		),
+		guardrailEvents: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gateway_guardrail_events_total",
+				Help: "Guardrail rule violations by stage, rule and action",
+			},
+			[]string{"stage", "rule", "action"},
+		),
+		fallbacksTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gateway_fallbacks_total",
+				Help: "Fallback replies by agent and reason",
+			},
+			[]string{"agent", "reason"},
+		),
+		inFlight: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gateway_agent_in_flight",
+				Help: "Agent calls currently holding a semaphore slot",
+			},
+			[]string{"agent"},
+		),
+		breakerState: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gateway_circuit_breaker_state",
+				Help: "Circuit breaker state per agent (0=closed, 1=half-open, 2=open)",
+			},
+			[]string{"agent"},
+		),
+		retriesTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gateway_agent_retries_total",
+				Help: "Retries of agent calls after a transient connection error",
+			},
+			[]string{"agent"},
+		),
+		semaphoreWait: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "gateway_semaphore_wait_seconds",
+				Help:    "Time spent acquiring the per-agent semaphore (rejections included, capped by the max wait)",
+				Buckets: []float64{.0001, .0005, .001, .005, .01, .025, .05, .075, .1, .25},
+			},
+			[]string{"agent"},
+		),
+		wsConnections: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "gateway_ws_connections",
+				Help: "Open WebSocket chat connections",
+			},
+		),
+		webhookAttempts: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gateway_webhook_attempts_total",
+				Help: "Webhook delivery attempts by event and outcome (success, failure)",
+			},
+			[]string{"event", "outcome"},
+		),
+		webhookResults: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gateway_webhook_deliveries_total",
+				Help: "Webhook deliveries by final result (delivered, dead_letter)",
+			},
+			[]string{"event", "result"},
+		),
+		webhookDuration: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "gateway_webhook_attempt_duration_seconds",
+				Help:    "Duration of each webhook delivery attempt",
+				Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10},
+			},
+			[]string{"event"},
+		),
+		webhookPending: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "gateway_webhook_pending",
+				Help: "Webhook deliveries in progress or waiting for a retry",
+			},
+		),
+		webhookDead: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "gateway_webhook_dead_letters",
+				Help: "Webhook deliveries in the dead-letter list",
+			},
+		),
+		channelMessages: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gateway_channel_messages_total",
+				Help: "Inbound messaging channel messages by channel and outcome",
+			},
+			[]string{"channel", "outcome"},
+		),
+		tenantSeen:  make(map[int]bool),
+		tenantLimit: tenants.Limit,
+	}
+	if len(tenants.Allow) > 0 {
+		r.tenantAllow = make(map[int]bool, len(tenants.Allow))
+		for _, id := range tenants.Allow {
+			r.tenantAllow[id] = true
+		}
+	}
+	return r
+}
+
+// Record registers a completed request with the given agent, status, tenant and duration.
+func (r *Recorder) Record(agent, status string, idEmpresa int, duration time.Duration) {
+	r.requestsTotal.WithLabelValues(agent, status, r.tenantLabel(idEmpresa)).Inc()
+	r.requestDuration.WithLabelValues(agent).Observe(duration.Seconds())
+}
+
+// RecordFallback registers a fallback reply and its reason (domain.Reason*).
+func (r *Recorder) RecordFallback(agent, reason string) {
+	r.fallbacksTotal.WithLabelValues(agent, reason).Inc()
+}
+
+// RecordGuardrail registers a guardrail rule violation.
+func (r *Recorder) RecordGuardrail(stage, rule, action string) {
+	r.guardrailEvents.WithLabelValues(stage, rule, action).Inc()
+}
+
+// AddInFlight adjusts the in-flight gauge for an agent (+1 al tomar el semaforo, -1 al liberarlo).
+func (r *Recorder) AddInFlight(agent string, delta int) {
+	r.inFlight.WithLabelValues(agent).Add(float64(delta))
+}
+
+// SetBreakerState sets the breaker gauge (BreakerClosed, BreakerHalfOpen, BreakerOpen).
+func (r *Recorder) SetBreakerState(agent string, state int) {
+	r.breakerState.WithLabelValues(agent).Set(float64(state))
+}
+
+// IncRetry counts one retry for an agent.
+func (r *Recorder) IncRetry(agent string) {
+	r.retriesTotal.WithLabelValues(agent).Inc()
+}
+
+// ObserveSemaphoreWait records the time spent acquiring the agent semaphore.
+func (r *Recorder) ObserveSemaphoreWait(agent string, d time.Duration) {
+	r.semaphoreWait.WithLabelValues(agent).Observe(d.Seconds())
+}
+
+// AddWSConnections adjusts the open WebSocket connections gauge.
+func (r *Recorder) AddWSConnections(delta int) {
+	r.wsConnections.Add(float64(delta))
+}
+
+// ObserveWebhookAttempt records one delivery attempt and its duration.
+func (r *Recorder) ObserveWebhookAttempt(event string, ok bool, d time.Duration) {
+	outcome := "failure"
+	if ok {
+		outcome = "success"
+	}
+	r.webhookAttempts.WithLabelValues(event, outcome).Inc()
+	r.webhookDuration.WithLabelValues(event).Observe(d.Seconds())
+}
+
+// RecordWebhookResult records the final result of a delivery ("delivered", "dead_letter").
+func (r *Recorder) RecordWebhookResult(event, result string) {
+	r.webhookResults.WithLabelValues(event, result).Inc()
+}
+
+// SetWebhookQueue sets the pending and dead-letter gauges.
+func (r *Recorder) SetWebhookQueue(pending, dead int) {
+	r.webhookPending.Set(float64(pending))
+	r.webhookDead.Set(float64(dead))
+}
+
+// RecordChannelMessage counts one inbound channel message by outcome (channel.Outcome*).
+func (r *Recorder) RecordChannelMessage(channel, outcome string) {
+	r.channelMessages.WithLabelValues(channel, outcome).Inc()
+}
+
+// tenantLabel maps id_empresa to a bounded label value.
+func (r *Recorder) tenantLabel(idEmpresa int) string {
+	if idEmpresa <= 0 {
+		return tenantOther
+	}
+	if r.tenantAllow != nil {
+		if r.tenantAllow[idEmpresa] {
+			return strconv.Itoa(idEmpresa)
+		}
+		return tenantOther
+	}
+	r.tenantMu.Lock()
+	defer r.tenantMu.Unlock()
+	if r.tenantSeen[idEmpresa] {
+		return strconv.Itoa(idEmpresa)
+	}
+	if len(r.tenantSeen) < r.tenantLimit {
+		r.tenantSeen[idEmpresa] = true
+		return strconv.Itoa(idEmpresa)
+	}
+	return tenantOther
+}
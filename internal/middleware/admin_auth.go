@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// AdminAuth protects operational endpoints with "Authorization: Bearer <token>".
+// Comparacion en tiempo constante; token vacio = todo request se rechaza.
+func AdminAuth(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !ValidBearer(r.Header.Get("Authorization"), token) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"detail":"unauthorized","code":"unauthorized"}` + "\n"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ValidBearer reports whether authorization is "Bearer <token>" (tiempo constante; token vacio = false).
+// Compartido con el interceptor de auth de gRPC.
+func ValidBearer(authorization, token string) bool {
+	got, ok := strings.CutPrefix(authorization, "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
@@ -1,39 +1,221 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 )
 
-// CORS sets Access-Control-Allow-* headers. origins is comma-separated (e.g. "*" or "https://app.maravia.pe,https://n8n.maravia.pe").
-func CORS(origins string) func(http.Handler) http.Handler {
-	allowed := strings.Split(origins, ",")
-	for i := range allowed {
-		allowed[i] = strings.TrimSpace(allowed[i])
+// CORSPolicy describes the CORS rules for a group of routes.
+//
+// Origins acepta tres formas:
+//   - exacto: "https://app.maravia.pe" (o "*" para cualquier origen, sin credenciales)
+//   - subdominio: "https://*.maravia.pe" (cualquier subdominio, no el apex)
+//   - regex: "re:^https://widget-[a-z0-9]+\.maravia\.pe$"
+//
+// Access-Control-Allow-Credentials solo se envia cuando el origen coincide con una entrada exacta
+// (hallazgo C3: nunca con "*" ni con comodines).
+type CORSPolicy struct {
+	Name             string
+	PathPrefix       string // vacio = politica por defecto
+	Origins          []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAgeSec        int // Access-Control-Max-Age; 0 = no se envia
+}
+
+// Defaults used when a policy leaves a list empty.
+var (
+	DefaultCORSMethods        = []string{"GET", "POST", "OPTIONS"}
+	DefaultCORSAllowedHeaders = []string{"Content-Type", "Accept", "Authorization", "X-Request-ID"}
+	DefaultCORSExposedHeaders = []string{"X-Request-ID"}
+)
+
+type originKind int
+
+const (
+	originAny originKind = iota
+	originExact
+	originSubdomain
+	originRegex
+)
+
+type originMatcher struct {
+	kind   originKind
+	exact  string         // originExact
+	scheme string         // originSubdomain
+	suffix string         // originSubdomain: ".maravia.pe"
+	re     *regexp.Regexp // originRegex
+}
+
+// compiledPolicy is a CORSPolicy with precomputed header values.
+type compiledPolicy struct {
+	name        string
+	prefix      string
+	matchers    []originMatcher
+	credentials bool
+	methods     string
+	headers     string
+	exposed     string
+	maxAge      string
+}
+
+// CORS returns a middleware that applies the policy whose PathPrefix is the longest match for the
+// request path, falling back to def. Se aplica globalmente (antes del router) para que los preflight
+// OPTIONS reciban la politica de la ruta aunque no haya un handler OPTIONS registrado.
+func CORS(def CORSPolicy, routes ...CORSPolicy) (func(http.Handler) http.Handler, error) {
+	defPolicy, err := compilePolicy(def)
+	if err != nil {
+		return nil, err
+	}
+	routePolicies := make([]*compiledPolicy, 0, len(routes))
+	for _, p := range routes {
+		if p.PathPrefix == "" {
+			return nil, fmt.Errorf("cors policy %q: path prefix is required", p.Name)
+		}
+		cp, err := compilePolicy(p)
+		if err != nil {
+			return nil, err
+		}
+		routePolicies = append(routePolicies, cp)
+	}
+
+	choose := func(path string) *compiledPolicy {
+		best := defPolicy
+		for _, p := range routePolicies {
+			if pathHasPrefix(path, p.prefix) && len(p.prefix) > len(best.prefix) {
+				best = p
+			}
+		}
+		return best
 	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			origin := r.Header.Get("Origin")
-			if origin != "" {
-				for _, o := range allowed {
-					if o == "*" || o == origin {
-						val := origin
-						if o == "*" {
-							val = "*"
+			p := choose(r.URL.Path)
+			h := w.Header()
+			h.Add("Vary", "Origin")
+
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if preflight {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if origin := r.Header.Get("Origin"); origin != "" {
+				if allowOrigin, explicit, ok := p.match(origin); ok {
+					h.Set("Access-Control-Allow-Origin", allowOrigin)
+					if p.credentials && explicit {
+						h.Set("Access-Control-Allow-Credentials", "true")
+					}
+					if preflight {
+						h.Set("Access-Control-Allow-Methods", p.methods)
+						h.Set("Access-Control-Allow-Headers", p.headers)
+						if p.maxAge != "" {
+							h.Set("Access-Control-Max-Age", p.maxAge)
 						}
-						w.Header().Set("Access-Control-Allow-Origin", val)
-						break
+					} else if p.exposed != "" {
+						h.Set("Access-Control-Expose-Headers", p.exposed)
 					}
 				}
 			}
-			w.Header().Set("Access-Control-Allow-Credentials", "true")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Accept")
+
 			if r.Method == http.MethodOptions {
 				w.WriteHeader(http.StatusNoContent)
 				return
 			}
 			next.ServeHTTP(w, r)
 		})
+	}, nil
+}
+
+// pathHasPrefix reports whether path is prefix or lies under it segment by segment: "/api/v2" cubre
+// "/api/v2/chat" pero no "/api/v2beta".
+func pathHasPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/")
+}
+
+// match returns the Access-Control-Allow-Origin value, whether the origin matched an exact entry, and ok.
+// Las entradas exactas van primero (compilePolicy): un "*" en la lista no le quita las credenciales a un
+// origen listado explicitamente.
+func (p *compiledPolicy) match(origin string) (string, bool, bool) {
+	lower := strings.ToLower(origin)
+	var u *url.URL
+	for _, m := range p.matchers {
+		switch m.kind {
+		case originAny:
+			return "*", false, true
+		case originExact:
+			if lower == m.exact {
+				return origin, true, true
+			}
+		case originSubdomain:
+			if u == nil {
+				parsed, err := url.Parse(lower)
+				if err != nil {
+					continue
+				}
+				u = parsed
+			}
+			if u.Scheme == m.scheme && strings.HasSuffix(u.Host, m.suffix) && len(u.Host) > len(m.suffix) {
+				return origin, false, true
+			}
+		case originRegex:
+			if m.re.MatchString(origin) {
+				return origin, false, true
+			}
+		}
+	}
+	return "", false, false
+}
+
+func compilePolicy(p CORSPolicy) (*compiledPolicy, error) {
+	cp := &compiledPolicy{
+		name:        p.Name,
+		prefix:      p.PathPrefix,
+		credentials: p.AllowCredentials,
+		methods:     strings.Join(orDefault(p.AllowedMethods, DefaultCORSMethods), ", "),
+		headers:     strings.Join(orDefault(p.AllowedHeaders, DefaultCORSAllowedHeaders), ", "),
+		exposed:     strings.Join(orDefault(p.ExposedHeaders, DefaultCORSExposedHeaders), ", "),
+	}
+	if p.MaxAgeSec > 0 {
+		cp.maxAge = strconv.Itoa(p.MaxAgeSec)
+	}
+	for _, o := range p.Origins {
+		o = strings.TrimSpace(o)
+		switch {
+		case o == "":
+			continue
+		case o == "*":
+			cp.matchers = append(cp.matchers, originMatcher{kind: originAny})
+		case strings.HasPrefix(o, "re:"):
+			re, err := regexp.Compile(strings.TrimPrefix(o, "re:"))
+			if err != nil {
+				return nil, fmt.Errorf("cors policy %q: origin %q: %w", p.Name, o, err)
+			}
+			cp.matchers = append(cp.matchers, originMatcher{kind: originRegex, re: re})
+		case strings.Contains(o, "://*."):
+			scheme, host, _ := strings.Cut(strings.ToLower(o), "://")
+			cp.matchers = append(cp.matchers, originMatcher{kind: originSubdomain, scheme: scheme, suffix: strings.TrimPrefix(host, "*")})
+		default:
+			cp.matchers = append(cp.matchers, originMatcher{kind: originExact, exact: strings.ToLower(strings.TrimRight(o, "/"))})
+		}
+	}
+	sort.SliceStable(cp.matchers, func(i, j int) bool {
+		return cp.matchers[i].kind == originExact && cp.matchers[j].kind != originExact
+	})
+	return cp, nil
+}
+
+func orDefault(v, def []string) []string {
+	if len(v) == 0 {
+		return def
 	}
+	return v
 }
@@ -1,33 +1,192 @@
-package middleware
-
-import (
-	"log/slog"
-	"net/http"
-	"time"
-)
-
-// Logger logs HTTP requests: method, path, status, duration.
-func Logger(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		wr := &responseWriter{ResponseWriter: w, status: http.StatusOK}
-		next.ServeHTTP(wr, r)
-		slog.Info("request",
-			"request_id", GetRequestID(r.Context()),
-			"method", r.Method,
-			"path", r.URL.Path,
-			"status", wr.status,
-			"duration_ms", time.Since(start).Milliseconds(),
-		)
-	})
-}
-
-type responseWriter struct {
-	http.ResponseWriter
-	status int
-}
-
-func (w *responseWriter) WriteHeader(code int) {
-	w.status = code
-	w.ResponseWriter.WriteHeader(code)
-}
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gateway/internal/config"
+)
+
+// LoggerOptions configures the access log.
+type LoggerOptions struct {
+	// TrustedProxies: IPs o CIDRs de proxies/balanceadores cuyo X-Forwarded-For / X-Real-IP se respeta.
+	// Vacio = remote_ip siempre es la IP de la conexion.
+	TrustedProxies []string
+	// ExcludePaths: rutas exactas, o prefijos terminados en "*" (ej. "/debug/*"), que no se registran.
+	ExcludePaths []string
+}
+
+// Logger logs HTTP requests with the default options (sin proxies confiables ni exclusiones).
+func Logger(next http.Handler) http.Handler {
+	mw, _ := NewLogger(LoggerOptions{})
+	return mw(next)
+}
+
+// NewLogger returns the access log middleware: method, path, query (con los parametros secretos
+// redactados, ej. hub.verify_token), status, duration, bytes, remote_ip, user_agent y los campos que
+// agregue el handler con Annotate (id_empresa, agent, fallback_reason).
+func NewLogger(o LoggerOptions) (func(http.Handler) http.Handler, error) {
+	trusted, err := parseNets(o.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if excluded(r.URL.Path, o.ExcludePaths) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			start := time.Now()
+			fields := &accessFields{}
+			ctx := context.WithValue(r.Context(), accessKey, fields)
+			wr := newResponseWriter(w)
+			next.ServeHTTP(wr, r.WithContext(ctx))
+
+			attrs := []any{
+				"request_id", GetRequestID(ctx),
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", wr.status,
+				"duration_ms", time.Since(start).Milliseconds(),
+				"bytes", wr.bytes,
+				"remote_ip", clientIP(r, trusted),
+				"user_agent", r.UserAgent(),
+			}
+			if r.URL.RawQuery != "" {
+				attrs = append(attrs, "query", config.RedactQuery(r.URL.RawQuery))
+			}
+			slog.InfoContext(ctx, "request", append(attrs, fields.attrs()...)...)
+		})
+	}, nil
+}
+
+// ---------------------------------------------------------------------------
+// Campos del handler
+// ---------------------------------------------------------------------------
+
+const accessKey ctxKey = "access_log"
+
+type accessFields struct {
+	mu   sync.Mutex
+	args []any
+}
+
+func (f *accessFields) attrs() []any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.args
+}
+
+// Annotate adds key/value pairs to the access log line of the current request
+// (ej. "id_empresa", 12, "agent", "cita", "fallback_reason", "timeout"). No-op fuera del Logger.
+func Annotate(ctx context.Context, args ...any) {
+	f, ok := ctx.Value(accessKey).(*accessFields)
+	if !ok || f == nil {
+		return
+	}
+	f.mu.Lock()
+	f.args = append(f.args, args...)
+	f.mu.Unlock()
+}
+
+// WithoutAnnotations detaches ctx from the access log line: Annotate pasa a ser no-op.
+// Para trabajo que vive dentro de un request largo (ej. cada mensaje de un WebSocket).
+func WithoutAnnotations(ctx context.Context) context.Context {
+	return context.WithValue(ctx, accessKey, (*accessFields)(nil))
+}
+
+// ---------------------------------------------------------------------------
+// Remote IP
+// ---------------------------------------------------------------------------
+
+// clientIP devuelve la IP del cliente. Si la conexion viene de un proxy confiable, recorre
+// X-Forwarded-For de derecha a izquierda y toma la primera IP que no es un proxy confiable
+// (las entradas de la izquierda las controla el cliente). Fallback: X-Real-IP.
+func clientIP(r *http.Request, trusted []*net.IPNet) string {
+	ip := remoteAddrIP(r.RemoteAddr)
+	if len(trusted) == 0 || !inNets(ip, trusted) {
+		return ip
+	}
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break // entrada invalida: no seguir confiando en lo que queda a la izquierda
+			}
+			if !inNets(hop, trusted) {
+				return hop
+			}
+			ip = hop
+		}
+		return ip
+	}
+	if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(xri) != nil {
+		return xri
+	}
+	return ip
+}
+
+func remoteAddrIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+func inNets(ip string, nets []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseNets acepta IPs sueltas ("10.0.0.1") o CIDRs ("10.0.0.0/8").
+func parseNets(list []string) ([]*net.IPNet, error) {
+	var out []*net.IPNet
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("trusted proxy %q: invalid IP", s)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			s = fmt.Sprintf("%s/%d", s, bits)
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", s, err)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+func excluded(path string, patterns []string) bool {
+	for _, p := range patterns {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == p {
+			return true
+		}
+	}
+	return false
+}
@@ -1,40 +1,51 @@
-package middleware
-
-import (
-	"context"
-	"crypto/rand"
-	"encoding/hex"
-	"net/http"
-)
-
-type ctxKey string
-
-const requestIDKey ctxKey = "request_id"
-
-// RequestID reads X-Request-ID from the incoming request or generates one.
-// Stores in context and sets it on the response header.
-func RequestID(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		id := r.Header.Get("X-Request-ID")
-		if id == "" {
-			id = generateID()
-		}
-		ctx := context.WithValue(r.Context(), requestIDKey, id)
-		w.Header().Set("X-Request-ID", id)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
-}
-
-// GetRequestID extracts the request ID from context.
-func GetRequestID(ctx context.Context) string {
-	if id, ok := ctx.Value(requestIDKey).(string); ok {
-		return id
-	}
-	return ""
-}
-
-func generateID() string {
-	b := make([]byte, 8)
-	_, _ = rand.Read(b)
-	return hex.EncodeToString(b)
-}
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+type ctxKey string
+
+const requestIDKey ctxKey = "request_id"
+
+// RequestID reads X-Request-ID from the incoming request or generates one.
+// Stores in context and sets it on the response header.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" {
+			id = generateID()
+		}
+		ctx := context.WithValue(r.Context(), requestIDKey, id)
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetRequestID extracts the request ID from context.
+func GetRequestID(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// WithRequestID stores id in ctx. Para requests que no pasan por el middleware
+// (ej. cada mensaje de una conexion WebSocket).
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// NewRequestID returns a random request ID (16 hex).
+func NewRequestID() string {
+	return generateID()
+}
+
+func generateID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
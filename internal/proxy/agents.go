@@ -1,234 +1,506 @@
-package proxy
-
-import (
-	"bytes"
-	"context"
-	"encoding/json"
-	"errors"
-	"fmt"
-	"io"
-	"log/slog"
-	"net"
-	"net/http"
-	"strings"
-	"time"
-
-	"gateway/internal/agent"
-	"gateway/internal/domain"
-	"gateway/internal/middleware"
-
-	"github.com/sony/gobreaker/v2"
-)
-
-// maxConcurrentPerAgent matches MaxConnsPerHost in the Transport.
-const maxConcurrentPerAgent = 25
-
-// AgentRequest is the body sent to the agent HTTP endpoint.
-type AgentRequest struct {
-	Message   string                 `json:"message"`
-	SessionID int                    `json:"session_id"`
-	IdEmpresa int                    `json:"id_empresa"`
-	ApiKey    string                 `json:"api_key"`
-	Config    map[string]interface{} `json:"config"`
-}
-
-// AgentResponse is the expected response from the agent.
-type AgentResponse struct {
-	Reply string  `json:"reply"`
-	URL   *string `json:"url"`
-}
-
-// agentResult holds reply and optional url from the agent for circuit breaker.
-type agentResult struct {
-	Reply string
-	URL   *string
-}
-
-// Invoker calls agent HTTP endpoints with circuit breaker and backpressure.
-type Invoker struct {
-	registry *agent.Registry
-	client   *http.Client
-	cbs      map[string]*gobreaker.CircuitBreaker[agentResult]
-	sems     map[string]chan struct{} // M1: backpressure per agent
-}
-
-// NewInvoker creates an invoker with shared HTTP client and per-agent circuit breakers.
-func NewInvoker(agentTimeout time.Duration, registry *agent.Registry) *Invoker {
-	client := &http.Client{
-		Timeout: agentTimeout,
-		Transport: &http.Transport{
-			DialContext: (&net.Dialer{
-				Timeout:   5 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).DialContext,
-			MaxConnsPerHost:       25,
-			MaxIdleConnsPerHost:   10,
-			MaxIdleConns:          50,
-			IdleConnTimeout:       90 * time.Second,
-			TLSHandshakeTimeout:   5 * time.Second,
-			ResponseHeaderTimeout: 20 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
-			ForceAttemptHTTP2:     false,
-			DisableKeepAlives:     false,
-		},
-	}
-
-	agents := registry.Keys()
-	cbs := make(map[string]*gobreaker.CircuitBreaker[agentResult], len(agents))
-	sems := make(map[string]chan struct{}, len(agents))
-	for _, name := range agents {
-		sems[name] = make(chan struct{}, maxConcurrentPerAgent)
-		cbs[name] = gobreaker.NewCircuitBreaker[agentResult](gobreaker.Settings{
-			Name:        name,
-			MaxRequests: 3,
-			Interval:    60 * time.Second,
-			Timeout:     30 * time.Second,
-			ReadyToTrip: func(counts gobreaker.Counts) bool {
-				return counts.ConsecutiveFailures >= 3
-			},
-			OnStateChange: func(name string, from, to gobreaker.State) {
-				slog.Warn("circuit_breaker", "agent", name, "from", from.String(), "to", to.String())
-			},
-		})
-	}
-	return &Invoker{registry: registry, client: client, cbs: cbs, sems: sems}
-}
-
-// InvokeAgent calls the agent by name with the given payload. Returns reply, optional url, or error.
-func (inv *Invoker) InvokeAgent(ctx context.Context, agent string, message string, sessionID int, idEmpresa int, apiKey string, configMap map[string]interface{}) (reply string, url *string, err error) {
-	if !inv.registry.Enabled(agent) {
-		return "", nil, fmt.Errorf("agent %s is disabled", agent)
-	}
-	agentURL := inv.registry.URL(agent)
-	if agentURL == "" {
-		return "", nil, fmt.Errorf("no URL configured for agent %s", agent)
-	}
-
-	cb, ok := inv.cbs[agent]
-	if !ok {
-		return "", nil, fmt.Errorf("unknown agent: %s", agent)
-	}
-
-	// M1: backpressure — non-blocking semaphore per agent.
-	sem := inv.sems[agent]
-	select {
-	case sem <- struct{}{}:
-		defer func() { <-sem }()
-	default:
-		return "", nil, fmt.Errorf("agent %s: backpressure (%d concurrent)", agent, maxConcurrentPerAgent)
-	}
-
-	// M3: retry inside CB so it sees the final result (1 failure, not 2).
-	res, err := cb.Execute(func() (agentResult, error) {
-		result, err := inv.doHTTP(ctx, agentURL, message, sessionID, idEmpresa, apiKey, configMap)
-		if err != nil && isRetryable(err) {
-			select {
-			case <-ctx.Done():
-				return agentResult{}, ctx.Err()
-			case <-time.After(500 * time.Millisecond):
-			}
-			slog.Debug("retry agente", "url", agentURL, "err", err)
-			return inv.doHTTP(ctx, agentURL, message, sessionID, idEmpresa, apiKey, configMap)
-		}
-		return result, err
-	})
-	if err != nil {
-		return "", nil, err
-	}
-
-	// Defensa en profundidad: el agente deberia siempre retornar reply,
-	// pero si viene vacio lo detectamos aqui. Fuera de cb.Execute para
-	// que el CB no lo cuente como fallo (el agente esta vivo, solo no genero texto).
-	if strings.TrimSpace(res.Reply) == "" {
-		slog.Warn("agent returned empty reply", "agent", agent, "url", agentURL)
-		return "", res.URL, domain.ErrEmptyReply
-	}
-
-	return res.Reply, res.URL, nil
-}
-
-func (inv *Invoker) doHTTP(ctx context.Context, agentURL string, message string, sessionID int, idEmpresa int, apiKey string, configMap map[string]interface{}) (agentResult, error) {
-	body := AgentRequest{
-		Message:   message,
-		SessionID: sessionID,
-		IdEmpresa: idEmpresa,
-		ApiKey:    apiKey,
-		Config:    configMap,
-	}
-	raw, err := json.Marshal(body)
-	if err != nil {
-		return agentResult{}, fmt.Errorf("marshal request: %w", err)
-	}
-
-	slog.Debug("→ enviando a agente",
-		"url", agentURL,
-		"session_id", sessionID,
-		"message_preview", domain.Preview(message, domain.DefaultPreviewLen),
-		"config_keys", contextKeys(configMap),
-	)
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, agentURL, bytes.NewReader(raw))
-	if err != nil {
-		return agentResult{}, fmt.Errorf("new request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-	if rid := middleware.GetRequestID(ctx); rid != "" {
-		req.Header.Set("X-Request-ID", rid)
-	}
-
-	start := time.Now()
-	resp, err := inv.client.Do(req)
-	if err != nil {
-		slog.Warn("← agente no respondio", "url", agentURL, "session_id", sessionID, "err", err, "duration_ms", time.Since(start).Milliseconds())
-		return agentResult{}, fmt.Errorf("http do: %w", err)
-	}
-	defer func() {
-		io.Copy(io.Discard, resp.Body)
-		resp.Body.Close()
-	}()
-
-	if resp.StatusCode != http.StatusOK {
-		slog.Warn("← agente respondio con error", "url", agentURL, "session_id", sessionID, "status", resp.StatusCode, "duration_ms", time.Since(start).Milliseconds())
-		return agentResult{}, fmt.Errorf("agent returned status %d", resp.StatusCode)
-	}
-
-	var out AgentResponse
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-		return agentResult{}, fmt.Errorf("decode response: %w", err)
-	}
-
-	u := out.URL
-	if u != nil && *u == "" {
-		u = nil
-	}
-
-	slog.Debug("← respuesta agente",
-		"url", agentURL,
-		"session_id", sessionID,
-		"duration_ms", time.Since(start).Milliseconds(),
-		"reply_preview", domain.Preview(out.Reply, domain.DefaultPreviewLen),
-	)
-
-	return agentResult{Reply: out.Reply, URL: u}, nil
-}
-
-// isRetryable returns true for transient connection errors worth retrying.
-// Does NOT retry timeouts (if 5s dial failed, 5.5s won't help) or context cancellation.
-func isRetryable(err error) bool {
-	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
-		return false
-	}
-	msg := err.Error()
-	return strings.Contains(msg, "connection refused") ||
-		strings.Contains(msg, "connection reset")
-}
-
-// contextKeys devuelve las claves del mapa de contexto (util para logs de debug sin exponer valores).
-func contextKeys(m map[string]interface{}) []string {
-	keys := make([]string, 0, len(m))
-	for k := range m {
-		keys = append(keys, k)
-	}
-	return keys
-}
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"gateway/internal/agent"
+	"gateway/internal/domain"
+	"gateway/internal/middleware"
+	"gateway/internal/svctoken"
+	"gateway/internal/tlsutil"
+
+	"github.com/sony/gobreaker/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxConcurrentPerAgent matches MaxConnsPerHost in the Transport.
+const maxConcurrentPerAgent = 25
+
+// semaphoreMaxWait is how long a call waits for a free slot before failing with backpressure:
+// absorbe picos cortos sin encolar requests indefinidamente.
+const semaphoreMaxWait = 100 * time.Millisecond
+
+// defaultResponseHeaderTimeout: espera maxima de headers del agente en llamadas normales.
+const defaultResponseHeaderTimeout = 20 * time.Second
+
+// breakerMaxRequests: requests permitidos en half-open; otros tantos exitos consecutivos cierran el breaker.
+const breakerMaxRequests = 3
+
+// AgentRequest is the body sent to the agent HTTP endpoint.
+type AgentRequest struct {
+	Message   string                 `json:"message"`
+	SessionID int                    `json:"session_id"`
+	IdEmpresa int                    `json:"id_empresa"`
+	ApiKey    string                 `json:"api_key,omitempty"` // omitido si AGENT_<KEY>_FORWARD_API_KEY=false
+	Config    map[string]interface{} `json:"config"`
+}
+
+// AgentResponse is the expected response from the agent. Acepta el formato enriquecido
+// ({"messages": [...]}) y el original ({"reply", "url"}); con messages, reply y url se ignoran.
+type AgentResponse struct {
+	Messages []domain.Message `json:"messages,omitempty"`
+	Reply    string           `json:"reply,omitempty"`
+	URL      *string          `json:"url,omitempty"`
+}
+
+// Normalize returns the reply as messages. Los mensajes invalidos (tipo desconocido, campos
+// faltantes) se descartan y se cuentan en dropped.
+func (r AgentResponse) Normalize() (msgs []domain.Message, dropped int) {
+	if len(r.Messages) == 0 {
+		return domain.LegacyMessages(r.Reply, r.URL), 0
+	}
+	for _, m := range r.Messages {
+		if !m.Valid() {
+			dropped++
+			continue
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, dropped
+}
+
+// agentResult holds the normalized reply from the agent for circuit breaker.
+type agentResult struct {
+	Messages []domain.Message
+}
+
+// TokenSigner signs the service token attached to every agent call.
+type TokenSigner interface {
+	Sign(c svctoken.Claims) (string, error)
+}
+
+// Metrics receives resilience signals from the invoker (implemented by metrics.Recorder).
+type Metrics interface {
+	AddInFlight(agent string, delta int)
+	SetBreakerState(agent string, state int)
+	IncRetry(agent string)
+	ObserveSemaphoreWait(agent string, d time.Duration)
+}
+
+// WithMetrics wires in-flight, breaker-state, retry and semaphore-wait metrics.
+func WithMetrics(m Metrics) Option {
+	return func(inv *Invoker) { inv.metrics = m }
+}
+
+// Option configures optional Invoker behaviour.
+type Option func(*Invoker)
+
+// WithHealthProbes lets health probe results drive the circuit breakers (see ObserveProbe).
+// tripAfter = probes fallidas consecutivas que abren el breaker; <= 0 no hace nada.
+func WithHealthProbes(tripAfter int) Option {
+	return func(inv *Invoker) {
+		if tripAfter > 0 {
+			inv.probeTripAfter = tripAfter
+			inv.probes = make(map[string]*probeState)
+		}
+	}
+}
+
+// WithLongCalls enables calls longer than agentTimeout (jobs async): si el deadline del ctx supera
+// agentTimeout se usa un cliente aparte con Timeout y ResponseHeaderTimeout = max. El semaforo y el
+// breaker del agente son los mismos; max <= agentTimeout no hace nada.
+func WithLongCalls(max time.Duration) Option {
+	return func(inv *Invoker) { inv.longTimeout = max }
+}
+
+// WithTLSReload sets how often the agents' client certificates are checked for changes
+// (GATEWAY_TLS_RELOAD_SEC). Sin esta opcion se usa tlsutil.DefaultReloadInterval.
+func WithTLSReload(d time.Duration) Option {
+	return func(inv *Invoker) { inv.tlsReload = d }
+}
+
+// WithTokenSigner attaches "Authorization: Bearer <jwt>" to every agent request.
+func WithTokenSigner(s TokenSigner) Option {
+	return func(inv *Invoker) { inv.signer = s }
+}
+
+// Invoker calls agent HTTP endpoints with circuit breaker and backpressure.
+type Invoker struct {
+	registry *agent.Registry
+	client   *http.Client            // shared client for agents without TLS options
+	clients  map[string]*http.Client // per-agent clients with their own TLS config (mTLS)
+	timeout  time.Duration           // agentTimeout
+	cbs      map[string]*gobreaker.CircuitBreaker[agentResult]
+	sems     map[string]chan struct{} // M1: backpressure per agent
+	signer   TokenSigner              // nil = sin token de servicio
+	metrics  Metrics                  // nil = sin metricas de resiliencia
+
+	tlsReload time.Duration // revision de certificados de cliente; 0 = tlsutil.DefaultReloadInterval
+
+	longTimeout time.Duration           // 0 = sin llamadas largas
+	longClients map[string]*http.Client // mismos clientes con timeouts = longTimeout (clave "" = compartido)
+
+	probes         map[string]*probeState // nil = breakers solo aprenden del trafico real
+	probeTripAfter int
+}
+
+// NewInvoker creates an invoker with shared HTTP client and per-agent circuit breakers.
+// Agents with TLS options (CA, client cert, server name) get a dedicated client.
+func NewInvoker(agentTimeout time.Duration, registry *agent.Registry, opts ...Option) (*Invoker, error) {
+	client := &http.Client{
+		Timeout:   agentTimeout,
+		Transport: newTransport(nil, defaultResponseHeaderTimeout),
+	}
+
+	agents := registry.Keys()
+	inv := &Invoker{
+		registry: registry,
+		client:   client,
+		clients:  make(map[string]*http.Client),
+		timeout:  agentTimeout,
+		cbs:      make(map[string]*gobreaker.CircuitBreaker[agentResult], len(agents)),
+		sems:     make(map[string]chan struct{}, len(agents)),
+	}
+	for _, opt := range opts {
+		opt(inv)
+	}
+	long := inv.longTimeout > agentTimeout
+	if long {
+		inv.longClients = map[string]*http.Client{"": {Timeout: inv.longTimeout, Transport: newTransport(nil, inv.longTimeout)}}
+	}
+
+	for _, name := range agents {
+		if a, _ := registry.Get(name); !a.TLS.IsZero() {
+			tlsCfg, err := tlsutil.ClientConfig(a.TLS, inv.tlsReload)
+			if err != nil {
+				return nil, fmt.Errorf("agent %s: %w", name, err)
+			}
+			inv.clients[name] = &http.Client{Timeout: agentTimeout, Transport: newTransport(tlsCfg, defaultResponseHeaderTimeout)}
+			if long {
+				inv.longClients[name] = &http.Client{Timeout: inv.longTimeout, Transport: newTransport(tlsCfg, inv.longTimeout)}
+			}
+		}
+		inv.sems[name] = make(chan struct{}, maxConcurrentPerAgent)
+		if inv.probes != nil {
+			inv.probes[name] = &probeState{}
+		}
+		inv.cbs[name] = gobreaker.NewCircuitBreaker[agentResult](gobreaker.Settings{
+			Name:        name,
+			MaxRequests: breakerMaxRequests,
+			Interval:    60 * time.Second,
+			Timeout:     30 * time.Second,
+			ReadyToTrip: func(counts gobreaker.Counts) bool {
+				return counts.ConsecutiveFailures >= 3
+			},
+			OnStateChange: func(name string, from, to gobreaker.State) {
+				slog.Warn("circuit_breaker", "agent", name, "from", from.String(), "to", to.String())
+				if to == gobreaker.StateHalfOpen {
+					inv.enterHalfOpen(name)
+				}
+				if inv.metrics != nil {
+					inv.metrics.SetBreakerState(name, breakerStateValue(to))
+				}
+			},
+		})
+		if inv.metrics != nil {
+			inv.metrics.SetBreakerState(name, breakerStateValue(gobreaker.StateClosed))
+		}
+	}
+	return inv, nil
+}
+
+// breakerStateValue maps gobreaker states to the gauge values (0 closed, 1 half-open, 2 open).
+func breakerStateValue(s gobreaker.State) int {
+	switch s {
+	case gobreaker.StateHalfOpen:
+		return 1
+	case gobreaker.StateOpen:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// newTransport returns the tuned Transport shared by all agent clients. tlsCfg nil = TLS por defecto.
+func newTransport(tlsCfg *tls.Config, responseHeaderTimeout time.Duration) *http.Transport {
+	return &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsCfg,
+		MaxConnsPerHost:       25,
+		MaxIdleConnsPerHost:   10,
+		MaxIdleConns:          50,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: responseHeaderTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+		ForceAttemptHTTP2:     false,
+		DisableKeepAlives:     false,
+	}
+}
+
+// clientFor returns the agent's dedicated client or the shared one (los de llamadas largas si el deadline de ctx lo pide).
+func (inv *Invoker) clientFor(ctx context.Context, agent string) *http.Client {
+	if inv.longClients != nil {
+		if dl, ok := ctx.Deadline(); ok && time.Until(dl) > inv.timeout {
+			if c, ok := inv.longClients[agent]; ok {
+				return c
+			}
+			return inv.longClients[""]
+		}
+	}
+	if c, ok := inv.clients[agent]; ok {
+		return c
+	}
+	return inv.client
+}
+
+// InvokeAgent calls the agent by name with the given payload. Returns the reply messages or error.
+func (inv *Invoker) InvokeAgent(ctx context.Context, agent string, message string, sessionID int, idEmpresa int, apiKey string, configMap map[string]interface{}) (msgs []domain.Message, err error) {
+	if !inv.registry.Enabled(agent) {
+		return nil, fmt.Errorf("agent %s: %w", agent, domain.ErrAgentDisabled)
+	}
+	info, _ := inv.registry.Get(agent)
+	agentURL := info.URL
+	if agentURL == "" {
+		return nil, fmt.Errorf("no URL configured for agent %s", agent)
+	}
+
+	cb, ok := inv.cbs[agent]
+	if !ok {
+		return nil, fmt.Errorf("unknown agent: %s", agent)
+	}
+
+	ctx, span := tracer.Start(ctx, "agent.invoke", trace.WithAttributes(
+		attribute.String("agent", agent),
+		attribute.Int("session_id", sessionID),
+		attribute.Int("id_empresa", idEmpresa),
+	))
+	defer func() { endSpan(span, err) }()
+
+	// M1: backpressure — semaforo por agente con espera acotada (semaphoreMaxWait).
+	sem := inv.sems[agent]
+	semCtx, semSpan := tracer.Start(ctx, "semaphore.wait", trace.WithAttributes(
+		attribute.Int("semaphore.in_use", len(sem)),
+		attribute.Int("semaphore.capacity", cap(sem)),
+	))
+	semStart := time.Now()
+	acquired := acquire(semCtx, sem, semaphoreMaxWait)
+	if inv.metrics != nil {
+		inv.metrics.ObserveSemaphoreWait(agent, time.Since(semStart))
+	}
+	if !acquired {
+		err = fmt.Errorf("agent %s: %w (%d concurrent)", agent, domain.ErrBackpressure, maxConcurrentPerAgent)
+		endSpan(semSpan, err)
+		return nil, err
+	}
+	semSpan.End()
+	if inv.metrics != nil {
+		inv.metrics.AddInFlight(agent, 1)
+		defer inv.metrics.AddInFlight(agent, -1)
+	}
+	defer func() { <-sem }()
+
+	// Con health probes, half-open no usa trafico real como prueba: se rechaza hasta que una probe
+	// sana posterior a la entrada en half-open lo cierra (aunque la ultima probe antes del trip fuera sana).
+	if cb.State() == gobreaker.StateHalfOpen && inv.awaitingProbe(agent) {
+		err = fmt.Errorf("agent %s: %w: waiting for healthy probe", agent, domain.ErrBreakerOpen)
+		return nil, err
+	}
+
+	// M3: retry inside CB so it sees the final result (1 failure, not 2).
+	cbCtx, cbSpan := tracer.Start(ctx, "circuit_breaker.execute", trace.WithAttributes(
+		attribute.String("circuit_breaker.state", cb.State().String()),
+	))
+	res, err := cb.Execute(func() (agentResult, error) {
+		result, err := inv.attempt(cbCtx, 1, info, message, sessionID, idEmpresa, apiKey, configMap)
+		if err != nil && isRetryable(err) {
+			select {
+			case <-cbCtx.Done():
+				return agentResult{}, cbCtx.Err()
+			case <-time.After(500 * time.Millisecond):
+			}
+			slog.DebugContext(cbCtx, "retry agente", "url", agentURL, "err", err)
+			if inv.metrics != nil {
+				inv.metrics.IncRetry(agent)
+			}
+			return inv.attempt(cbCtx, 2, info, message, sessionID, idEmpresa, apiKey, configMap)
+		}
+		return result, err
+	})
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		err = fmt.Errorf("agent %s: %w: %w", agent, domain.ErrBreakerOpen, err)
+	}
+	endSpan(cbSpan, err)
+	if err != nil {
+		return nil, err
+	}
+
+	// Defensa en profundidad: el agente deberia siempre retornar reply,
+	// pero si viene vacio lo detectamos aqui. Fuera de cb.Execute para
+	// que el CB no lo cuente como fallo (el agente esta vivo, solo no genero texto).
+	if len(res.Messages) == 0 {
+		slog.WarnContext(ctx, "agent returned empty reply", "agent", agent, "url", agentURL)
+		err = domain.ErrEmptyReply
+		return nil, err
+	}
+
+	return res.Messages, nil
+}
+
+// attempt runs one doHTTP inside its own span (attempt 2 = retry).
+func (inv *Invoker) attempt(ctx context.Context, n int, info agent.AgentInfo, message string, sessionID int, idEmpresa int, apiKey string, configMap map[string]interface{}) (agentResult, error) {
+	ctx, span := tracer.Start(ctx, "agent.attempt", trace.WithAttributes(
+		attribute.Int("attempt", n),
+		attribute.Bool("retry", n > 1),
+	))
+	res, err := inv.doHTTP(ctx, info, message, sessionID, idEmpresa, apiKey, configMap)
+	endSpan(span, err)
+	return res, err
+}
+
+func (inv *Invoker) doHTTP(ctx context.Context, info agent.AgentInfo, message string, sessionID int, idEmpresa int, apiKey string, configMap map[string]interface{}) (agentResult, error) {
+	agentURL := info.URL
+	if !info.ForwardAPIKey {
+		apiKey = ""
+	}
+	body := AgentRequest{
+		Message:   message,
+		SessionID: sessionID,
+		IdEmpresa: idEmpresa,
+		ApiKey:    apiKey,
+		Config:    configMap,
+	}
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return agentResult{}, fmt.Errorf("marshal request: %w", err)
+	}
+
+	slog.DebugContext(ctx, "→ enviando a agente",
+		"url", agentURL,
+		"session_id", sessionID,
+		"message_preview", domain.Preview(message, domain.DefaultPreviewLen),
+		"config_keys", contextKeys(configMap),
+	)
+
+	ctx, span := tracer.Start(ctx, "POST "+info.Key, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("http.request.method", http.MethodPost),
+		attribute.String("url.full", agentURL),
+	))
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, agentURL, bytes.NewReader(raw))
+	if err != nil {
+		return agentResult{}, fmt.Errorf("new request: %w", err)
+	}
+	// W3C trace context: el agente continua la traza (traceparent / tracestate).
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	rid := middleware.GetRequestID(ctx)
+	if rid != "" {
+		req.Header.Set("X-Request-ID", rid)
+	}
+	if inv.signer != nil {
+		token, err := inv.signer.Sign(svctoken.Claims{
+			IdEmpresa: idEmpresa,
+			SessionID: sessionID,
+			Agent:     info.Key,
+			RequestID: rid,
+		})
+		if err != nil {
+			return agentResult{}, fmt.Errorf("sign service token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	start := time.Now()
+	resp, err := inv.clientFor(ctx, info.Key).Do(req)
+	if err != nil {
+		slog.WarnContext(ctx, "← agente no respondio", "url", agentURL, "session_id", sessionID, "err", err, "duration_ms", time.Since(start).Milliseconds())
+		span.SetStatus(codes.Error, err.Error())
+		var netErr net.Error
+		if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+			return agentResult{}, fmt.Errorf("http do: %w: %w", domain.ErrAgentTimeout, err)
+		}
+		return agentResult{}, fmt.Errorf("http do: %w: %w", domain.ErrAgentUnreachable, err)
+	}
+	defer func() {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode != http.StatusOK {
+		span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d", resp.StatusCode))
+		slog.WarnContext(ctx, "← agente respondio con error", "url", agentURL, "session_id", sessionID, "status", resp.StatusCode, "duration_ms", time.Since(start).Milliseconds())
+		return agentResult{}, fmt.Errorf("%w: %d", domain.ErrAgentStatus, resp.StatusCode)
+	}
+
+	var out AgentResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return agentResult{}, fmt.Errorf("%w: %w", domain.ErrAgentDecode, err)
+	}
+
+	msgs, dropped := out.Normalize()
+	if dropped > 0 {
+		slog.WarnContext(ctx, "agent returned invalid messages", "url", agentURL, "session_id", sessionID, "dropped", dropped)
+	}
+	reply, _ := domain.Flatten(msgs)
+
+	slog.DebugContext(ctx, "← respuesta agente",
+		"url", agentURL,
+		"session_id", sessionID,
+		"duration_ms", time.Since(start).Milliseconds(),
+		"messages", len(msgs),
+		"reply_preview", domain.Preview(reply, domain.DefaultPreviewLen),
+	)
+
+	return agentResult{Messages: msgs}, nil
+}
+
+// isRetryable returns true for transient connection errors worth retrying.
+// Does NOT retry timeouts (if 5s dial failed, 5.5s won't help) or context cancellation.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "connection refused") ||
+		strings.Contains(msg, "connection reset")
+}
+
+// contextKeys devuelve las claves del mapa de contexto (util para logs de debug sin exponer valores).
+func contextKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// acquire takes a slot of sem, esperando como maximo wait (o hasta que ctx termine).
+func acquire(ctx context.Context, sem chan struct{}, wait time.Duration) bool {
+	select {
+	case sem <- struct{}{}:
+		return true
+	default:
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-t.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sony/gobreaker/v2"
+)
+
+// errProbeFailed es el fallo sintetico que se cuenta en el breaker cuando falla una health probe.
+var errProbeFailed = errors.New("health probe failed")
+
+// probeState holds the probe results for one agent.
+type probeState struct {
+	mu       sync.Mutex
+	failures int // probes fallidas consecutivas
+
+	// Atomicos: OnStateChange los toca desde dentro del breaker, que puede estar ejecutandose
+	// bajo mu (ObserveProbe), asi que no pueden usar mu.
+	probed    atomic.Bool // llego al menos una probe: el agente tiene health URL
+	recovered atomic.Bool // hubo una probe sana desde que el breaker entro en half-open
+}
+
+// ObserveProbe feeds a health probe result into the agent's circuit breaker
+// (implements health.ProbeObserver).
+//
+// Con tripAfter probes fallidas consecutivas el breaker se abre sin esperar a que
+// fallen mensajes de usuarios. En half-open el trafico real se rechaza hasta que una
+// probe sana confirma la recuperacion y cierra el breaker.
+// Una probe sana con el breaker cerrado no se cuenta: no debe ocultar fallos de /api/chat.
+func (inv *Invoker) ObserveProbe(agent string, ok bool) {
+	ps, found := inv.probes[agent]
+	if !found {
+		return
+	}
+	cb := inv.cbs[agent]
+
+	ps.probed.Store(true)
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ok {
+		ps.failures = 0
+		if cb.State() == gobreaker.StateHalfOpen {
+			ps.recovered.Store(true)
+			slog.Info("circuit_breaker probe recovery", "agent", agent)
+			for i := 0; i < breakerMaxRequests && cb.State() == gobreaker.StateHalfOpen; i++ {
+				_, _ = cb.Execute(func() (agentResult, error) { return agentResult{}, nil })
+			}
+		}
+		return
+	}
+
+	ps.failures++
+	if ps.failures < inv.probeTripAfter || cb.State() == gobreaker.StateOpen {
+		return
+	}
+	slog.Warn("circuit_breaker probe trip", "agent", agent, "consecutive_probe_failures", ps.failures)
+	// gobreaker no expone un "trip" manual: se registran fallos hasta que ReadyToTrip abre el breaker.
+	// El limite evita un loop infinito si trafico real exitoso resetea los contadores en paralelo.
+	for i := 0; i < 2*breakerMaxRequests && cb.State() != gobreaker.StateOpen; i++ {
+		_, _ = cb.Execute(func() (agentResult, error) { return agentResult{}, errProbeFailed })
+	}
+}
+
+// awaitingProbe reports whether half-open traffic must wait for a healthy probe: el agente se
+// sondea y ninguna probe sana llego desde que el breaker paso a half-open. Sin probes (o sin
+// health URL) el trafico real es la prueba, como en un breaker comun.
+func (inv *Invoker) awaitingProbe(agent string) bool {
+	ps, ok := inv.probes[agent]
+	if !ok {
+		return false
+	}
+	return ps.probed.Load() && !ps.recovered.Load()
+}
+
+// enterHalfOpen resets the recovery flag (llamado desde OnStateChange).
+func (inv *Invoker) enterHalfOpen(agent string) {
+	if ps, ok := inv.probes[agent]; ok {
+		ps.recovered.Store(false)
+	}
+}
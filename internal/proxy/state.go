@@ -0,0 +1,24 @@
+package proxy
+
+// AgentState is the current breaker state and semaphore occupancy of one agent.
+type AgentState struct {
+	Breaker             string `json:"breaker"` // closed, half-open, open
+	ConsecutiveFailures uint32 `json:"consecutive_failures"`
+	InFlight            int    `json:"in_flight"`
+	Capacity            int    `json:"capacity"`
+}
+
+// AgentStates returns the state of every agent known to the invoker.
+func (inv *Invoker) AgentStates() map[string]AgentState {
+	out := make(map[string]AgentState, len(inv.cbs))
+	for name, cb := range inv.cbs {
+		sem := inv.sems[name]
+		out[name] = AgentState{
+			Breaker:             cb.State().String(),
+			ConsecutiveFailures: cb.Counts().ConsecutiveFailures,
+			InFlight:            len(sem),
+			Capacity:            cap(sem),
+		}
+	}
+	return out
+}
@@ -0,0 +1,313 @@
+// Package stats calcula en proceso latencia (p50/p95/p99), tasa de error y throughput por agente y
+// por tenant en ventanas deslizantes de 1, 5 y 15 minutos, con memoria acotada.
+// Pensado para equipos sin Prometheus: GET /debug/stats.
+package stats
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	slotDuration = 10 * time.Second
+	numSlots     = 90 // 15 min de slots de 10s
+
+	// Histograma log-lineal: limite superior del bucket i = histBase * histFactor^i ms.
+	histBase    = 1.0
+	histFactor  = 1.5
+	histBuckets = 32 // hasta ~290 s; lo que exceda cae en el ultimo bucket
+
+	// Defaults del colector.
+	DefaultMaxTenants = 200
+	DefaultTopTenants = 10
+
+	// TenantOther agrupa los tenants que no entran en MaxTenants.
+	TenantOther = "other"
+)
+
+// Windows reportadas (nombre → duracion).
+var Windows = []struct {
+	Name     string
+	Duration time.Duration
+}{
+	{"1m", time.Minute},
+	{"5m", 5 * time.Minute},
+	{"15m", 15 * time.Minute},
+}
+
+var histBounds = func() [histBuckets]float64 {
+	var b [histBuckets]float64
+	for i := range b {
+		b[i] = histBase * math.Pow(histFactor, float64(i))
+	}
+	return b
+}()
+
+// slot acumula los requests de 10 segundos.
+type slot struct {
+	start     int64 // unix del inicio del slot; distinto al esperado = slot viejo
+	count     uint32
+	errors    uint32
+	sumMs     uint64
+	maxMs     uint32
+	hist      [histBuckets]uint32
+	fallbacks map[string]uint32 // motivo → cantidad (lazy)
+}
+
+// series is the ring of slots for one agent or tenant.
+type series struct {
+	slots [numSlots]slot
+	last  int64 // inicio del ultimo slot escrito
+}
+
+func slotStart(t time.Time) int64 {
+	return t.Unix() - t.Unix()%int64(slotDuration/time.Second)
+}
+
+func (s *series) slotAt(start int64) *slot {
+	idx := (start / int64(slotDuration/time.Second)) % numSlots
+	sl := &s.slots[idx]
+	if sl.start != start {
+		*sl = slot{start: start}
+	}
+	if start > s.last {
+		s.last = start
+	}
+	return sl
+}
+
+func (s *series) add(now time.Time, d time.Duration, isErr bool) {
+	sl := s.slotAt(slotStart(now))
+	ms := d.Milliseconds()
+	if ms < 0 {
+		ms = 0
+	}
+	sl.count++
+	if isErr {
+		sl.errors++
+	}
+	sl.sumMs += uint64(ms)
+	if uint32(ms) > sl.maxMs {
+		sl.maxMs = uint32(ms)
+	}
+	i := sort.SearchFloat64s(histBounds[:], float64(ms))
+	if i >= histBuckets {
+		i = histBuckets - 1
+	}
+	sl.hist[i]++
+}
+
+func (s *series) addFallback(now time.Time, reason string) {
+	sl := s.slotAt(slotStart(now))
+	if sl.fallbacks == nil {
+		sl.fallbacks = make(map[string]uint32)
+	}
+	sl.fallbacks[reason]++
+}
+
+// WindowStats summarizes one series over one window.
+type WindowStats struct {
+	Requests      uint64            `json:"requests"`
+	Errors        uint64            `json:"errors"`
+	ErrorRate     float64           `json:"error_rate"`
+	ThroughputRPS float64           `json:"throughput_rps"`
+	MeanMs        float64           `json:"mean_ms"`
+	P50Ms         float64           `json:"p50_ms"`
+	P95Ms         float64           `json:"p95_ms"`
+	P99Ms         float64           `json:"p99_ms"`
+	MaxMs         uint32            `json:"max_ms"`
+	Fallbacks     map[string]uint64 `json:"fallbacks,omitempty"`
+}
+
+// summarize agrega los slots dentro de window terminando en now.
+func (s *series) summarize(now time.Time, window time.Duration) WindowStats {
+	var ws WindowStats
+	var hist [histBuckets]uint64
+	var sum uint64
+	cur := slotStart(now)
+	n := int64(window / slotDuration)
+	from := cur - (n-1)*int64(slotDuration/time.Second)
+	for i := range s.slots {
+		sl := &s.slots[i]
+		if sl.start < from || sl.start > cur || sl.start == 0 {
+			continue
+		}
+		ws.Requests += uint64(sl.count)
+		ws.Errors += uint64(sl.errors)
+		sum += sl.sumMs
+		if sl.maxMs > ws.MaxMs {
+			ws.MaxMs = sl.maxMs
+		}
+		for j, c := range sl.hist {
+			hist[j] += uint64(c)
+		}
+		for reason, c := range sl.fallbacks {
+			if ws.Fallbacks == nil {
+				ws.Fallbacks = make(map[string]uint64)
+			}
+			ws.Fallbacks[reason] += uint64(c)
+		}
+	}
+	// Segundos efectivamente cubiertos: slots completos + lo transcurrido del actual.
+	elapsed := float64((n-1)*int64(slotDuration/time.Second)) + float64(now.Unix()-cur) + 1
+	ws.ThroughputRPS = round3(float64(ws.Requests) / elapsed)
+	if ws.Requests == 0 {
+		return ws
+	}
+	ws.ErrorRate = round3(float64(ws.Errors) / float64(ws.Requests))
+	ws.MeanMs = round3(float64(sum) / float64(ws.Requests))
+	ws.P50Ms = percentile(hist, ws.Requests, 0.50, ws.MaxMs)
+	ws.P95Ms = percentile(hist, ws.Requests, 0.95, ws.MaxMs)
+	ws.P99Ms = percentile(hist, ws.Requests, 0.99, ws.MaxMs)
+	return ws
+}
+
+// percentile devuelve el limite superior del bucket que contiene el rank pedido, acotado al maximo observado.
+func percentile(hist [histBuckets]uint64, total uint64, p float64, maxMs uint32) float64 {
+	rank := uint64(math.Ceil(p * float64(total)))
+	var cum uint64
+	for i, c := range hist {
+		cum += c
+		if cum >= rank {
+			return round3(math.Min(histBounds[i], float64(maxMs)))
+		}
+	}
+	return float64(maxMs)
+}
+
+func round3(f float64) float64 { return math.Round(f*1000) / 1000 }
+
+// Options configures the collector. Campos en cero usan los defaults.
+type Options struct {
+	MaxTenants int // tenants con serie propia; el resto se agrupa en "other"
+	TopTenants int // tenants reportados por ventana (los de mas requests)
+}
+
+// Collector records chat requests (implementa handler.MetricsRecorder).
+type Collector struct {
+	opts Options
+	now  func() time.Time
+
+	mu      sync.Mutex
+	agents  map[string]*series
+	tenants map[string]*series
+}
+
+// NewCollector creates an empty collector.
+func NewCollector(opts Options) *Collector {
+	if opts.MaxTenants <= 0 {
+		opts.MaxTenants = DefaultMaxTenants
+	}
+	if opts.TopTenants <= 0 {
+		opts.TopTenants = DefaultTopTenants
+	}
+	return &Collector{
+		opts:    opts,
+		now:     time.Now,
+		agents:  make(map[string]*series),
+		tenants: make(map[string]*series),
+	}
+}
+
+// Record registers a completed request. status != "ok" y != "rejected" cuenta como error.
+func (c *Collector) Record(agent, status string, idEmpresa int, d time.Duration) {
+	now := c.now()
+	isErr := status != "ok" && status != "rejected"
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.agentSeries(agent).add(now, d, isErr)
+	c.tenantSeries(idEmpresa, now).add(now, d, isErr)
+}
+
+// RecordFallback counts a fallback reply by reason (se reporta por agente).
+func (c *Collector) RecordFallback(agent, reason string) {
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.agentSeries(agent).addFallback(now, reason)
+}
+
+func (c *Collector) agentSeries(agent string) *series {
+	s, ok := c.agents[agent]
+	if !ok {
+		s = &series{}
+		c.agents[agent] = s
+	}
+	return s
+}
+
+// tenantSeries devuelve la serie del tenant. Con el mapa lleno primero descarta tenants sin
+// actividad en 15 min; si sigue lleno, el tenant cae en "other".
+func (c *Collector) tenantSeries(idEmpresa int, now time.Time) *series {
+	key := TenantOther
+	if idEmpresa > 0 {
+		key = strconv.Itoa(idEmpresa)
+	}
+	if s, ok := c.tenants[key]; ok {
+		return s
+	}
+	if len(c.tenants) >= c.opts.MaxTenants {
+		stale := slotStart(now) - int64(numSlots)*int64(slotDuration/time.Second)
+		for k, s := range c.tenants {
+			if k != TenantOther && s.last <= stale {
+				delete(c.tenants, k)
+			}
+		}
+	}
+	if len(c.tenants) >= c.opts.MaxTenants {
+		key = TenantOther
+		if s, ok := c.tenants[key]; ok {
+			return s
+		}
+	}
+	s := &series{}
+	c.tenants[key] = s
+	return s
+}
+
+// TenantStats is one entry of the top tenants list.
+type TenantStats struct {
+	IdEmpresa string `json:"id_empresa"`
+	WindowStats
+}
+
+// WindowReport groups the stats of one window.
+type WindowReport struct {
+	Agents     map[string]WindowStats `json:"agents"`
+	TopTenants []TenantStats          `json:"top_tenants"`
+}
+
+// Snapshot computes every window (1m, 5m, 15m).
+func (c *Collector) Snapshot() map[string]WindowReport {
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]WindowReport, len(Windows))
+	for _, w := range Windows {
+		rep := WindowReport{Agents: make(map[string]WindowStats, len(c.agents))}
+		for name, s := range c.agents {
+			rep.Agents[name] = s.summarize(now, w.Duration)
+		}
+		for key, s := range c.tenants {
+			ws := s.summarize(now, w.Duration)
+			if ws.Requests > 0 {
+				rep.TopTenants = append(rep.TopTenants, TenantStats{IdEmpresa: key, WindowStats: ws})
+			}
+		}
+		sort.Slice(rep.TopTenants, func(i, j int) bool {
+			a, b := rep.TopTenants[i], rep.TopTenants[j]
+			if a.Requests != b.Requests {
+				return a.Requests > b.Requests
+			}
+			return a.IdEmpresa < b.IdEmpresa
+		})
+		if len(rep.TopTenants) > c.opts.TopTenants {
+			rep.TopTenants = rep.TopTenants[:c.opts.TopTenants]
+		}
+		out[w.Name] = rep
+	}
+	return out
+}
@@ -0,0 +1,221 @@
+// Package svctoken firma tokens de servicio (JWT compacto) que el gateway adjunta a cada llamada a un agente.
+// El agente valida el token para comprobar que la llamada viene del gateway y no de un tercero con la api_key.
+package svctoken
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Algoritmos soportados (valor del header "alg").
+const (
+	AlgHS256 = "HS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// DefaultIssuer is the "iss" claim when none is configured.
+const DefaultIssuer = "maravia-gateway"
+
+// Claims are the gateway-specific claims carried by every service token.
+type Claims struct {
+	IdEmpresa int    `json:"id_empresa"`
+	SessionID int    `json:"session_id"`
+	Agent     string `json:"agent"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Key is one signing key identified by kid.
+type Key struct {
+	ID      string
+	Alg     string
+	secret  []byte             // HS256
+	private ed25519.PrivateKey // EdDSA
+}
+
+// Signer signs service tokens with the active key (the first one).
+// Las claves restantes solo se publican en el JWKS para que los agentes sigan validando
+// tokens emitidos antes de una rotacion.
+type Signer struct {
+	keys   []Key
+	issuer string
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// NewSigner returns a signer. keys[0] is the active signing key; the rest are kept for rotation.
+func NewSigner(keys []Key, issuer string, ttl time.Duration) (*Signer, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("svctoken: no keys")
+	}
+	if ttl <= 0 {
+		return nil, errors.New("svctoken: ttl must be > 0")
+	}
+	if issuer == "" {
+		issuer = DefaultIssuer
+	}
+	return &Signer{keys: keys, issuer: issuer, ttl: ttl, now: time.Now}, nil
+}
+
+// ActiveKeyID returns the kid used to sign new tokens.
+func (s *Signer) ActiveKeyID() string {
+	return s.keys[0].ID
+}
+
+// Sign returns a compact JWT for the given claims, valid for the signer TTL.
+func (s *Signer) Sign(c Claims) (string, error) {
+	k := s.keys[0]
+	now := s.now()
+
+	header, err := json.Marshal(map[string]string{"alg": k.Alg, "typ": "JWT", "kid": k.ID})
+	if err != nil {
+		return "", fmt.Errorf("svctoken: marshal header: %w", err)
+	}
+	payload, err := json.Marshal(struct {
+		Issuer   string `json:"iss"`
+		Audience string `json:"aud"`
+		IssuedAt int64  `json:"iat"`
+		Expires  int64  `json:"exp"`
+		Claims
+	}{
+		Issuer:   s.issuer,
+		Audience: c.Agent,
+		IssuedAt: now.Unix(),
+		Expires:  now.Add(s.ttl).Unix(),
+		Claims:   c,
+	})
+	if err != nil {
+		return "", fmt.Errorf("svctoken: marshal claims: %w", err)
+	}
+
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+
+	var sig []byte
+	switch k.Alg {
+	case AlgHS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write([]byte(signingInput))
+		sig = mac.Sum(nil)
+	case AlgEdDSA:
+		sig = ed25519.Sign(k.private, []byte(signingInput))
+	default:
+		return "", fmt.Errorf("svctoken: unsupported alg %q", k.Alg)
+	}
+	return signingInput + "." + enc.EncodeToString(sig), nil
+}
+
+// JWK is a public key in JSON Web Key format (RFC 8037 for Ed25519).
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of every Ed25519 key. Las claves HS256 son simetricas y nunca se publican.
+func (s *Signer) JWKS() JWKS {
+	out := JWKS{Keys: []JWK{}}
+	for _, k := range s.keys {
+		if k.Alg != AlgEdDSA {
+			continue
+		}
+		pub := k.private.Public().(ed25519.PublicKey)
+		out.Keys = append(out.Keys, JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+			Kid: k.ID,
+			Alg: AlgEdDSA,
+			Use: "sig",
+		})
+	}
+	return out
+}
+
+// LoadKeys parses "kid=path,kid=path" (first = active) and reads each key file.
+// alg "hs256": el archivo contiene el secreto (min. 32 bytes). alg "ed25519": clave privada PKCS#8 en PEM.
+func LoadKeys(alg, spec string) ([]Key, error) {
+	var jwtAlg string
+	switch strings.ToLower(strings.TrimSpace(alg)) {
+	case "hs256":
+		jwtAlg = AlgHS256
+	case "ed25519", "eddsa":
+		jwtAlg = AlgEdDSA
+	default:
+		return nil, fmt.Errorf("svctoken: unknown alg %q (use hs256 or ed25519)", alg)
+	}
+
+	var keys []Key
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kid, path, ok := strings.Cut(item, "=")
+		kid, path = strings.TrimSpace(kid), strings.TrimSpace(path)
+		if !ok || kid == "" || path == "" {
+			return nil, fmt.Errorf("svctoken: invalid key entry %q (expected kid=path)", item)
+		}
+		if seen[kid] {
+			return nil, fmt.Errorf("svctoken: duplicate kid %q", kid)
+		}
+		seen[kid] = true
+
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("svctoken: read key %s: %w", kid, err)
+		}
+		k := Key{ID: kid, Alg: jwtAlg}
+		if jwtAlg == AlgHS256 {
+			k.secret = []byte(strings.TrimSpace(string(raw)))
+			if len(k.secret) < 32 {
+				return nil, fmt.Errorf("svctoken: key %s: hs256 secret must be at least 32 bytes", kid)
+			}
+		} else {
+			k.private, err = parseEd25519(raw)
+			if err != nil {
+				return nil, fmt.Errorf("svctoken: key %s: %w", kid, err)
+			}
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("svctoken: no keys configured")
+	}
+	return keys, nil
+}
+
+// parseEd25519 lee una clave privada Ed25519 en PEM PKCS#8 ("PRIVATE KEY", formato de openssl genpkey).
+func parseEd25519(raw []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse pkcs8: %w", err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("key is %T, not ed25519", key)
+	}
+	return priv, nil
+}
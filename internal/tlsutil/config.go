@@ -0,0 +1,66 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ServerConfig returns a tls.Config for the gateway listener backed by a CertReloader.
+func ServerConfig(certFile, keyFile string, reload time.Duration) (*tls.Config, error) {
+	r, err := NewCertReloader(certFile, keyFile, reload)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}, nil
+}
+
+// ClientOptions describes the TLS settings used to reach one agent.
+// Todos los campos son opcionales: sin CAFile se usan las CAs del sistema,
+// sin CertFile/KeyFile no se presenta certificado de cliente (TLS simple, sin mTLS).
+type ClientOptions struct {
+	CAFile     string
+	CertFile   string
+	KeyFile    string
+	ServerName string
+}
+
+// IsZero reports whether no TLS option is set.
+func (o ClientOptions) IsZero() bool {
+	return o == ClientOptions{}
+}
+
+// ClientConfig builds a tls.Config for an agent. The client certificate (if any) reloads like the server one.
+func ClientConfig(o ClientOptions, reload time.Duration) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: o.ServerName,
+	}
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: read CA %s: %w", o.CAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls: no certificates found in CA %s", o.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return nil, fmt.Errorf("tls: client cert and key must be set together")
+	}
+	if o.CertFile != "" {
+		r, err := NewCertReloader(o.CertFile, o.KeyFile, reload)
+		if err != nil {
+			return nil, err
+		}
+		cfg.GetClientCertificate = r.GetClientCertificate
+	}
+	return cfg, nil
+}
@@ -0,0 +1,113 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultReloadInterval es cada cuanto se revisa si cambiaron los archivos de certificado.
+const DefaultReloadInterval = 30 * time.Second
+
+// CertReloader keeps a certificate/key pair in memory and reloads it when the files change on disk.
+// La revision es perezosa: se hace un stat como maximo una vez por intervalo, dentro del handshake,
+// sin goroutines en segundo plano. Si la recarga falla se sigue usando el certificado anterior.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+// NewCertReloader loads the pair once and returns a reloader. interval <= 0 uses DefaultReloadInterval.
+func NewCertReloader(certFile, keyFile string, interval time.Duration) (*CertReloader, error) {
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+	r := &CertReloader{certFile: certFile, keyFile: keyFile, interval: interval}
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(modTime); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate (lado servidor).
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.current(), nil
+}
+
+// GetClientCertificate implements tls.Config.GetClientCertificate (mTLS hacia agentes).
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.current(), nil
+}
+
+// current devuelve el certificado vigente, recargandolo si el intervalo vencio y los archivos cambiaron.
+func (r *CertReloader) current() *tls.Certificate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(r.lastCheck) < r.interval {
+		return r.cert
+	}
+	r.lastCheck = now
+
+	modTime, err := r.latestModTime()
+	if err != nil {
+		slog.Warn("tls reload: stat", "cert", r.certFile, "err", err)
+		return r.cert
+	}
+	// Equal y no After: una rotacion puede traer un mtime anterior (cp -p, swap del symlink de un secret de k8s).
+	if modTime.Equal(r.modTime) {
+		return r.cert
+	}
+	if err := r.loadLocked(modTime); err != nil {
+		slog.Warn("tls reload: keeping previous certificate", "cert", r.certFile, "err", err)
+		return r.cert
+	}
+	slog.Info("tls certificate reloaded", "cert", r.certFile)
+	return r.cert
+}
+
+func (r *CertReloader) load(modTime time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastCheck = time.Now()
+	return r.loadLocked(modTime)
+}
+
+func (r *CertReloader) loadLocked(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("tls: load key pair %s: %w", r.certFile, err)
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+// latestModTime devuelve el mtime mas reciente entre cert y key (se pueden rotar por separado).
+func (r *CertReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, f := range []string{r.certFile, r.keyFile} {
+		st, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("tls: %w", err)
+		}
+		if st.ModTime().After(latest) {
+			latest = st.ModTime()
+		}
+	}
+	return latest, nil
+}
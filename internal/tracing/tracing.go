@@ -0,0 +1,68 @@
+// Package tracing configura OpenTelemetry: TracerProvider con exportador OTLP/HTTP,
+// muestreo configurable y propagacion W3C (traceparent / tracestate / baggage).
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Options configures tracing. Con Enabled=false solo se instala el propagador W3C:
+// el traceparent entrante se sigue reenviando a los agentes, pero no se exportan spans.
+type Options struct {
+	Enabled     bool
+	Endpoint    string  // host:port del collector OTLP/HTTP (ej. "localhost:4318")
+	URLPath     string  // vacio = /v1/traces
+	Insecure    bool    // http en lugar de https hacia el collector
+	SampleRatio float64 // 0..1, aplicado a trazas raiz (ParentBased respeta la decision del padre)
+	ServiceName string
+	Version     string
+}
+
+// Setup installs the global propagator and, if enabled, a TracerProvider exporting over OTLP/HTTP.
+// The returned shutdown flushes pending spans; call it during graceful shutdown.
+func Setup(ctx context.Context, o Options) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	if !o.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(o.Endpoint)}
+	if o.URLPath != "" {
+		opts = append(opts, otlptracehttp.WithURLPath(o.URLPath))
+	}
+	if o.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exp, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: otlp exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", o.ServiceName),
+		attribute.String("service.version", o.Version),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("tracing: resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp, sdktrace.WithBatchTimeout(5*time.Second)),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(o.SampleRatio))),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
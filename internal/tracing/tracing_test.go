@@ -0,0 +1,112 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeCollector is a local stand-in for an OTLP/HTTP collector: guarda cada export recibido en /v1/traces.
+type fakeCollector struct {
+	mu      sync.Mutex
+	exports []*coltracepb.ExportTraceServiceRequest
+}
+
+func (c *fakeCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req coltracepb.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	c.exports = append(c.exports, &req)
+	c.mu.Unlock()
+	raw, _ := proto.Marshal(&coltracepb.ExportTraceServiceResponse{})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(raw)
+}
+
+func TestSetupExportsSpansOnShutdown(t *testing.T) {
+	collector := &fakeCollector{}
+	srv := httptest.NewServer(collector)
+	defer srv.Close()
+
+	ctx := context.Background()
+	shutdown, err := Setup(ctx, Options{
+		Enabled:     true,
+		Endpoint:    strings.TrimPrefix(srv.URL, "http://"),
+		Insecure:    true,
+		SampleRatio: 1,
+		ServiceName: "gateway-test",
+		Version:     "v1.2.3",
+	})
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	_, span := otel.Tracer("test").Start(ctx, "chat.route")
+	span.End()
+	if err := shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if len(collector.exports) == 0 {
+		t.Fatal("collector received no export")
+	}
+	var names []string
+	attrs := map[string]string{}
+	for _, exp := range collector.exports {
+		for _, rs := range exp.GetResourceSpans() {
+			for _, kv := range rs.GetResource().GetAttributes() {
+				attrs[kv.GetKey()] = kv.GetValue().GetStringValue()
+			}
+			for _, ss := range rs.GetScopeSpans() {
+				for _, s := range ss.GetSpans() {
+					names = append(names, s.GetName())
+				}
+			}
+		}
+	}
+	if len(names) != 1 || names[0] != "chat.route" {
+		t.Errorf("spans = %v, want [chat.route]", names)
+	}
+	if attrs["service.name"] != "gateway-test" || attrs["service.version"] != "v1.2.3" {
+		t.Errorf("resource service.name=%q service.version=%q", attrs["service.name"], attrs["service.version"])
+	}
+}
+
+func TestSetupDisabledExportsNothing(t *testing.T) {
+	collector := &fakeCollector{}
+	srv := httptest.NewServer(collector)
+	defer srv.Close()
+
+	ctx := context.Background()
+	shutdown, err := Setup(ctx, Options{Enabled: false, Endpoint: strings.TrimPrefix(srv.URL, "http://"), Insecure: true})
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if err := shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if len(collector.exports) != 0 {
+		t.Errorf("exports = %d, want 0", len(collector.exports))
+	}
+}
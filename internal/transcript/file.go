@@ -0,0 +1,200 @@
+package transcript
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults del sink de archivos.
+const (
+	DefaultMaxSize = 100 << 20 // 100 MB
+	DefaultMaxAge  = 24 * time.Hour
+	DefaultBuffer  = 1000
+
+	filePrefix = "transcript-"
+	fileSuffix = ".jsonl"
+)
+
+// FileOptions configures the rotated JSONL file sink. Campos en cero usan los defaults.
+type FileOptions struct {
+	Dir       string
+	MaxSize   int64         // rota al superar este tamano (bytes)
+	MaxAge    time.Duration // rota cuando el archivo actual tiene mas de esta antiguedad
+	Retention time.Duration // borra archivos rotados mas viejos; 0 = no borra nunca
+	Buffer    int           // records en cola; si se llena se descartan (el request no espera al disco)
+	Redact    Redactor
+}
+
+// FileSink writes one JSON record per line to rotated files in Dir.
+type FileSink struct {
+	opts FileOptions
+	ch   chan Record
+	done chan struct{}
+
+	// mu protege closed: Write envia con RLock, Close cierra ch con Lock. Un request que termina
+	// despues del shutdown (ctx vencido en Shutdown) no puede enviar en un canal cerrado.
+	mu      sync.RWMutex
+	closed  bool
+	dropped atomic.Int64
+
+	fileOnce sync.Once // cierre del archivo: Close concurrentes no compiten por s.file
+	closeErr error
+
+	// Solo los usa la goroutine de escritura.
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+// NewFileSink creates Dir if needed and starts the writer goroutine.
+func NewFileSink(opts FileOptions) (*FileSink, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("transcript: dir is required")
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = DefaultMaxAge
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = DefaultBuffer
+	}
+	if err := os.MkdirAll(opts.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("transcript: %w", err)
+	}
+	s := &FileSink{
+		opts: opts,
+		ch:   make(chan Record, opts.Buffer),
+		done: make(chan struct{}),
+	}
+	if err := s.rotate(time.Now()); err != nil {
+		return nil, err
+	}
+	go s.loop()
+	return s, nil
+}
+
+// Write enqueues a record. Si la cola esta llena o el sink ya se cerro, el record se descarta
+// (se reporta en el log y cuenta en Dropped).
+func (s *FileSink) Write(r Record) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		s.dropped.Add(1)
+		slog.Warn("transcript sink closed, record dropped", "request_id", r.RequestID, "agent", r.Agent)
+		return
+	}
+	select {
+	case s.ch <- r:
+	default:
+		s.dropped.Add(1)
+		slog.Warn("transcript queue full, record dropped", "request_id", r.RequestID, "agent", r.Agent)
+	}
+}
+
+// Dropped returns how many records were discarded (cola llena o escritos tras Close).
+func (s *FileSink) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Close flushes queued records and closes the current file. Es seguro llamarlo mas de una vez.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+	s.mu.Unlock()
+	<-s.done
+	s.fileOnce.Do(func() {
+		if s.file != nil {
+			s.closeErr = s.file.Close()
+			s.file = nil
+		}
+	})
+	return s.closeErr
+}
+
+func (s *FileSink) loop() {
+	defer close(s.done)
+	for r := range s.ch {
+		if err := s.write(r); err != nil {
+			slog.Error("transcript write", "request_id", r.RequestID, "err", err)
+		}
+	}
+}
+
+func (s *FileSink) write(r Record) error {
+	line, err := json.Marshal(r.Redact(s.opts.Redact))
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	now := time.Now()
+	if s.file == nil || s.size+int64(len(line)) > s.opts.MaxSize || now.Sub(s.opened) >= s.opts.MaxAge {
+		if err := s.rotate(now); err != nil {
+			return err
+		}
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+// rotate cierra el archivo actual, abre uno nuevo y aplica la retencion.
+func (s *FileSink) rotate(now time.Time) error {
+	if s.file != nil {
+		if err := s.file.Close(); err != nil {
+			slog.Warn("transcript close", "file", s.file.Name(), "err", err)
+		}
+		s.file = nil
+	}
+	// Dos rotaciones en el mismo milisegundo no comparten archivo: el segundo lleva sufijo -1, -2...
+	stamp := filePrefix + now.UTC().Format("20060102T150405.000")
+	name := filepath.Join(s.opts.Dir, stamp+fileSuffix)
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o640)
+	for i := 1; os.IsExist(err); i++ {
+		name = filepath.Join(s.opts.Dir, fmt.Sprintf("%s-%d%s", stamp, i, fileSuffix))
+		f, err = os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o640)
+	}
+	if err != nil {
+		return fmt.Errorf("transcript: %w", err)
+	}
+	s.file, s.size, s.opened = f, 0, now
+	s.cleanup(now)
+	return nil
+}
+
+// cleanup borra los archivos de transcript con mas antiguedad que Retention.
+func (s *FileSink) cleanup(now time.Time) {
+	if s.opts.Retention <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(s.opts.Dir)
+	if err != nil {
+		slog.Warn("transcript cleanup", "err", err)
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), filePrefix) || !strings.HasSuffix(e.Name(), fileSuffix) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || now.Sub(info.ModTime()) < s.opts.Retention {
+			continue
+		}
+		path := filepath.Join(s.opts.Dir, e.Name())
+		if err := os.Remove(path); err != nil {
+			slog.Warn("transcript cleanup", "file", path, "err", err)
+		}
+	}
+}
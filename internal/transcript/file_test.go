@@ -0,0 +1,261 @@
+package transcript
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gateway/internal/domain"
+)
+
+// readRecords cuenta las lineas de todos los archivos de transcript en dir.
+func readRecords(t *testing.T, dir string) int {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, filePrefix+"*"+fileSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			n++
+		}
+		f.Close()
+	}
+	return n
+}
+
+// transcriptFiles lista los archivos de transcript en dir (orden por nombre = orden de rotacion).
+func transcriptFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, filePrefix+"*"+fileSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestFileSinkWriteAfterCloseIsDropped(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileSink(FileOptions{Dir: dir})
+	if err != nil {
+		t.Fatalf("NewFileSink: %v", err)
+	}
+	s.Write(Record{RequestID: "before", Agent: "cita"})
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Un ChatHandler.record tardio (Shutdown vencido) no debe entrar en panic.
+	s.Write(Record{RequestID: "after", Agent: "cita"})
+	if err := s.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+
+	if got := s.Dropped(); got != 1 {
+		t.Errorf("Dropped() = %d, want 1", got)
+	}
+	if got := readRecords(t, dir); got != 1 {
+		t.Errorf("records on disk = %d, want 1", got)
+	}
+}
+
+func TestFileSinkConcurrentWriteAndClose(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileSink(FileOptions{Dir: dir, Buffer: 8})
+	if err != nil {
+		t.Fatalf("NewFileSink: %v", err)
+	}
+
+	const writers, perWriter = 8, 50
+	var wg sync.WaitGroup
+	for range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range perWriter {
+				s.Write(Record{Agent: "cita"})
+			}
+		}()
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	wg.Wait()
+
+	written := readRecords(t, dir)
+	if total := int64(written) + s.Dropped(); total != writers*perWriter {
+		t.Errorf("written %d + dropped %d = %d, want %d", written, s.Dropped(), total, writers*perWriter)
+	}
+}
+
+func TestFileSinkConcurrentClose(t *testing.T) {
+	s, err := NewFileSink(FileOptions{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFileSink: %v", err)
+	}
+	s.Write(Record{Agent: "cita"})
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.Close(); err != nil {
+				t.Errorf("Close: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestFileSinkRotatesBySize(t *testing.T) {
+	dir := t.TempDir()
+	const maxSize = 512
+	s, err := NewFileSink(FileOptions{Dir: dir, MaxSize: maxSize})
+	if err != nil {
+		t.Fatalf("NewFileSink: %v", err)
+	}
+	const records = 20
+	for range records {
+		s.Write(Record{Agent: "cita", Message: strings.Repeat("x", 100)})
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	files := transcriptFiles(t, dir)
+	if len(files) < 2 {
+		t.Fatalf("files = %d, want several (MaxSize %d)", len(files), maxSize)
+	}
+	for _, name := range files {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > maxSize {
+			t.Errorf("%s: size %d > MaxSize %d", filepath.Base(name), info.Size(), maxSize)
+		}
+	}
+	if got := readRecords(t, dir); got != records {
+		t.Errorf("records on disk = %d, want %d", got, records)
+	}
+}
+
+func TestFileSinkRotatesByAge(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileSink(FileOptions{Dir: dir, MaxAge: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewFileSink: %v", err)
+	}
+	s.Write(Record{Agent: "cita", RequestID: "first"})
+	time.Sleep(200 * time.Millisecond) // vence MaxAge: el segundo record abre otro archivo
+	s.Write(Record{Agent: "cita", RequestID: "second"})
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	files := transcriptFiles(t, dir)
+	if len(files) != 2 {
+		t.Fatalf("files = %v, want 2 (one per MaxAge window)", files)
+	}
+	for i, want := range []string{"first", "second"} {
+		b, err := os.ReadFile(files[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), `"request_id":"`+want+`"`) {
+			t.Errorf("%s = %s, want record %s", filepath.Base(files[i]), b, want)
+		}
+	}
+}
+
+func TestFileSinkRetentionCleanup(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour)
+	write := func(name string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("{}\n"), 0o640); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	expired := write(filePrefix + "20000101T000000.000" + fileSuffix)
+	foreign := write("otro-archivo.jsonl") // no es un transcript: se respeta aunque sea viejo
+	recent := filepath.Join(dir, filePrefix+"20000102T000000.000"+fileSuffix)
+	if err := os.WriteFile(recent, []byte("{}\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewFileSink(FileOptions{Dir: dir, Retention: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("NewFileSink: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if _, err := os.Stat(expired); !os.IsNotExist(err) {
+		t.Errorf("%s older than Retention still exists (err %v)", filepath.Base(expired), err)
+	}
+	for _, path := range []string{foreign, recent} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s was removed: %v", filepath.Base(path), err)
+		}
+	}
+}
+
+func TestFileSinkRedacts(t *testing.T) {
+	dir := t.TempDir()
+	redact := func(text string) string { return strings.ReplaceAll(text, "secreto", "[REDACTED]") }
+	s, err := NewFileSink(FileOptions{Dir: dir, Redact: redact})
+	if err != nil {
+		t.Fatalf("NewFileSink: %v", err)
+	}
+	url := "https://x.pe/secreto.pdf"
+	s.Write(Record{
+		Agent:   "cita",
+		Message: "mi clave es secreto",
+		Reply:   "recibi secreto",
+		URL:     &url,
+		Messages: []domain.Message{
+			{Type: domain.MessageImage, Text: "foto secreto", URL: "https://x.pe/secreto.png"},
+			{Type: domain.MessageQuickReplies, Text: "elige", Buttons: []domain.Button{{Title: "secreto", Payload: "p_secreto"}}},
+		},
+		Config: map[string]interface{}{"nombre_bot": "bot secreto", "max_tokens": 10},
+	})
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	var out strings.Builder
+	for _, name := range transcriptFiles(t, dir) {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out.Write(b)
+	}
+	got := out.String()
+	if strings.Contains(got, "secreto") {
+		t.Errorf("transcript leaks the redacted text: %s", got)
+	}
+	if n := strings.Count(got, "[REDACTED]"); n != 8 {
+		t.Errorf("redactions = %d, want 8 (message, reply, url, image text y url, title, payload, config): %s", n, got)
+	}
+	if !strings.Contains(got, `"max_tokens":10`) {
+		t.Errorf("non-string config values must be kept: %s", got)
+	}
+}
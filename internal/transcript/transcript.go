@@ -0,0 +1,85 @@
+// Package transcript registra cada intercambio completo (request, agente, reply, url, latencia, resultado)
+// para auditoria y debugging. Los logs solo guardan previews de 80 caracteres (domain.Preview).
+package transcript
+
+import (
+	"time"
+
+	"gateway/internal/domain"
+)
+
+// Resultados de un intercambio.
+const (
+	OutcomeOK       = "ok"
+	OutcomeFallback = "fallback"
+	OutcomeRejected = "rejected"
+)
+
+// Record is one chat exchange. El api_key del tenant nunca se registra.
+type Record struct {
+	Time      time.Time              `json:"time"`
+	RequestID string                 `json:"request_id,omitempty"`
+	TraceID   string                 `json:"trace_id,omitempty"`
+	IdEmpresa int                    `json:"id_empresa"`
+	SessionID int                    `json:"session_id"`
+	Modalidad string                 `json:"modalidad"`
+	Agent     string                 `json:"agent"`
+	Message   string                 `json:"message"`
+	Config    map[string]interface{} `json:"config,omitempty"`
+	Reply     string                 `json:"reply"` // lo que recibio el cliente (respuesta del agente o fallback)
+	URL       *string                `json:"url"`
+	Messages  []domain.Message       `json:"messages,omitempty"` // respuesta completa del agente si no era un solo texto
+	LatencyMs int64                  `json:"latency_ms"`
+	Outcome   string                 `json:"outcome"`
+	Reason    string                 `json:"reason,omitempty"` // domain.Reason* cuando outcome != ok
+}
+
+// Sink receives transcript records (implementado por FileSink; otros backends implementan lo mismo).
+// Write no debe bloquear el request: las implementaciones encolan y descartan si el backend no da abasto.
+type Sink interface {
+	Write(r Record)
+	Close() error
+}
+
+// Redactor rewrites a text before it is stored (ej. reglas de patrones con reemplazo).
+type Redactor func(text string) string
+
+// Redact applies fn to every free-text field of the record (message, reply, url y valores string de config).
+func (r Record) Redact(fn Redactor) Record {
+	if fn == nil {
+		return r
+	}
+	r.Message = fn(r.Message)
+	r.Reply = fn(r.Reply)
+	if r.URL != nil {
+		u := fn(*r.URL)
+		r.URL = &u
+	}
+	if len(r.Messages) > 0 {
+		msgs := make([]domain.Message, len(r.Messages))
+		for i, m := range r.Messages {
+			m.Text, m.URL = fn(m.Text), fn(m.URL)
+			if len(m.Buttons) > 0 {
+				buttons := make([]domain.Button, len(m.Buttons))
+				for j, b := range m.Buttons {
+					b.Title, b.Payload, b.URL = fn(b.Title), fn(b.Payload), fn(b.URL)
+					buttons[j] = b
+				}
+				m.Buttons = buttons
+			}
+			msgs[i] = m
+		}
+		r.Messages = msgs
+	}
+	if len(r.Config) > 0 {
+		cfg := make(map[string]interface{}, len(r.Config))
+		for k, v := range r.Config {
+			if s, ok := v.(string); ok {
+				v = fn(s)
+			}
+			cfg[k] = v
+		}
+		r.Config = cfg
+	}
+	return r
+}
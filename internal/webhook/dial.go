@@ -0,0 +1,67 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedAddress is returned when a callback resolves to an address the gateway must not reach
+// (loopback, link-local, redes privadas): callback_url lo elige el cliente y sin este control el
+// gateway haria POSTs a su propio listener admin o al metadata service del cloud.
+var ErrBlockedAddress = errors.New("webhook: destination address not allowed")
+
+// blockedPrefixes are non-public ranges not covered by netip.Addr's predicates.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"), // CGNAT
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64: puede apuntar a una IPv4 interna
+}
+
+// blockedIP reports whether ip is not a public unicast address.
+func blockedIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return true // loopback, link-local (169.254/16, fe80::/10), multicast, unspecified, RFC 1918, fc00::/7
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// newClient builds the delivery client: sin redirects (un 3xx cuenta como respuesta definitiva) y,
+// salvo allowPrivate, rechazando al conectar las IPs no publicas. El control va en el dial, sobre la
+// IP ya resuelta, para que un DNS que cambia entre validacion y entrega no lo salte.
+func newClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+			}
+			if blockedIP(ap.Addr()) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, ap.Addr())
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // el control de IPs aplica al destino, no a un proxy intermedio
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,365 @@
+// Package webhook entrega callbacks HTTP (ej. resultado de los jobs async) firmados con HMAC-SHA256.
+// Reintenta con backoff exponencial mientras la entrega tenga menos de MaxAge; las que agotan el plazo
+// o reciben un 4xx definitivo pasan a una lista dead-letter en memoria, desde donde se pueden reenviar.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"gateway/internal/config"
+	"gateway/internal/middleware"
+)
+
+// Headers de cada entrega. El ID se mantiene entre reintentos y redeliveries (idempotencia del receptor).
+const (
+	HeaderDelivery  = "X-Gateway-Delivery"
+	HeaderEvent     = "X-Gateway-Event"
+	HeaderTimestamp = "X-Gateway-Timestamp"
+	HeaderSignature = "X-Gateway-Signature"
+)
+
+// Options configures the dispatcher. Valores <= 0 toman los defaults.
+type Options struct {
+	Secrets        []string      // firma con cada uno ("v1=<hex>,v1=<hex>") para rotar sin cortes; vacio = sin firma
+	Timeout        time.Duration // por intento (default 10s)
+	MaxAge         time.Duration // desde el encolado; despues, dead-letter (default 1h)
+	InitialBackoff time.Duration // default 1s; se duplica en cada reintento
+	MaxBackoff     time.Duration // default 5m
+	Concurrency    int           // POSTs simultaneos (default 10)
+	MaxPending     int           // entregas en curso o esperando reintento (default 10000); excedente = dead-letter
+	MaxDeadLetters int           // se descartan las mas antiguas (default 1000)
+	AllowPrivate   bool          // permite destinos loopback, link-local y privados (receptores internos)
+}
+
+// Metrics receives delivery signals (implemented by metrics.Recorder).
+type Metrics interface {
+	ObserveWebhookAttempt(event string, ok bool, d time.Duration)
+	RecordWebhookResult(event, result string)
+	SetWebhookQueue(pending, dead int)
+}
+
+// Delivery is one callback. Los campos exportados son los que lista el endpoint admin.
+type Delivery struct {
+	ID         string     `json:"id"`
+	Event      string     `json:"event"`
+	URL        string     `json:"url"`
+	RequestID  string     `json:"request_id,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Attempts   int        `json:"attempts"`
+	LastStatus int        `json:"last_status,omitempty"` // 0 = error de red
+	LastError  string     `json:"last_error,omitempty"`
+	DeadAt     *time.Time `json:"dead_at,omitempty"`
+	Bytes      int        `json:"bytes"`
+
+	payload []byte
+}
+
+// Dispatcher delivers webhooks in background.
+type Dispatcher struct {
+	opts    Options
+	client  *http.Client
+	metrics Metrics // nil = sin metricas
+	sem     chan struct{}
+	stop    chan struct{} // se cierra en Shutdown: corta las esperas de backoff
+	wg      sync.WaitGroup
+
+	mu      sync.Mutex
+	closed  bool
+	pending int
+	dead    []*Delivery // mas antigua primero
+}
+
+// NewDispatcher creates a dispatcher. m puede ser nil.
+func NewDispatcher(opts Options, m Metrics) *Dispatcher {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = time.Hour
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 5 * time.Minute
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 10
+	}
+	if opts.MaxPending <= 0 {
+		opts.MaxPending = 10000
+	}
+	if opts.MaxDeadLetters <= 0 {
+		opts.MaxDeadLetters = 1000
+	}
+	return &Dispatcher{
+		opts:    opts,
+		client:  newClient(opts.Timeout, opts.AllowPrivate),
+		metrics: m,
+		sem:     make(chan struct{}, opts.Concurrency),
+		stop:    make(chan struct{}),
+	}
+}
+
+// Send queues payload (JSON) for url. No bloquea: la entrega y los reintentos corren en segundo plano.
+// El request ID de ctx viaja en X-Request-ID.
+func (d *Dispatcher) Send(ctx context.Context, url, event string, payload any) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		slog.ErrorContext(ctx, "webhook marshal", "event", event, "err", err)
+		return
+	}
+	del := &Delivery{
+		ID:        newID(),
+		Event:     event,
+		URL:       url,
+		RequestID: middleware.GetRequestID(ctx),
+		CreatedAt: time.Now().UTC(),
+		Bytes:     len(body),
+		payload:   body,
+	}
+	d.enqueue(del)
+}
+
+func (d *Dispatcher) enqueue(del *Delivery) {
+	d.mu.Lock()
+	switch {
+	case d.closed:
+		d.mu.Unlock()
+		d.kill(del, "dispatcher closed")
+		return
+	case d.pending >= d.opts.MaxPending:
+		d.mu.Unlock()
+		d.kill(del, "too many pending deliveries")
+		return
+	}
+	d.pending++
+	d.wg.Add(1)
+	d.mu.Unlock()
+	d.updateQueue()
+	go d.deliver(del)
+}
+
+// deliver retries del until it succeeds, fails permanently or exceeds MaxAge.
+func (d *Dispatcher) deliver(del *Delivery) {
+	defer func() {
+		d.mu.Lock()
+		d.pending--
+		d.mu.Unlock()
+		d.updateQueue()
+		d.wg.Done()
+	}()
+
+	backoff := d.opts.InitialBackoff
+	for {
+		select {
+		case d.sem <- struct{}{}:
+		case <-d.stop:
+			d.kill(del, "shutdown")
+			return
+		}
+		retry, err := d.attempt(del)
+		<-d.sem
+		if err == nil {
+			slog.Debug("webhook delivered", "delivery_id", del.ID, "event", del.Event, "attempts", del.Attempts, "request_id", del.RequestID)
+			d.result(del.Event, "delivered")
+			return
+		}
+		if !retry {
+			d.kill(del, err.Error())
+			return
+		}
+		wait := jitter(backoff)
+		backoff = min(backoff*2, d.opts.MaxBackoff)
+		if time.Since(del.CreatedAt)+wait > d.opts.MaxAge {
+			d.kill(del, fmt.Sprintf("max age exceeded: %v", err))
+			return
+		}
+		slog.Info("webhook retry", "delivery_id", del.ID, "event", del.Event, "attempts", del.Attempts, "in", wait.Round(time.Millisecond).String(), "err", err)
+		select {
+		case <-time.After(wait):
+		case <-d.stop:
+			d.kill(del, fmt.Sprintf("shutdown: %v", err))
+			return
+		}
+	}
+}
+
+// attempt POSTs del once. retry=false si el receptor rechazo la entrega (4xx salvo 408 y 429, o un
+// redirect: no se siguen) o si el destino es una IP bloqueada.
+func (d *Dispatcher) attempt(del *Delivery) (retry bool, err error) {
+	del.Attempts++
+	start := time.Now()
+	defer func() {
+		if d.metrics != nil {
+			d.metrics.ObserveWebhookAttempt(del.Event, err == nil, time.Since(start))
+		}
+	}()
+
+	req, err := http.NewRequest(http.MethodPost, del.URL, bytes.NewReader(del.payload))
+	if err != nil {
+		del.LastStatus, del.LastError = 0, err.Error()
+		return false, err
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDelivery, del.ID)
+	req.Header.Set(HeaderEvent, del.Event)
+	req.Header.Set(HeaderTimestamp, fmt.Sprint(ts))
+	if sig := Sign(d.opts.Secrets, ts, del.payload); sig != "" {
+		req.Header.Set(HeaderSignature, sig)
+	}
+	if del.RequestID != "" {
+		req.Header.Set("X-Request-ID", del.RequestID)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		del.LastStatus, del.LastError = 0, err.Error()
+		return !errors.Is(err, ErrBlockedAddress), err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	del.LastStatus = resp.StatusCode
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		del.LastError = ""
+		return false, nil
+	}
+	err = fmt.Errorf("status %d", resp.StatusCode)
+	del.LastError = err.Error()
+	retryable := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return retryable, err
+}
+
+// kill moves del to the dead-letter list.
+func (d *Dispatcher) kill(del *Delivery, reason string) {
+	now := time.Now().UTC()
+	del.DeadAt = &now
+	del.LastError = reason
+	slog.Warn("webhook dead-lettered", "delivery_id", del.ID, "event", del.Event, "url", config.RedactURL(del.URL), "attempts", del.Attempts, "reason", reason, "request_id", del.RequestID)
+	d.mu.Lock()
+	d.dead = append(d.dead, del)
+	if over := len(d.dead) - d.opts.MaxDeadLetters; over > 0 {
+		d.dead = append(d.dead[:0:0], d.dead[over:]...)
+	}
+	d.mu.Unlock()
+	d.result(del.Event, "dead_letter")
+	d.updateQueue()
+}
+
+// DeadLetters returns a copy of the dead-letter list (mas antigua primero).
+func (d *Dispatcher) DeadLetters() []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]Delivery, 0, len(d.dead))
+	for _, del := range d.dead {
+		out = append(out, *del)
+	}
+	return out
+}
+
+// Redeliver takes one delivery out of the dead-letter list and queues it again with a fresh MaxAge.
+func (d *Dispatcher) Redeliver(id string) bool {
+	d.mu.Lock()
+	var del *Delivery
+	for i, x := range d.dead {
+		if x.ID == id {
+			del = x
+			d.dead = append(d.dead[:i], d.dead[i+1:]...)
+			break
+		}
+	}
+	d.mu.Unlock()
+	if del == nil {
+		return false
+	}
+	d.revive(del)
+	return true
+}
+
+// RedeliverAll queues every dead-lettered delivery again. Devuelve cuantas se reencolaron.
+func (d *Dispatcher) RedeliverAll() int {
+	d.mu.Lock()
+	dead := d.dead
+	d.dead = nil
+	d.mu.Unlock()
+	for _, del := range dead {
+		d.revive(del)
+	}
+	return len(dead)
+}
+
+func (d *Dispatcher) revive(del *Delivery) {
+	del.CreatedAt = time.Now().UTC()
+	del.DeadAt = nil
+	del.LastError = ""
+	slog.Info("webhook redelivery", "delivery_id", del.ID, "event", del.Event)
+	d.enqueue(del)
+}
+
+// Shutdown stops accepting deliveries and cuts backoff waits (esas entregas pasan a dead-letter);
+// espera los POSTs en curso hasta que ctx venza.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	d.mu.Unlock()
+	close(d.stop)
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if n := len(d.DeadLetters()); n > 0 {
+		slog.Warn("webhook dead letters lost on shutdown", "count", n)
+	}
+	return nil
+}
+
+func (d *Dispatcher) result(event, result string) {
+	if d.metrics != nil {
+		d.metrics.RecordWebhookResult(event, result)
+	}
+}
+
+func (d *Dispatcher) updateQueue() {
+	if d.metrics == nil {
+		return
+	}
+	d.mu.Lock()
+	pending, dead := d.pending, len(d.dead)
+	d.mu.Unlock()
+	d.metrics.SetWebhookQueue(pending, dead)
+}
+
+// jitter returns d ± 20% para que los reintentos de muchas entregas no lleguen juntos.
+func jitter(d time.Duration) time.Duration {
+	return time.Duration(float64(d) * (0.8 + 0.4*rand.Float64()))
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = cryptorand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,25 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// Sign returns the X-Gateway-Signature value: "v1=<hex>" por secreto, separados por coma.
+// Se firma HMAC-SHA256("<timestamp>.<body>"); el receptor recalcula con su secreto, compara en
+// tiempo constante con cualquiera de las firmas y rechaza timestamps viejos (replay).
+// Sin secretos devuelve "".
+func Sign(secrets []string, timestamp int64, body []byte) string {
+	sigs := make([]string, 0, len(secrets))
+	for _, s := range secrets {
+		mac := hmac.New(sha256.New, []byte(s))
+		mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+		mac.Write([]byte("."))
+		mac.Write(body)
+		sigs = append(sigs, "v1="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	return strings.Join(sigs, ",")
+}